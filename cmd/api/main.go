@@ -1,58 +1,87 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"expvar"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
 	_ "github.com/DMaryanskiy/bookshare-api/docs" // swag init output
 	"github.com/DMaryanskiy/bookshare-api/internal/admin"
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
 	"github.com/DMaryanskiy/bookshare-api/internal/auth"
 	"github.com/DMaryanskiy/bookshare-api/internal/books"
+	"github.com/DMaryanskiy/bookshare-api/internal/config"
 	"github.com/DMaryanskiy/bookshare-api/internal/db"
+	"github.com/DMaryanskiy/bookshare-api/internal/loans"
 	"github.com/DMaryanskiy/bookshare-api/internal/middleware"
+	"github.com/DMaryanskiy/bookshare-api/internal/rbac"
 	"github.com/DMaryanskiy/bookshare-api/internal/task/distributor"
 	"github.com/DMaryanskiy/bookshare-api/internal/user"
+	"github.com/DMaryanskiy/bookshare-api/pkg/utils"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 	"github.com/redis/go-redis/v9"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
 func main() {
-	debug_mode := os.Getenv("DEBUG")
-	if debug_mode != "" {
-		err := godotenv.Load()
-		if err != nil {
-			log.Fatal("Failed to load env:", err)
-		}
+	cfg, err := config.Load(config.SectionDB, config.SectionRedis, config.SectionJWT, config.SectionRateLimit)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	db.InitDB()
+	conns, err := db.Connect(cfg.DB)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	redisAddr := os.Getenv("REDIS_ADDR")
+	keySet, err := utils.NewKeySet([]byte(cfg.JWT.Secret), cfg.JWT.KeysDir, cfg.JWT.ActiveKID)
+	if err != nil {
+		log.Fatal("Failed to load JWT keys:", err)
+	}
+	utils.SetDefaultKeySet(keySet)
+
+	redisAddr := cfg.Redis.Addr
 	taskDist := distributor.NewTaskDistributor(redisAddr)
 	tokenStore := auth.NewTokenStore(redisAddr)
 
 	redisClient := redis.NewClient(&redis.Options{Addr: redisAddr})
+	denylist := auth.NewDenylist(redisClient)
 
-	// An example of rate limiter
-	rateLimiter := middleware.NewRateLimiter(redisClient, map[string]middleware.RateLimitRule{
-		"api/v1/login": {
-			Limit:  5,
-			Window: time.Minute,
-		},
-		"api/v1/books": {
-			Limit:  100,
-			Window: time.Minute,
-		},
-	})
+	// Audit entries are batched off the request path; batches Postgres
+	// rejects are handed to the worker through the task queue
+	auditWriter := audit.NewWriter(audit.DefaultWriterConfig(), audit.DBStore{DB: conns.Primary}, taskDist)
+	auditLog := audit.NewLogger(auditWriter)
+
+	policy, err := middleware.ParseFailurePolicy(cfg.RateLimit.FailurePolicy)
+	if err != nil {
+		log.Fatal("Invalid rate limit failure policy:", err)
+	}
+	limiterCfg := middleware.DefaultRateLimiterConfig()
+	limiterCfg.Policy = policy
+
+	rulesFile := cfg.RateLimit.RulesFile
+	rules, err := middleware.LoadRateLimitRules(rulesFile)
+	if err != nil {
+		log.Fatal("Failed to load rate limit rules:", err)
+	}
+	rateLimiter := middleware.NewRateLimiter(redisClient, rules, limiterCfg)
+
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	go rateLimiter.WatchRules(watchCtx, rulesFile, 5*time.Second)
 
 	r := gin.Default()
 	r.Use(rateLimiter.Middleware())
 
-	userHandler := user.NewHandler(taskDist, tokenStore)
+	userHandler := user.NewHandler(conns.Primary, auditLog, taskDist, tokenStore, denylist, redisClient)
 
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
@@ -60,43 +89,126 @@ func main() {
 		ctx.JSON(200, gin.H{"message": "pong"})
 	})
 
+	r.GET("/.well-known/jwks.json", userHandler.JWKS)
+
 	// Group: Public routes
 	public := r.Group("/api/v1")
 	public.POST("/register", userHandler.RegisterUser)
 	public.GET("/verify", userHandler.VerifyEmail)
+	public.POST("/verify/resend", userHandler.ResendVerification)
 	public.POST("/login", userHandler.LoginUser)
+	public.POST("/login/mfa", userHandler.LoginMFA)
 	public.POST("/refresh", userHandler.RefreshToken)
+	public.GET("/forgot-password", userHandler.ForgotPasswordPage)
+	public.POST("/forgot-password", userHandler.ForgotPassword)
+	public.GET("/reset-password", userHandler.ResetPasswordPage)
+	public.POST("/reset-password", userHandler.ResetPassword)
 
 	// Group: Authenticated user routes
 	auth := r.Group("/api/v1")
-	auth.Use(middleware.JWTAuthMiddleware())
+	auth.Use(middleware.JWTAuthMiddleware(denylist))
 	auth.GET("/me", userHandler.GetMe)
 	auth.POST("/logout", userHandler.Logout)
+	auth.GET("/sessions", userHandler.ListSessions)
+	auth.POST("/mfa/totp/enroll", userHandler.EnrollTOTP)
+	auth.POST("/mfa/totp/confirm", userHandler.ConfirmTOTP)
+	auth.POST("/mfa/totp/disable", userHandler.DisableTOTP)
+	auth.DELETE("/sessions", userHandler.RevokeAllSessions)
+	auth.DELETE("/sessions/:id", userHandler.RevokeSession)
 
-	bookHandler := books.NewHandler()
+	bookHandler := books.NewHandler(conns, taskDist)
 
 	// Group: Books CRUD
 	booksGroup := r.Group("/api/v1/books")
-	booksGroup.Use(middleware.JWTAuthMiddleware())
+	booksGroup.Use(middleware.JWTAuthMiddleware(denylist))
 
 	booksGroup.POST("", bookHandler.CreateBook)
 	booksGroup.GET("", bookHandler.ListBooks)
+	booksGroup.GET("/search", bookHandler.SearchBooks)
 	booksGroup.GET("/:id", bookHandler.GetBook)
 	booksGroup.PUT("/:id", bookHandler.UpdateBook)
 	booksGroup.DELETE("/:id", bookHandler.DeleteBook)
 
-	adminHandler := admin.NewHandler()
+	// Group: Public catalog of shared books
+	catalogGroup := r.Group("/api/v1/catalog")
+	catalogGroup.Use(middleware.JWTAuthMiddleware(denylist))
+
+	catalogGroup.GET("", bookHandler.ListCatalog)
+	catalogGroup.GET("/:id", bookHandler.GetCatalogBook)
+
+	loanHandler := loans.NewHandler(conns.Primary, auditLog)
 
-	// Group: Admin handler
+	// Group: Book lending
+	loansGroup := r.Group("/api/v1/loans")
+	loansGroup.Use(middleware.JWTAuthMiddleware(denylist))
+
+	loansGroup.POST("", loanHandler.RequestLoan)
+	loansGroup.GET("", loanHandler.ListLoans)
+	loansGroup.GET("/:id", loanHandler.GetLoan)
+	loansGroup.POST("/:id/approve", loanHandler.ApproveLoan)
+	loansGroup.POST("/:id/reject", loanHandler.RejectLoan)
+	loansGroup.POST("/:id/return", loanHandler.ReturnLoan)
+
+	roles := rbac.NewStore(conns.Primary, rbac.DefaultCacheTTL)
+	adminHandler := admin.NewHandler(conns, auditLog, tokenStore, denylist, roles, rateLimiter)
+
+	// Group: Admin handler. Each route names the permission it needs; the
+	// role carried in the access token is looked up in auth.role_permissions.
 	adminGroup := r.Group("/api/v1/admin")
-	adminGroup.Use(middleware.JWTAuthMiddleware(), middleware.AdminOnly())
+	adminGroup.Use(middleware.JWTAuthMiddleware(denylist))
+
+	manageUsers := middleware.RequirePermission(roles, auditLog, rbac.PermUsersManage)
+	adminGroup.GET("/users", manageUsers, adminHandler.ListUsers)
+	adminGroup.GET("/users/:id", manageUsers, adminHandler.GetUser)
+	adminGroup.PATCH("/users/:id/role", manageUsers, adminHandler.UpdateUserRole)
+	adminGroup.POST("/users/:id/suspend", manageUsers, adminHandler.SuspendUser)
+	adminGroup.POST("/users/:id/unsuspend", manageUsers, adminHandler.UnsuspendUser)
+	adminGroup.POST("/users/:id/verify", manageUsers, adminHandler.VerifyUser)
+	adminGroup.DELETE("/users/:id", manageUsers, adminHandler.DeleteUser)
+
+	readAudit := middleware.RequirePermission(roles, auditLog, rbac.PermAuditRead)
+	adminGroup.GET("/audit-logs", readAudit, adminHandler.ListAuditLogs)
+	adminGroup.GET("/audit-logs/verify", readAudit, adminHandler.VerifyAuditLogs)
+
+	manageRoles := middleware.RequirePermission(roles, auditLog, rbac.PermRolesManage)
+	adminGroup.GET("/roles", manageRoles, adminHandler.ListRoles)
+	adminGroup.POST("/roles", manageRoles, adminHandler.CreateRole)
+	adminGroup.PUT("/roles/:name/permissions", manageRoles, adminHandler.SetRolePermissions)
+	adminGroup.DELETE("/roles/:name", manageRoles, adminHandler.DeleteRole)
+	adminGroup.GET("/permissions", manageRoles, adminHandler.ListPermissions)
+
+	manageLimits := middleware.RequirePermission(roles, auditLog, rbac.PermRateLimits)
+	adminGroup.GET("/rate-limits/rules", manageLimits, adminHandler.ListRateLimitRules)
+	adminGroup.GET("/rate-limits/overrides", manageLimits, adminHandler.ListRateLimitOverrides)
+	adminGroup.PUT("/rate-limits/overrides/:client", manageLimits, adminHandler.SetRateLimitOverride)
+	adminGroup.DELETE("/rate-limits/overrides/:client", manageLimits, adminHandler.DeleteRateLimitOverride)
+	adminGroup.GET("/rate-limits/api-keys", manageLimits, adminHandler.ListRateLimitAPIKeys)
+	adminGroup.POST("/rate-limits/api-keys", manageLimits, adminHandler.CreateRateLimitAPIKey)
+	adminGroup.DELETE("/rate-limits/api-keys/:id", manageLimits, adminHandler.RevokeRateLimitAPIKey)
+	adminGroup.GET("/rate-limits/clients/:client", manageLimits, adminHandler.GetRateLimitUsage)
+	adminGroup.DELETE("/rate-limits/clients/:client", manageLimits, adminHandler.ResetRateLimitUsage)
+
+	adminGroup.DELETE("/books/:id", middleware.RequirePermission(roles, auditLog, rbac.PermBooksModerate), adminHandler.RemoveBook)
+	adminGroup.GET("/debug/vars", middleware.RequirePermission(roles, auditLog, rbac.PermMetricsRead), gin.WrapH(expvar.Handler()))
+
+	srv := &http.Server{Addr: ":" + strconv.Itoa(cfg.HTTP.Port), Handler: r}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
 
-	adminGroup.GET("/users", adminHandler.ListUsers)
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Println("Shutting down...")
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Server shutdown: %v", err)
 	}
 
-	log.Fatal(r.Run(":" + port))
+	// Flush buffered audit entries once no request can add more
+	auditWriter.Close()
 }
@@ -2,30 +2,34 @@ package main
 
 import (
 	"log"
-	"os"
 
+	"github.com/DMaryanskiy/bookshare-api/internal/config"
 	"github.com/DMaryanskiy/bookshare-api/internal/db"
 	"github.com/DMaryanskiy/bookshare-api/internal/email"
+	"github.com/DMaryanskiy/bookshare-api/internal/metadata"
 	"github.com/DMaryanskiy/bookshare-api/internal/task/processor"
-	"github.com/joho/godotenv"
 )
 
 func main() {
-	debug_mode := os.Getenv("DEBUG")
-	if debug_mode != "" {
-		err := godotenv.Load()
-		if err != nil {
-			log.Fatal("Failed to load env:", err)
-		}
+	cfg, err := config.Load(config.SectionHTTP, config.SectionDB, config.SectionRedis, config.SectionSMTP, config.SectionMetadata)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	db.InitDB()
+	gdb, err := db.Open(cfg.DB.Source)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sender := email.NewEmailSender(cfg.SMTP)
 
-	redisAddr := os.Getenv("REDIS_ADDR")
-	sender := email.NewEmailSender()
+	provider, err := metadata.NewFileProvider(cfg.Metadata.File)
+	if err != nil {
+		log.Fatal("failed to load isbn metadata:", err)
+	}
 
-	taskProcessor := processor.NewTaskProcessor(sender)
-	if err := taskProcessor.Start(redisAddr); err != nil {
+	taskProcessor := processor.NewTaskProcessor(gdb, sender, provider, cfg.HTTP)
+	if err := taskProcessor.Start(cfg.Redis.Addr); err != nil {
 		log.Fatal("failed to start worker:", err)
 	}
 }
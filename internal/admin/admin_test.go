@@ -1,41 +1,89 @@
 package admin_test
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/DMaryanskiy/bookshare-api/internal/admin"
-	"github.com/DMaryanskiy/bookshare-api/internal/db"
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
+	"github.com/DMaryanskiy/bookshare-api/internal/auth"
+	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
 	"github.com/DMaryanskiy/bookshare-api/internal/middleware"
+	"github.com/DMaryanskiy/bookshare-api/internal/pagination"
+	"github.com/DMaryanskiy/bookshare-api/internal/rbac"
 	"github.com/DMaryanskiy/bookshare-api/internal/tests"
+	"github.com/DMaryanskiy/bookshare-api/pkg/utils"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 )
 
-func setupAdminRouter() *gin.Engine {
+var testRateLimitRules = []middleware.RateLimitRule{
+	{Pattern: "/api/v1/books/**", Limit: 4, Window: time.Minute},
+}
+
+func setupAdminRouter(gdb *gorm.DB) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 
 	router := gin.Default()
+	denylist := auth.NewDenylist(tests.TestRedis)
 
 	// Middleware for JWT auth
-	router.Use(middleware.JWTAuthMiddleware(), middleware.AdminOnly())
+	router.Use(middleware.JWTAuthMiddleware(denylist))
+
+	auditLog := tests.AuditLogger(gdb)
+	roles := rbac.NewStore(gdb, rbac.DefaultCacheTTL)
+	manageUsers := middleware.RequirePermission(roles, auditLog, rbac.PermUsersManage)
+	readAudit := middleware.RequirePermission(roles, auditLog, rbac.PermAuditRead)
+	manageRoles := middleware.RequirePermission(roles, auditLog, rbac.PermRolesManage)
+
+	limiter := middleware.NewRateLimiter(tests.TestRedis, testRateLimitRules, middleware.DefaultRateLimiterConfig())
+	manageLimits := middleware.RequirePermission(roles, auditLog, rbac.PermRateLimits)
 
-	adm := admin.NewHandler()
-	router.GET("/admin/users", adm.ListUsers)
+	adm := admin.NewHandler(tests.Conns(gdb), auditLog, auth.NewTokenStore(tests.TestRedis.Options().Addr), denylist, roles, limiter)
+	router.GET("/admin/users", manageUsers, adm.ListUsers)
+	router.GET("/admin/audit-logs", readAudit, adm.ListAuditLogs)
+	router.GET("/admin/audit-logs/verify", readAudit, adm.VerifyAuditLogs)
+	router.GET("/admin/users/:id", manageUsers, adm.GetUser)
+	router.PATCH("/admin/users/:id/role", manageUsers, adm.UpdateUserRole)
+	router.POST("/admin/users/:id/suspend", manageUsers, adm.SuspendUser)
+	router.POST("/admin/users/:id/unsuspend", manageUsers, adm.UnsuspendUser)
+	router.POST("/admin/users/:id/verify", manageUsers, adm.VerifyUser)
+	router.DELETE("/admin/users/:id", manageUsers, adm.DeleteUser)
+	router.GET("/admin/roles", manageRoles, adm.ListRoles)
+	router.POST("/admin/roles", manageRoles, adm.CreateRole)
+	router.PUT("/admin/roles/:name/permissions", manageRoles, adm.SetRolePermissions)
+	router.DELETE("/admin/roles/:name", manageRoles, adm.DeleteRole)
+	router.GET("/admin/permissions", manageRoles, adm.ListPermissions)
+	router.DELETE("/admin/books/:id", middleware.RequirePermission(roles, auditLog, rbac.PermBooksModerate), adm.RemoveBook)
+	router.GET("/admin/rate-limits/rules", manageLimits, adm.ListRateLimitRules)
+	router.GET("/admin/rate-limits/overrides", manageLimits, adm.ListRateLimitOverrides)
+	router.PUT("/admin/rate-limits/overrides/:client", manageLimits, adm.SetRateLimitOverride)
+	router.DELETE("/admin/rate-limits/overrides/:client", manageLimits, adm.DeleteRateLimitOverride)
+	router.GET("/admin/rate-limits/api-keys", manageLimits, adm.ListRateLimitAPIKeys)
+	router.POST("/admin/rate-limits/api-keys", manageLimits, adm.CreateRateLimitAPIKey)
+	router.DELETE("/admin/rate-limits/api-keys/:id", manageLimits, adm.RevokeRateLimitAPIKey)
+	router.GET("/admin/rate-limits/clients/:client", manageLimits, adm.GetRateLimitUsage)
+	router.DELETE("/admin/rate-limits/clients/:client", manageLimits, adm.ResetRateLimitUsage)
 
 	return router
 }
 
 func TestAdminListUsers_Success(t *testing.T) {
-	tests.SetupTestDB(t)
+	gdb := tests.SetupTestDB(t)
 	tests.SetupTestRedis()
 
-	admin, token := tests.CreateTestUser(t, "admin@example.com", "adminpass")
-	admin.Role = "admin"
-	require.NoError(t, db.DB.Table("auth.users").Save(&admin).Error)
+	_, token := createAdmin(t, gdb, "admin@example.com")
 
-	r := setupAdminRouter()
+	r := setupAdminRouter(gdb)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/admin/users", nil)
@@ -46,12 +94,12 @@ func TestAdminListUsers_Success(t *testing.T) {
 }
 
 func TestAdminAccess_ForbiddenForNonAdmin(t *testing.T) {
-	tests.SetupTestDB(t)
+	gdb := tests.SetupTestDB(t)
 	tests.SetupTestRedis()
 
-	_, token := tests.CreateTestUser(t, "user2@example.com", "userpass")
+	user, token := tests.CreateTestUser(t, gdb, "user2@example.com", "userpass")
 
-	r := setupAdminRouter()
+	r := setupAdminRouter(gdb)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/admin/users", nil)
@@ -59,4 +107,674 @@ func TestAdminAccess_ForbiddenForNonAdmin(t *testing.T) {
 
 	r.ServeHTTP(w, req)
 	require.Equal(t, http.StatusForbidden, w.Code)
+
+	var entry models.AuditLog
+	require.NoError(t, gdb.Table("logs.audit_logs").
+		Where("user_id = ? AND action = ?", user.ID, audit.ActionAdminAccessDenied).
+		First(&entry).Error)
+
+	var meta audit.Meta
+	require.NoError(t, json.Unmarshal([]byte(*entry.Metadata), &meta))
+	require.Equal(t, audit.OutcomeFailure, meta.Outcome)
+	require.Equal(t, "missing_permission", meta.Reason)
+	require.Equal(t, "/admin/users", meta.Details["path"])
+}
+
+func TestAdminListUsers_Filters(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	root, token := createAdmin(t, gdb, "filteradmin@example.com")
+
+	require.NoError(t, gdb.Table("auth.users").Create(&models.User{
+		Email:        "unverified@example.com",
+		PasswordHash: "somehash",
+	}).Error)
+
+	r := setupAdminRouter(gdb)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/admin/users?verified=false", nil)
+	req.Header.Set("Authorization", tests.GetAuthHeader(token))
+
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var page pagination.Page[admin.UserResponse]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Data, 1)
+	require.Equal(t, "unverified@example.com", page.Data[0].Email)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/admin/users?role=admin", nil)
+	req.Header.Set("Authorization", tests.GetAuthHeader(token))
+
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Data, 1)
+	require.Equal(t, root.ID, page.Data[0].ID)
+}
+
+func createAdmin(t *testing.T, gdb *gorm.DB, email string) (models.User, string) {
+	return tests.CreateTestUserWithRole(t, gdb, email, "adminpass", models.RoleAdmin)
+}
+
+func getAdmin(r *gin.Engine, path, token string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	req.Header.Set("Authorization", tests.GetAuthHeader(token))
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func seedAuditLogs(t *testing.T, gdb *gorm.DB, userID uuid.UUID) {
+	auditLog := tests.AuditLogger(gdb)
+	auditLog.Log(userID, audit.ActionLoginFailed, audit.Meta{IP: "10.0.0.1"}.Failure("invalid_password"))
+	auditLog.Log(userID, audit.ActionLoginSuccess, audit.Meta{IP: "10.0.0.1", Outcome: audit.OutcomeSuccess}.With("method", "totp"))
+	auditLog.Log(userID, audit.ActionLoginSuccess, audit.Meta{IP: "10.0.0.2", Outcome: audit.OutcomeSuccess}.With("method", "password"))
+	auditLog.Log(uuid.Nil, audit.ActionLoginFailed, audit.Meta{IP: "10.0.0.3"}.Failure("unknown_email").With("attempt", 3))
+}
+
+func TestAdminListAuditLogs_Filters(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	_, token := createAdmin(t, gdb, "auditadmin@example.com")
+	u, _ := tests.CreateTestUser(t, gdb, "audited@example.com", "password123")
+	seedAuditLogs(t, gdb, u.ID)
+
+	r := setupAdminRouter(gdb)
+
+	cases := []struct {
+		query string
+		want  int
+	}{
+		{"user_id=" + u.ID.String(), 3},
+		{"user_id=" + u.ID.String() + "&action=login_success", 2},
+		{"action=login_failed&meta.reason=unknown_email", 1},
+		{"meta.details.method=totp", 1},
+		{"meta.ip=10.0.0.1&meta.outcome=success", 1},
+		{"meta.details.attempt=3", 1},
+		{"meta.details.method=sms", 0},
+	}
+	for _, tc := range cases {
+		w := getAdmin(r, "/admin/audit-logs?"+tc.query, token)
+		require.Equal(t, http.StatusOK, w.Code, tc.query)
+
+		var page pagination.Page[admin.AuditLogResponse]
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		require.Len(t, page.Data, tc.want, tc.query)
+	}
+
+	w := getAdmin(r, "/admin/audit-logs?meta.details.method=totp", token)
+	var raw map[string][]map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &raw))
+	entry := raw["data"][0]
+	require.Equal(t, u.ID.String(), entry["user_id"])
+	require.Equal(t, "login_success", entry["action"])
+	require.Equal(t, "totp", entry["metadata"].(map[string]any)["details"].(map[string]any)["method"])
+
+	for _, query := range []string{"user_id=nope", "meta..ip=x", "format=xml", "created_after=yesterday"} {
+		w := getAdmin(r, "/admin/audit-logs?"+query, token)
+		require.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestAdminListAuditLogs_Pagination(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	_, token := createAdmin(t, gdb, "pageadmin@example.com")
+	u, _ := tests.CreateTestUser(t, gdb, "paged@example.com", "password123")
+	seedAuditLogs(t, gdb, u.ID)
+
+	r := setupAdminRouter(gdb)
+
+	seen := map[uuid.UUID]bool{}
+	path := "/admin/audit-logs?action=login_success&limit=1"
+	for i := 0; i < 3; i++ {
+		w := getAdmin(r, path, token)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var page pagination.Page[admin.AuditLogResponse]
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		for _, l := range page.Data {
+			seen[l.ID] = true
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		path = "/admin/audit-logs?action=login_success&limit=1&cursor=" + page.NextCursor
+	}
+	require.Len(t, seen, 2)
+}
+
+func TestAdminListAuditLogs_Export(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	_, token := createAdmin(t, gdb, "exportadmin@example.com")
+	u, _ := tests.CreateTestUser(t, gdb, "exported@example.com", "password123")
+	seedAuditLogs(t, gdb, u.ID)
+
+	r := setupAdminRouter(gdb)
+
+	// Exports ignore the page size
+	w := getAdmin(r, "/admin/audit-logs?format=csv&limit=1&user_id="+u.ID.String(), token)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Header().Get("Content-Type"), "text/csv")
+	require.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+
+	records, err := csv.NewReader(bytes.NewReader(w.Body.Bytes())).ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, []string{"id", "user_id", "action", "metadata", "created_at"}, records[0])
+	require.Len(t, records, 4)
+
+	w = getAdmin(r, "/admin/audit-logs?format=ndjson&action=login_failed", token)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+	lines := 0
+	scanner := bufio.NewScanner(bytes.NewReader(w.Body.Bytes()))
+	for scanner.Scan() {
+		var l admin.AuditLogResponse
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &l))
+		require.Equal(t, "login_failed", l.Action)
+		lines++
+	}
+	require.Equal(t, 2, lines)
+}
+
+func verifyChain(t *testing.T, r *gin.Engine, token string) audit.ChainReport {
+	w := getAdmin(r, "/admin/audit-logs/verify", token)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var report audit.ChainReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	return report
+}
+
+func TestAdminVerifyAuditLogs_DetectsTampering(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	_, token := createAdmin(t, gdb, "verifyadmin@example.com")
+	u, _ := tests.CreateTestUser(t, gdb, "chained@example.com", "password123")
+	seedAuditLogs(t, gdb, u.ID)
+
+	r := setupAdminRouter(gdb)
+
+	report := verifyChain(t, r, token)
+	require.True(t, report.OK())
+	require.Positive(t, report.Checked)
+
+	var target models.AuditLog
+	require.NoError(t, gdb.Table("logs.audit_logs").
+		Where("user_id = ? AND action = ?", u.ID, audit.ActionLoginFailed).
+		First(&target).Error)
+
+	// Rewriting the reason breaks the entry's own hash
+	require.NoError(t, gdb.Exec(
+		`UPDATE logs.audit_logs SET metadata = jsonb_set(metadata, '{reason}', '"typo"') WHERE id = ?`, target.ID,
+	).Error)
+
+	report = verifyChain(t, r, token)
+	require.NotNil(t, report.Broken)
+	require.Equal(t, target.ID, report.Broken.ID)
+	require.Equal(t, audit.BrokenHashMismatch, report.Broken.Reason)
+
+	// Deleting it instead leaves a gap
+	require.NoError(t, gdb.Exec(`DELETE FROM logs.audit_logs WHERE id = ?`, target.ID).Error)
+
+	report = verifyChain(t, r, token)
+	require.NotNil(t, report.Broken)
+	require.Equal(t, target.Seq+1, report.Broken.Seq)
+	require.Equal(t, audit.BrokenSeqGap, report.Broken.Reason)
+}
+
+func TestInsertAuditLogs_ReplayKeepsChain(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+
+	userID := uuid.New()
+	entries := []models.AuditLog{
+		{ID: uuid.New(), Action: string(audit.ActionLogout), CreatedAt: time.Now()},
+		{ID: uuid.New(), UserID: &userID, Action: string(audit.ActionLogout), CreatedAt: time.Now()},
+	}
+	require.NoError(t, audit.InsertAuditLogs(context.Background(), gdb, entries[:1]))
+
+	// A spilled batch replayed after its first entry already made it in
+	require.NoError(t, audit.InsertAuditLogs(context.Background(), gdb, entries))
+
+	var count int64
+	require.NoError(t, gdb.Table("logs.audit_logs").Count(&count).Error)
+	require.Equal(t, int64(2), count)
+
+	report, err := audit.VerifyChain(context.Background(), gdb)
+	require.NoError(t, err)
+	require.True(t, report.OK())
+	require.Equal(t, int64(2), report.Checked)
+	require.Equal(t, int64(2), report.LastSeq)
+}
+
+func sendAdmin(r *gin.Engine, method, path, token string, body any) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		_ = json.NewEncoder(&buf).Encode(body)
+	}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", tests.GetAuthHeader(token))
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func reloadUser(t *testing.T, gdb *gorm.DB, id uuid.UUID) models.User {
+	var u models.User
+	require.NoError(t, gdb.Table("auth.users").First(&u, "id = ?", id).Error)
+	return u
+}
+
+func requireAudited(t *testing.T, gdb *gorm.DB, actor models.User, action audit.Action, target uuid.UUID) {
+	var count int64
+	require.NoError(t, gdb.Table("logs.audit_logs").
+		Where("user_id = ? AND action = ? AND metadata @> ?::jsonb", actor.ID, action,
+			`{"details":{"target_user_id":"`+target.String()+`"}}`).
+		Count(&count).Error)
+	require.Equal(t, int64(1), count, action)
+}
+
+func TestAdminGetUser(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	_, token := createAdmin(t, gdb, "getadmin@example.com")
+	u, _ := tests.CreateTestUser(t, gdb, "someone@example.com", "password123")
+
+	r := setupAdminRouter(gdb)
+
+	w := getAdmin(r, "/admin/users/"+u.ID.String(), token)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "someone@example.com")
+
+	w = getAdmin(r, "/admin/users/"+uuid.NewString(), token)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = getAdmin(r, "/admin/users/not-a-uuid", token)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAdminUpdateUserRole(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	root, token := createAdmin(t, gdb, "roleadmin@example.com")
+	u, _ := tests.CreateTestUser(t, gdb, "promoted@example.com", "password123")
+
+	r := setupAdminRouter(gdb)
+
+	w := sendAdmin(r, http.MethodPatch, "/admin/users/"+u.ID.String()+"/role", token, gin.H{"role": "owner"})
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = sendAdmin(r, http.MethodPatch, "/admin/users/"+u.ID.String()+"/role", token, gin.H{"role": "admin"})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, models.RoleAdmin, reloadUser(t, gdb, u.ID).Role)
+	requireAudited(t, gdb, root, audit.ActionUserRoleChanged, u.ID)
+
+	// The role change denies tokens issued up to this second; the promoted
+	// user's next token carries the admin role and can demote the original admin
+	time.Sleep(time.Second)
+	userToken, err := utils.GenerateAccessToken(u.ID.String(), models.RoleAdmin, time.Hour)
+	require.NoError(t, err)
+	w = sendAdmin(r, http.MethodPatch, "/admin/users/"+root.ID.String()+"/role", userToken, gin.H{"role": "user"})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// but not themselves, as the only admin left
+	w = sendAdmin(r, http.MethodPatch, "/admin/users/"+u.ID.String()+"/role", userToken, gin.H{"role": "user"})
+	require.Equal(t, http.StatusConflict, w.Code)
+	require.Equal(t, models.RoleAdmin, reloadUser(t, gdb, u.ID).Role)
+	requireAudited(t, gdb, u, audit.ActionAdminActionFailed, u.ID)
+}
+
+func TestAdminSuspendUser(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	root, token := createAdmin(t, gdb, "suspendadmin@example.com")
+	other, otherToken := createAdmin(t, gdb, "suspended@example.com")
+
+	r := setupAdminRouter(gdb)
+
+	w := sendAdmin(r, http.MethodPost, "/admin/users/"+other.ID.String()+"/suspend", token, gin.H{"reason": "abuse"})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotNil(t, reloadUser(t, gdb, other.ID).SuspendedAt)
+	requireAudited(t, gdb, root, audit.ActionUserSuspended, other.ID)
+
+	// Their access token is rejected straight away
+	w = getAdmin(r, "/admin/users/"+root.ID.String(), otherToken)
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.Contains(t, w.Body.String(), "suspended")
+
+	w = sendAdmin(r, http.MethodPost, "/admin/users/"+other.ID.String()+"/suspend", token, nil)
+	require.Equal(t, http.StatusConflict, w.Code)
+
+	// A suspended admin does not count, so root is the last active one
+	w = sendAdmin(r, http.MethodPost, "/admin/users/"+root.ID.String()+"/suspend", token, nil)
+	require.Equal(t, http.StatusConflict, w.Code)
+	require.Nil(t, reloadUser(t, gdb, root.ID).SuspendedAt)
+
+	w = sendAdmin(r, http.MethodPost, "/admin/users/"+other.ID.String()+"/unsuspend", token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Nil(t, reloadUser(t, gdb, other.ID).SuspendedAt)
+	requireAudited(t, gdb, root, audit.ActionUserUnsuspended, other.ID)
+
+	suspended, err := auth.NewDenylist(tests.TestRedis).IsSuspended(context.Background(), other.ID.String())
+	require.NoError(t, err)
+	require.False(t, suspended)
+
+	w = sendAdmin(r, http.MethodPost, "/admin/users/"+other.ID.String()+"/unsuspend", token, nil)
+	require.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestAdminVerifyUser(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	root, token := createAdmin(t, gdb, "verifyuseradmin@example.com")
+	u := models.User{Email: "pending@example.com", PasswordHash: "somehash"}
+	require.NoError(t, gdb.Table("auth.users").Create(&u).Error)
+
+	r := setupAdminRouter(gdb)
+
+	w := sendAdmin(r, http.MethodPost, "/admin/users/"+u.ID.String()+"/verify", token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.True(t, reloadUser(t, gdb, u.ID).IsVerified)
+	requireAudited(t, gdb, root, audit.ActionUserForceVerified, u.ID)
+}
+
+func TestAdminDeleteUser(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	root, token := createAdmin(t, gdb, "deleteadmin@example.com")
+	u, _ := tests.CreateTestUser(t, gdb, "doomed@example.com", "password123")
+	tests.AuditLogger(gdb).Log(u.ID, audit.ActionLoginSuccess, audit.Meta{Outcome: audit.OutcomeSuccess})
+
+	r := setupAdminRouter(gdb)
+
+	w := sendAdmin(r, http.MethodDelete, "/admin/users/"+u.ID.String(), token, nil)
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	var count int64
+	gdb.Table("auth.users").Where("id = ?", u.ID).Count(&count)
+	require.Equal(t, int64(0), count)
+	requireAudited(t, gdb, root, audit.ActionUserDeleted, u.ID)
+
+	// The user's own history is kept and the chain is untouched
+	gdb.Table("logs.audit_logs").Where("user_id = ?", u.ID).Count(&count)
+	require.Equal(t, int64(1), count)
+	require.True(t, verifyChain(t, r, token).OK())
+
+	w = sendAdmin(r, http.MethodDelete, "/admin/users/"+u.ID.String(), token, nil)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = sendAdmin(r, http.MethodDelete, "/admin/users/"+root.ID.String(), token, nil)
+	require.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestResponses_NoSensitiveFields(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	_, token := createAdmin(t, gdb, "sensitiveadmin@example.com")
+	u, _ := tests.CreateTestUser(t, gdb, "target@example.com", "password123")
+	seedAuditLogs(t, gdb, u.ID)
+
+	r := setupAdminRouter(gdb)
+	id := u.ID.String()
+
+	check := func(w *httptest.ResponseRecorder) {
+		t.Helper()
+		require.Less(t, w.Code, 300, w.Body.String())
+		tests.RequireNoSensitiveFields(t, w.Body.Bytes())
+	}
+
+	check(getAdmin(r, "/admin/users", token))
+	check(getAdmin(r, "/admin/users/"+id, token))
+	check(getAdmin(r, "/admin/audit-logs", token))
+	check(getAdmin(r, "/admin/audit-logs/verify", token))
+	check(sendAdmin(r, http.MethodPatch, "/admin/users/"+id+"/role", token, gin.H{"role": "admin"}))
+	check(sendAdmin(r, http.MethodPost, "/admin/users/"+id+"/suspend", token, nil))
+	check(sendAdmin(r, http.MethodPost, "/admin/users/"+id+"/unsuspend", token, nil))
+	check(sendAdmin(r, http.MethodPost, "/admin/users/"+id+"/verify", token, nil))
+
+	// NDJSON export lines are documents of their own
+	w := getAdmin(r, "/admin/audit-logs?format=ndjson", token)
+	require.Equal(t, http.StatusOK, w.Code)
+	scanner := bufio.NewScanner(bytes.NewReader(w.Body.Bytes()))
+	for scanner.Scan() {
+		tests.RequireNoSensitiveFields(t, scanner.Bytes())
+	}
+}
+
+func TestAdminRoles_ManagePermissions(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	_, token := createAdmin(t, gdb, "rolesadmin@example.com")
+	r := setupAdminRouter(gdb)
+
+	w := sendAdmin(r, http.MethodPost, "/admin/roles", token, gin.H{"name": "moderator", "permissions": []string{"books:fly"}})
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = sendAdmin(r, http.MethodPost, "/admin/roles", token, gin.H{
+		"name":        "moderator",
+		"description": "Removes books that break the rules",
+		"permissions": []rbac.Permission{rbac.PermBooksModerate},
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var role admin.RoleResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &role))
+	require.Equal(t, []rbac.Permission{rbac.PermBooksModerate}, role.Permissions)
+
+	w = sendAdmin(r, http.MethodPost, "/admin/roles", token, gin.H{"name": "moderator"})
+	require.Equal(t, http.StatusConflict, w.Code)
+
+	owner, _ := tests.CreateTestUser(t, gdb, "owner@example.com", "password123")
+	book := models.Book{UserID: owner.ID, Title: "Spam"}
+	require.NoError(t, gdb.Table("books.books").Create(&book).Error)
+
+	_, modToken := tests.CreateTestUserWithRole(t, gdb, "moderator@example.com", "password123", "moderator")
+
+	// A moderator may remove books but not manage users or read the audit log
+	w = getAdmin(r, "/admin/users", modToken)
+	require.Equal(t, http.StatusForbidden, w.Code)
+	w = getAdmin(r, "/admin/audit-logs", modToken)
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	w = sendAdmin(r, http.MethodDelete, "/admin/books/"+book.ID.String(), modToken, nil)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	w = sendAdmin(r, http.MethodDelete, "/admin/books/"+book.ID.String(), modToken, nil)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	// Granting a permission applies to existing tokens straight away
+	w = sendAdmin(r, http.MethodPut, "/admin/roles/moderator/permissions", token, gin.H{
+		"permissions": []rbac.Permission{rbac.PermBooksModerate, rbac.PermAuditRead},
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+	w = getAdmin(r, "/admin/audit-logs", modToken)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = sendAdmin(r, http.MethodDelete, "/admin/roles/moderator", token, nil)
+	require.Equal(t, http.StatusConflict, w.Code)
+	w = sendAdmin(r, http.MethodDelete, "/admin/roles/admin", token, nil)
+	require.Equal(t, http.StatusConflict, w.Code)
+
+	w = getAdmin(r, "/admin/roles", token)
+	require.Equal(t, http.StatusOK, w.Code)
+	var roles []admin.RoleResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &roles))
+	require.Len(t, roles, 3)
+
+	w = sendAdmin(r, http.MethodPost, "/admin/roles", token, gin.H{"name": "unused"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = sendAdmin(r, http.MethodDelete, "/admin/roles/unused", token, nil)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	w = sendAdmin(r, http.MethodDelete, "/admin/roles/unused", token, nil)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAdminRoles_KeepsLastAdmin(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	_, token := createAdmin(t, gdb, "lastroleadmin@example.com")
+	r := setupAdminRouter(gdb)
+
+	// Nobody outside the admin role can manage users, so admin keeps users:manage
+	w := sendAdmin(r, http.MethodPut, "/admin/roles/admin/permissions", token, gin.H{
+		"permissions": []rbac.Permission{rbac.PermRolesManage},
+	})
+	require.Equal(t, http.StatusConflict, w.Code)
+
+	w = getAdmin(r, "/admin/users", token)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// Once another role's active user holds it, the admin role may give it up
+	w = sendAdmin(r, http.MethodPost, "/admin/roles", token, gin.H{
+		"name":        "support",
+		"permissions": []rbac.Permission{rbac.PermUsersManage},
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	tests.CreateTestUserWithRole(t, gdb, "support@example.com", "password123", "support")
+
+	w = sendAdmin(r, http.MethodPut, "/admin/roles/admin/permissions", token, gin.H{
+		"permissions": []rbac.Permission{rbac.PermRolesManage},
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = getAdmin(r, "/admin/users", token)
+	require.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestAdminRateLimits(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	_, token := createAdmin(t, gdb, "limitsadmin@example.com")
+	u, _ := tests.CreateTestUser(t, gdb, "limited@example.com", "password123")
+	client := "user:" + u.ID.String()
+
+	r := setupAdminRouter(gdb)
+
+	w := getAdmin(r, "/admin/rate-limits/rules", token)
+	require.Equal(t, http.StatusOK, w.Code)
+	var rules []admin.RateLimitRuleResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rules))
+	require.Equal(t, []admin.RateLimitRuleResponse{{Pattern: "/api/v1/books/**", Limit: 4, Window: "1m0s"}}, rules)
+
+	// Spend two requests of the user's bucket
+	limiter := middleware.NewRateLimiter(tests.TestRedis, testRateLimitRules, middleware.DefaultRateLimiterConfig())
+	for range 2 {
+		res, err := limiter.Allow(context.Background(), testRateLimitRules[0], client, 4)
+		require.NoError(t, err)
+		require.True(t, res.Allowed)
+	}
+
+	w = getAdmin(r, "/admin/rate-limits/clients/"+client, token)
+	require.Equal(t, http.StatusOK, w.Code)
+	var usage []admin.RateLimitUsageResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &usage))
+	require.Len(t, usage, 1)
+	require.Equal(t, 2, usage[0].Remaining)
+	require.WithinDuration(t, time.Now().Add(30*time.Second), usage[0].ResetAt, 2*time.Second)
+
+	w = sendAdmin(r, http.MethodDelete, "/admin/rate-limits/clients/"+client, token, nil)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	w = getAdmin(r, "/admin/rate-limits/clients/"+client, token)
+	require.JSONEq(t, "[]", w.Body.String())
+
+	w = getAdmin(r, "/admin/rate-limits/clients/nobody", token)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	// An override replaces the rule's limit for that user only
+	w = sendAdmin(r, http.MethodPut, "/admin/rate-limits/overrides/"+client, token, gin.H{"limit": 1})
+	require.Equal(t, http.StatusOK, w.Code)
+	w = sendAdmin(r, http.MethodPut, "/admin/rate-limits/overrides/ip:10.0.0.1", token, gin.H{"limit": 1})
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	res, err := limiter.Allow(context.Background(), testRateLimitRules[0], client, 4)
+	require.NoError(t, err)
+	require.Equal(t, 1, res.Limit)
+	res, err = limiter.Allow(context.Background(), testRateLimitRules[0], client, 4)
+	require.NoError(t, err)
+	require.False(t, res.Allowed)
+
+	// Usage reports the limit the bucket was counted with, not the rule's
+	w = getAdmin(r, "/admin/rate-limits/clients/"+client, token)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &usage))
+	require.Len(t, usage, 1)
+	require.Equal(t, 1, usage[0].Limit)
+	require.Equal(t, 0, usage[0].Remaining)
+
+	w = getAdmin(r, "/admin/rate-limits/overrides", token)
+	require.JSONEq(t, `[{"client":"`+client+`","limit":1}]`, w.Body.String())
+
+	w = sendAdmin(r, http.MethodDelete, "/admin/rate-limits/overrides/"+client, token, nil)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	w = sendAdmin(r, http.MethodDelete, "/admin/rate-limits/overrides/"+client, token, nil)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	var count int64
+	require.NoError(t, gdb.Table("logs.audit_logs").
+		Where("action IN ?", []audit.Action{audit.ActionRateLimitReset, audit.ActionRateLimitOverrideSet, audit.ActionRateLimitOverrideRemoved}).
+		Count(&count).Error)
+	require.Equal(t, int64(3), count)
+}
+
+func TestAdminRateLimitAPIKeys(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	_, token := createAdmin(t, gdb, "keysadmin@example.com")
+	r := setupAdminRouter(gdb)
+
+	w := sendAdmin(r, http.MethodPost, "/admin/rate-limits/api-keys", token, gin.H{"id": "partner"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created admin.APIKeyResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	require.Equal(t, "apikey:partner", created.Client)
+	require.NotEmpty(t, created.Key)
+
+	w = sendAdmin(r, http.MethodPost, "/admin/rate-limits/api-keys", token, gin.H{"id": "partner"})
+	require.Equal(t, http.StatusConflict, w.Code)
+	w = sendAdmin(r, http.MethodPost, "/admin/rate-limits/api-keys", token, gin.H{"id": "no/slashes"})
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	// The key is never shown again
+	w = getAdmin(r, "/admin/rate-limits/api-keys", token)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `[{"id":"partner","client":"apikey:partner"}]`, w.Body.String())
+	require.NotContains(t, w.Body.String(), created.Key)
+
+	w = sendAdmin(r, http.MethodPut, "/admin/rate-limits/overrides/apikey:partner", token, gin.H{"limit": 50})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = sendAdmin(r, http.MethodDelete, "/admin/rate-limits/api-keys/partner", token, nil)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	w = sendAdmin(r, http.MethodDelete, "/admin/rate-limits/api-keys/partner", token, nil)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = getAdmin(r, "/admin/rate-limits/overrides", token)
+	require.JSONEq(t, "[]", w.Body.String())
+
+	var count int64
+	require.NoError(t, gdb.Table("logs.audit_logs").
+		Where("action IN ?", []audit.Action{audit.ActionRateLimitAPIKeyCreated, audit.ActionRateLimitAPIKeyRevoked}).
+		Count(&count).Error)
+	require.Equal(t, int64(2), count)
 }
@@ -0,0 +1,256 @@
+package admin
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
+	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"github.com/DMaryanskiy/bookshare-api/internal/pagination"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const metaFilterPrefix = "meta."
+
+var errInvalidMetaFilter = errors.New("meta filters must be meta.<key>[.<key>...]=value")
+
+// AuditLogResponse is an audit entry as returned to admins, with the
+// metadata document inlined rather than as an escaped string.
+type AuditLogResponse struct {
+	ID        uuid.UUID       `json:"id"`
+	UserID    *uuid.UUID      `json:"user_id"`
+	Action    string          `json:"action"`
+	Metadata  json.RawMessage `json:"metadata"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+func newAuditLogResponse(l models.AuditLog) AuditLogResponse {
+	resp := AuditLogResponse{ID: l.ID, UserID: l.UserID, Action: l.Action, CreatedAt: l.CreatedAt}
+	if l.Metadata != nil {
+		resp.Metadata = json.RawMessage(*l.Metadata)
+	} else {
+		resp.Metadata = json.RawMessage("null")
+	}
+	return resp
+}
+
+func auditLogCursor(l AuditLogResponse) pagination.Cursor {
+	return pagination.Cursor{CreatedAt: l.CreatedAt, ID: l.ID}
+}
+
+// metaCondition turns meta.details.method=totp into a containment match on
+// {"details":{"method":"totp"}}, which the GIN index on metadata serves.
+// Values that look like numbers or booleans match either the typed JSON
+// value or the string, since query parameters carry no type.
+func metaCondition(key, value string) (string, []any, error) {
+	path := strings.Split(strings.TrimPrefix(key, metaFilterPrefix), ".")
+	for _, part := range path {
+		if part == "" {
+			return "", nil, errInvalidMetaFilter
+		}
+	}
+
+	candidates := []any{value}
+	var typed any
+	if err := json.Unmarshal([]byte(value), &typed); err == nil {
+		switch typed.(type) {
+		case bool, float64:
+			candidates = append(candidates, json.RawMessage(value))
+		}
+	}
+
+	clauses := make([]string, 0, len(candidates))
+	args := make([]any, 0, len(candidates))
+	for _, v := range candidates {
+		doc := v
+		for i := len(path) - 1; i >= 0; i-- {
+			doc = map[string]any{path[i]: doc}
+		}
+		b, err := json.Marshal(doc)
+		if err != nil {
+			return "", nil, err
+		}
+		clauses = append(clauses, "metadata @> ?::jsonb")
+		args = append(args, string(b))
+	}
+	return "(" + strings.Join(clauses, " OR ") + ")", args, nil
+}
+
+// auditLogQuery applies the user_id, action and meta.* filters.
+func (h *Handler) auditLogQuery(c *gin.Context) (*gorm.DB, error) {
+	query := h.ReadDB.Table("logs.audit_logs")
+
+	if raw := c.Query("user_id"); raw != "" {
+		userID, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, errors.New("user_id must be a UUID")
+		}
+		query = query.Where("user_id = ?", userID)
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+
+	for key, values := range c.Request.URL.Query() {
+		if !strings.HasPrefix(key, metaFilterPrefix) {
+			continue
+		}
+		for _, value := range values {
+			cond, args, err := metaCondition(key, value)
+			if err != nil {
+				return nil, err
+			}
+			query = query.Where(cond, args...)
+		}
+	}
+
+	return query, nil
+}
+
+// ListAuditLogs godoc
+// @Summary      Query the audit log
+// @Description  Retrieves a page of audit log entries (newest first by default), or exports every matching entry as CSV or NDJSON
+// @Tags         admin
+// @Produce      json
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Param        limit           query     int     false  "Page size (default 20, max 100); ignored by exports"
+// @Param        cursor          query     string  false  "Cursor from a previous page's next_cursor"
+// @Param        sort            query     string  false  "created_at or -created_at (default)"
+// @Param        user_id         query     string  false  "Filter by user ID"
+// @Param        action          query     string  false  "Filter by action, e.g. login_failed"
+// @Param        created_after   query     string  false  "Only entries created at or after this RFC3339 time"
+// @Param        created_before  query     string  false  "Only entries created before this RFC3339 time"
+// @Param        meta.key        query     string  false  "Filter by metadata value; nested keys are dotted, e.g. meta.details.method=totp"
+// @Param        format          query     string  false  "csv or ndjson to export instead of paginating"
+// @Success      200  {object}  pagination.Page[AuditLogResponse]  "Page of audit log entries"
+// @Failure      400  {object}  map[string]string  "Invalid pagination or filter"
+// @Failure      500  {object}  map[string]string  "Could not retrieve audit logs"
+// @Router       /admin/audit-logs [get]
+func (h *Handler) ListAuditLogs(c *gin.Context) {
+	params, err := pagination.Parse(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query, err := h.auditLogQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch format := c.Query("format"); format {
+	case "":
+	case "csv", "ndjson":
+		h.exportAuditLogs(c, params.Scope(query), format)
+		return
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or ndjson"})
+		return
+	}
+
+	var logs []models.AuditLog
+	if err := params.Apply(query).Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not retrieve audit logs"})
+		return
+	}
+
+	resp := make([]AuditLogResponse, len(logs))
+	for i, l := range logs {
+		resp[i] = newAuditLogResponse(l)
+	}
+
+	c.JSON(http.StatusOK, pagination.NewPage(resp, params, auditLogCursor))
+}
+
+// exportAuditLogs streams rows as they are read so that large exports are
+// never held in memory. Once the first row is written the status is
+// committed, so a failure part-way through can only cut the body short.
+func (h *Handler) exportAuditLogs(c *gin.Context, query *gorm.DB, format string) {
+	rows, err := query.Rows()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not retrieve audit logs"})
+		return
+	}
+	defer rows.Close()
+
+	filename := fmt.Sprintf("audit-logs-%s.%s", time.Now().UTC().Format("20060102T150405Z"), format)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	var write func(AuditLogResponse) error
+	var flush func()
+	switch format {
+	case "csv":
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		w := csv.NewWriter(c.Writer)
+		if err := w.Write([]string{"id", "user_id", "action", "metadata", "created_at"}); err != nil {
+			return
+		}
+		write = func(l AuditLogResponse) error {
+			userID, metadata := "", ""
+			if l.UserID != nil {
+				userID = l.UserID.String()
+			}
+			if string(l.Metadata) != "null" {
+				metadata = string(l.Metadata)
+			}
+			return w.Write([]string{
+				l.ID.String(), userID, l.Action, metadata, l.CreatedAt.UTC().Format(time.RFC3339Nano),
+			})
+		}
+		flush = func() { w.Flush(); c.Writer.Flush() }
+	case "ndjson":
+		c.Header("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(c.Writer)
+		write = func(l AuditLogResponse) error { return enc.Encode(l) }
+		flush = c.Writer.Flush
+	}
+	c.Status(http.StatusOK)
+
+	n := 0
+	for rows.Next() {
+		var l models.AuditLog
+		if err := h.ReadDB.ScanRows(rows, &l); err != nil {
+			c.Error(err)
+			break
+		}
+		if err := write(newAuditLogResponse(l)); err != nil {
+			// The client went away
+			c.Error(err)
+			break
+		}
+		if n++; n%100 == 0 {
+			flush()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		c.Error(err)
+	}
+	flush()
+}
+
+// VerifyAuditLogs godoc
+// @Summary      Verify the audit log hash chain
+// @Description  Recomputes the hash of every audit log entry in order and reports the first entry that was altered, removed or inserted out of band
+// @Tags         admin
+// @Produce      json
+// @Success      200  {object}  audit.ChainReport  "Verification result; broken is set when the chain does not check out"
+// @Failure      500  {object}  map[string]string  "Could not verify audit logs"
+// @Router       /admin/audit-logs/verify [get]
+func (h *Handler) VerifyAuditLogs(c *gin.Context) {
+	report, err := audit.VerifyChain(c.Request.Context(), h.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not verify audit logs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
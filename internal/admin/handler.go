@@ -1,7 +1,32 @@
 package admin
 
-type Handler struct {}
+import (
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
+	"github.com/DMaryanskiy/bookshare-api/internal/auth"
+	"github.com/DMaryanskiy/bookshare-api/internal/db"
+	"github.com/DMaryanskiy/bookshare-api/internal/middleware"
+	"github.com/DMaryanskiy/bookshare-api/internal/rbac"
+	"gorm.io/gorm"
+)
 
-func NewHandler() *Handler {
-	return &Handler{}
-}
\ No newline at end of file
+type Handler struct {
+	DB         *gorm.DB
+	ReadDB     *gorm.DB // user listings and audit log search, may lag behind DB
+	Audit      *audit.Logger
+	TokenStore auth.RefreshTokenStore
+	Denylist   *auth.Denylist
+	Roles      *rbac.Store
+	Limiter    *middleware.RateLimiter
+}
+
+func NewHandler(conns db.Conns, auditLog *audit.Logger, ts auth.RefreshTokenStore, denylist *auth.Denylist, roles *rbac.Store, limiter *middleware.RateLimiter) *Handler {
+	return &Handler{
+		DB:         conns.Primary,
+		ReadDB:     conns.Replica,
+		Audit:      auditLog,
+		TokenStore: ts,
+		Denylist:   denylist,
+		Roles:      roles,
+		Limiter:    limiter,
+	}
+}
@@ -2,27 +2,86 @@ package admin
 
 import (
 	"net/http"
+	"strconv"
+	"time"
 
-	"github.com/DMaryanskiy/bookshare-api/internal/db"
 	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"github.com/DMaryanskiy/bookshare-api/internal/pagination"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
+// UserResponse is a user as admins see it. Credentials and second factor
+// secrets are never part of it.
+type UserResponse struct {
+	ID          uuid.UUID  `json:"id"`
+	Email       string     `json:"email"`
+	Role        string     `json:"role"`
+	IsVerified  bool       `json:"is_verified"`
+	TOTPEnabled bool       `json:"totp_enabled"`
+	SuspendedAt *time.Time `json:"suspended_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func newUserResponse(u models.User) UserResponse {
+	return UserResponse{
+		ID:          u.ID,
+		Email:       u.Email,
+		Role:        u.Role,
+		IsVerified:  u.IsVerified,
+		TOTPEnabled: u.TOTPEnabled,
+		SuspendedAt: u.SuspendedAt,
+		CreatedAt:   u.CreatedAt,
+		UpdatedAt:   u.UpdatedAt,
+	}
+}
+
+func userCursor(u models.User) pagination.Cursor {
+	return pagination.Cursor{CreatedAt: u.CreatedAt, ID: u.ID}
+}
+
 // ListUsers godoc
 // @Summary      List all users
-// @Description  Retrieves a list of all users ordered by creation date descending
+// @Description  Retrieves a page of users ordered by creation date (newest first by default)
 // @Tags         admin
 // @Produce      json
-// @Success      200  {array}   models.User  "List of users"
+// @Param        limit           query     int     false  "Page size (default 20, max 100)"
+// @Param        cursor          query     string  false  "Cursor from a previous page's next_cursor"
+// @Param        sort            query     string  false  "created_at or -created_at (default)"
+// @Param        role            query     string  false  "Filter by role"
+// @Param        verified        query     bool    false  "Filter by verification status"
+// @Param        created_after   query     string  false  "Only users created at or after this RFC3339 time"
+// @Param        created_before  query     string  false  "Only users created before this RFC3339 time"
+// @Success      200  {object}  pagination.Page[UserResponse]  "Page of users"
+// @Failure      400  {object}  map[string]string  "Invalid pagination or filter"
 // @Failure      500  {object}  map[string]string  "Could not retrieve users"
 // @Router       /admin/users [get]
 func (h *Handler) ListUsers(c *gin.Context) {
-	var users []models.User
+	params, err := pagination.Parse(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	if err := db.DB.Table("auth.users").Order("created_at desc").Find(&users).Error; err != nil {
+	query := h.ReadDB.Table("auth.users")
+	if role := c.Query("role"); role != "" {
+		query = query.Where("role = ?", role)
+	}
+	if raw := c.Query("verified"); raw != "" {
+		verified, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "verified must be true or false"})
+			return
+		}
+		query = query.Where("is_verified = ?", verified)
+	}
+
+	var users []models.User
+	if err := params.Apply(query).Find(&users).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not retrieve users"})
 		return
 	}
 
-	c.JSON(http.StatusOK, users)
+	c.JSON(http.StatusOK, pagination.MapPage(pagination.NewPage(users, params, userCursor), newUserResponse))
 }
@@ -0,0 +1,363 @@
+package admin
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
+	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"github.com/DMaryanskiy/bookshare-api/internal/rbac"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errUserNotFound     = errors.New("user not found")
+	errLastAdmin        = errors.New("cannot remove the last active admin")
+	errAlreadySuspended = errors.New("user is already suspended")
+	errNotSuspended     = errors.New("user is not suspended")
+	errUnknownRole      = errors.New("unknown role")
+)
+
+type UpdateRoleRequest struct {
+	Role string `json:"role" binding:"required,max=64"`
+}
+
+type SuspendRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// activeAdmins selects users who can sign in and manage users. At least
+// one must remain, or nobody could undo a mistaken demotion.
+func activeAdmins(tx *gorm.DB) *gorm.DB {
+	return tx.Table("auth.users").
+		Where("suspended_at IS NULL").
+		Where("role IN (SELECT role FROM auth.role_permissions WHERE permission = ?)", rbac.PermUsersManage)
+}
+
+// updateUser loads the user named by the :id parameter under a row lock and
+// runs change on it in the same transaction. When the change takes away
+// admin rights, every active admin row is locked first so that two admins
+// cannot demote each other at the same time.
+func (h *Handler) updateUser(c *gin.Context, revokesAdmin bool, change func(tx *gorm.DB, user *models.User) error) (models.User, error) {
+	var user models.User
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return user, errUserNotFound
+	}
+
+	err = h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		var admins []uuid.UUID
+		if revokesAdmin {
+			if err := activeAdmins(tx).
+				Clauses(clause.Locking{Strength: "UPDATE"}).
+				Pluck("id", &admins).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Table("auth.users").
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&user, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errUserNotFound
+			}
+			return err
+		}
+
+		if revokesAdmin && len(admins) <= 1 && slices.Contains(admins, user.ID) {
+			return errLastAdmin
+		}
+
+		return change(tx, &user)
+	})
+	return user, err
+}
+
+// respondUpdateError maps updateUser errors to responses, auditing refusals
+// that protect the last admin.
+func (h *Handler) respondUpdateError(c *gin.Context, action audit.Action, err error) {
+	switch {
+	case errors.Is(err, errUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errLastAdmin):
+		h.Audit.Log(actorID(c), audit.ActionAdminActionFailed, targetMeta(c, c.Param("id")).
+			Failure("last_admin").
+			With("attempted", string(action)))
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, errAlreadySuspended), errors.Is(err, errNotSuspended):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, errUnknownRole):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not update user"})
+	}
+}
+
+// actorID is the admin making the request, as authenticated by
+// JWTAuthMiddleware and checked by RequirePermission.
+func actorID(c *gin.Context) uuid.UUID {
+	id, _ := uuid.Parse(c.GetString("user_id"))
+	return id
+}
+
+func targetMeta(c *gin.Context, targetID string) audit.Meta {
+	return audit.Request(c).With("target_user_id", targetID)
+}
+
+// GetUser godoc
+// @Summary      Get a user
+// @Description  Retrieves a single user by ID
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      string  true  "User ID"
+// @Success      200  {object}  UserResponse  "The user"
+// @Failure      404  {object}  map[string]string  "User not found"
+// @Router       /admin/users/{id} [get]
+func (h *Handler) GetUser(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": errUserNotFound.Error()})
+		return
+	}
+
+	var user models.User
+	if err := h.DB.Table("auth.users").First(&user, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": errUserNotFound.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not retrieve user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// UpdateUserRole godoc
+// @Summary      Change a user's role
+// @Description  Assigns one of the roles from /admin/roles. The user's access tokens are revoked so the new role applies from their next refresh. The last active user with users:manage cannot lose it.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id    path      string             true  "User ID"
+// @Param        role  body      UpdateRoleRequest  true  "New role"
+// @Success      200  {object}  UserResponse  "The updated user"
+// @Failure      400  {object}  map[string]string  "Unknown role"
+// @Failure      404  {object}  map[string]string  "User not found"
+// @Failure      409  {object}  map[string]string  "Would remove the last active admin"
+// @Failure      500  {object}  map[string]string  "Could not update user"
+// @Router       /admin/users/{id}/role [patch]
+func (h *Handler) UpdateUserRole(c *gin.Context) {
+	var req UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role is required"})
+		return
+	}
+
+	// A role without permissions still yields one empty row; no rows means
+	// there is no such role
+	var grants []string
+	if err := h.DB.Table("auth.roles r").
+		Joins("LEFT JOIN auth.role_permissions rp ON rp.role = r.name").
+		Where("r.name = ?", req.Role).
+		Pluck("COALESCE(rp.permission, '')", &grants).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not update user"})
+		return
+	}
+	if len(grants) == 0 {
+		h.respondUpdateError(c, audit.ActionUserRoleChanged, errUnknownRole)
+		return
+	}
+	revokesAdmin := !slices.Contains(grants, string(rbac.PermUsersManage))
+
+	var previous string
+	user, err := h.updateUser(c, revokesAdmin, func(tx *gorm.DB, user *models.User) error {
+		previous = user.Role
+		user.Role = req.Role
+		return tx.Table("auth.users").Where("id = ?", user.ID).Update("role", req.Role).Error
+	})
+	if err != nil {
+		h.respondUpdateError(c, audit.ActionUserRoleChanged, err)
+		return
+	}
+
+	if previous != user.Role {
+		// Access tokens carry the role; the user picks up the new one on
+		// their next refresh
+		if err := h.Denylist.RevokeUserTokens(c, user.ID.String()); err != nil {
+			log.Printf("Failed to deny access tokens of user %s after role change: %v", user.ID, err)
+		}
+
+		h.Audit.Log(actorID(c), audit.ActionUserRoleChanged, targetMeta(c, user.ID.String()).
+			With("from", previous).
+			With("to", user.Role))
+	}
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// SuspendUser godoc
+// @Summary      Suspend a user
+// @Description  Blocks the user from logging in, ends all their sessions and rejects their access tokens until unsuspended. The last active admin cannot be suspended.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id      path      string          true   "User ID"
+// @Param        reason  body      SuspendRequest  false  "Reason recorded in the audit log"
+// @Success      200  {object}  UserResponse  "The suspended user"
+// @Failure      404  {object}  map[string]string  "User not found"
+// @Failure      409  {object}  map[string]string  "Already suspended, or the last active admin"
+// @Failure      500  {object}  map[string]string  "Could not suspend user"
+// @Router       /admin/users/{id}/suspend [post]
+func (h *Handler) SuspendUser(c *gin.Context) {
+	var req SuspendRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid suspend input"})
+			return
+		}
+	}
+
+	user, err := h.updateUser(c, true, func(tx *gorm.DB, user *models.User) error {
+		if user.SuspendedAt != nil {
+			return errAlreadySuspended
+		}
+		now := time.Now()
+		user.SuspendedAt = &now
+		return tx.Table("auth.users").Where("id = ?", user.ID).Update("suspended_at", now).Error
+	})
+	if err != nil {
+		h.respondUpdateError(c, audit.ActionUserSuspended, err)
+		return
+	}
+
+	userID := user.ID.String()
+	if err := h.Denylist.SuspendUser(c, userID); err != nil {
+		log.Printf("Failed to deny access tokens of suspended user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "user suspended but access tokens could not be revoked"})
+		return
+	}
+	if err := h.TokenStore.DeleteUserRefreshTokens(c, userID); err != nil {
+		log.Printf("Failed to delete sessions of suspended user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "user suspended but sessions could not be revoked"})
+		return
+	}
+
+	meta := targetMeta(c, userID)
+	if req.Reason != "" {
+		meta = meta.With("reason", req.Reason)
+	}
+	h.Audit.Log(actorID(c), audit.ActionUserSuspended, meta)
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// UnsuspendUser godoc
+// @Summary      Lift a suspension
+// @Description  Allows a suspended user to log in again
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      string  true  "User ID"
+// @Success      200  {object}  UserResponse  "The user"
+// @Failure      404  {object}  map[string]string  "User not found"
+// @Failure      409  {object}  map[string]string  "User is not suspended"
+// @Failure      500  {object}  map[string]string  "Could not unsuspend user"
+// @Router       /admin/users/{id}/unsuspend [post]
+func (h *Handler) UnsuspendUser(c *gin.Context) {
+	user, err := h.updateUser(c, false, func(tx *gorm.DB, user *models.User) error {
+		if user.SuspendedAt == nil {
+			return errNotSuspended
+		}
+		user.SuspendedAt = nil
+		return tx.Table("auth.users").Where("id = ?", user.ID).Update("suspended_at", nil).Error
+	})
+	if err != nil {
+		h.respondUpdateError(c, audit.ActionUserUnsuspended, err)
+		return
+	}
+
+	if err := h.Denylist.UnsuspendUser(c, user.ID.String()); err != nil {
+		log.Printf("Failed to clear suspension marker of user %s: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "user unsuspended but access could not be restored"})
+		return
+	}
+
+	h.Audit.Log(actorID(c), audit.ActionUserUnsuspended, targetMeta(c, user.ID.String()))
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// VerifyUser godoc
+// @Summary      Mark a user as verified
+// @Description  Verifies the user's email without the verification link; outstanding verification tokens are discarded
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      string  true  "User ID"
+// @Success      200  {object}  UserResponse  "The verified user"
+// @Failure      404  {object}  map[string]string  "User not found"
+// @Failure      500  {object}  map[string]string  "Could not verify user"
+// @Router       /admin/users/{id}/verify [post]
+func (h *Handler) VerifyUser(c *gin.Context) {
+	var wasVerified bool
+	user, err := h.updateUser(c, false, func(tx *gorm.DB, user *models.User) error {
+		wasVerified = user.IsVerified
+		user.IsVerified = true
+		if err := tx.Table("auth.users").Where("id = ?", user.ID).Update("is_verified", true).Error; err != nil {
+			return err
+		}
+		return tx.Exec("DELETE FROM auth.verification_tokens WHERE user_id = ?", user.ID).Error
+	})
+	if err != nil {
+		h.respondUpdateError(c, audit.ActionUserForceVerified, err)
+		return
+	}
+
+	if !wasVerified {
+		h.Audit.Log(actorID(c), audit.ActionUserForceVerified, targetMeta(c, user.ID.String()))
+	}
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// DeleteUser godoc
+// @Summary      Delete a user
+// @Description  Permanently deletes the user with their books, loans and sessions. Audit log entries are kept. The last active admin cannot be deleted.
+// @Tags         admin
+// @Param        id   path      string  true  "User ID"
+// @Success      204  "User deleted"
+// @Failure      404  {object}  map[string]string  "User not found"
+// @Failure      409  {object}  map[string]string  "Would remove the last active admin"
+// @Failure      500  {object}  map[string]string  "Could not delete user"
+// @Router       /admin/users/{id} [delete]
+func (h *Handler) DeleteUser(c *gin.Context) {
+	user, err := h.updateUser(c, true, func(tx *gorm.DB, user *models.User) error {
+		return tx.Table("auth.users").Delete(&models.User{}, "id = ?", user.ID).Error
+	})
+	if err != nil {
+		h.respondUpdateError(c, audit.ActionUserDeleted, err)
+		return
+	}
+
+	// The account is gone either way; outstanding tokens just stop working
+	// sooner
+	userID := user.ID.String()
+	if err := h.TokenStore.DeleteUserRefreshTokens(c, userID); err != nil {
+		log.Printf("Failed to delete sessions of deleted user %s: %v", userID, err)
+	}
+	if err := h.Denylist.RevokeUserTokens(c, userID); err != nil {
+		log.Printf("Failed to deny access tokens of deleted user %s: %v", userID, err)
+	}
+
+	h.Audit.Log(actorID(c), audit.ActionUserDeleted, targetMeta(c, userID).
+		With("email", user.Email).
+		With("role", user.Role))
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,50 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
+	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// RemoveBook godoc
+// @Summary      Remove a book
+// @Description  Deletes any user's book, e.g. one that breaks the sharing rules
+// @Tags         admin
+// @Param        id   path      string  true  "Book ID"
+// @Success      204  "Book removed"
+// @Failure      400  {object}  map[string]string  "Invalid book ID"
+// @Failure      404  {object}  map[string]string  "Book not found"
+// @Failure      500  {object}  map[string]string  "Could not remove book"
+// @Router       /admin/books/{id} [delete]
+func (h *Handler) RemoveBook(c *gin.Context) {
+	bookID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid book id"})
+		return
+	}
+
+	var removed []models.Book
+	res := h.DB.WithContext(c).Table("books.books").
+		Clauses(clause.Returning{}).
+		Where("id = ?", bookID).
+		Delete(&removed)
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not remove book"})
+		return
+	}
+	if len(removed) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
+		return
+	}
+
+	h.Audit.Log(actorID(c), audit.ActionBookRemoved, audit.Request(c).
+		With("book_id", bookID.String()).
+		With("owner_id", removed[0].UserID.String()).
+		With("title", removed[0].Title))
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,305 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
+	"github.com/DMaryanskiy/bookshare-api/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type RateLimitRuleResponse struct {
+	Pattern    string         `json:"pattern"`
+	Limit      int            `json:"limit"`
+	Window     string         `json:"window"`
+	RoleLimits map[string]int `json:"role_limits,omitempty"`
+}
+
+type RateLimitOverrideResponse struct {
+	Client string `json:"client"`
+	Limit  int    `json:"limit"`
+}
+
+type RateLimitUsageResponse struct {
+	Pattern   string    `json:"pattern"`
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	ResetAt   time.Time `json:"reset_at"`
+}
+
+type SetOverrideRequest struct {
+	Limit *int `json:"limit" binding:"required,min=0"`
+}
+
+type CreateAPIKeyRequest struct {
+	ID string `json:"id" binding:"required"`
+}
+
+// APIKeyResponse carries Key only when the key is created.
+type APIKeyResponse struct {
+	ID     string `json:"id"`
+	Client string `json:"client"`
+	Key    string `json:"key,omitempty"`
+}
+
+// ListRateLimitRules godoc
+// @Summary      List rate limit rules
+// @Description  Returns the rules currently applied, in matching order, as last loaded from the rules file
+// @Tags         admin
+// @Produce      json
+// @Success      200  {array}   RateLimitRuleResponse  "Rules"
+// @Router       /admin/rate-limits/rules [get]
+func (h *Handler) ListRateLimitRules(c *gin.Context) {
+	rules := h.Limiter.Rules()
+
+	resp := make([]RateLimitRuleResponse, 0, len(rules))
+	for _, r := range rules {
+		resp = append(resp, RateLimitRuleResponse{
+			Pattern:    r.Pattern,
+			Limit:      r.Limit,
+			Window:     r.Window.String(),
+			RoleLimits: r.RoleLimits,
+		})
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// ListRateLimitOverrides godoc
+// @Summary      List rate limit overrides
+// @Description  Lists users and API keys whose limit replaces the rules' limits
+// @Tags         admin
+// @Produce      json
+// @Success      200  {array}   RateLimitOverrideResponse  "Overrides"
+// @Failure      500  {object}  map[string]string  "Could not retrieve overrides"
+// @Router       /admin/rate-limits/overrides [get]
+func (h *Handler) ListRateLimitOverrides(c *gin.Context) {
+	overrides, err := h.Limiter.Overrides(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not retrieve overrides"})
+		return
+	}
+
+	resp := make([]RateLimitOverrideResponse, 0, len(overrides))
+	for client, limit := range overrides {
+		resp = append(resp, RateLimitOverrideResponse{Client: client, Limit: limit})
+	}
+	sort.Slice(resp, func(i, j int) bool { return resp[i].Client < resp[j].Client })
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// SetRateLimitOverride godoc
+// @Summary      Override a client's rate limit
+// @Description  Replaces the limit of every rule for a user (user:<id>) or an issued API key (apikey:<id>). A limit of 0 blocks the client.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        client    path      string              true  "Client, e.g. user:<id>"
+// @Param        override  body      SetOverrideRequest  true  "Limit"
+// @Success      200  {object}  RateLimitOverrideResponse  "The override"
+// @Failure      400  {object}  map[string]string  "Invalid client or limit"
+// @Failure      500  {object}  map[string]string  "Could not set override"
+// @Router       /admin/rate-limits/overrides/{client} [put]
+func (h *Handler) SetRateLimitOverride(c *gin.Context) {
+	var req SetOverrideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be 0 or more"})
+		return
+	}
+	client := c.Param("client")
+
+	if err := h.Limiter.SetOverride(c, client, *req.Limit); err != nil {
+		if errors.Is(err, middleware.ErrInvalidOverride) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not set override"})
+		return
+	}
+
+	h.Audit.Log(actorID(c), audit.ActionRateLimitOverrideSet, audit.Request(c).
+		With("client", client).
+		With("limit", *req.Limit))
+
+	c.JSON(http.StatusOK, RateLimitOverrideResponse{Client: client, Limit: *req.Limit})
+}
+
+// DeleteRateLimitOverride godoc
+// @Summary      Remove a rate limit override
+// @Description  Makes the rules' limits apply to the client again
+// @Tags         admin
+// @Param        client  path      string  true  "Client, e.g. user:<id>"
+// @Success      204  "Override removed"
+// @Failure      404  {object}  map[string]string  "No override for the client"
+// @Failure      500  {object}  map[string]string  "Could not remove override"
+// @Router       /admin/rate-limits/overrides/{client} [delete]
+func (h *Handler) DeleteRateLimitOverride(c *gin.Context) {
+	client := c.Param("client")
+
+	found, err := h.Limiter.DeleteOverride(c, client)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not remove override"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no override for client"})
+		return
+	}
+
+	h.Audit.Log(actorID(c), audit.ActionRateLimitOverrideRemoved, audit.Request(c).With("client", client))
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetRateLimitUsage godoc
+// @Summary      Inspect a client's rate limit counters
+// @Description  Shows how much of each rule's limit a client (user:<id>, apikey:<id> or ip:<address>) has used, against the limit that was applied to it. Rules with the full limit available are left out.
+// @Tags         admin
+// @Produce      json
+// @Param        client  path      string  true  "Client, e.g. ip:203.0.113.7"
+// @Success      200  {array}   RateLimitUsageResponse  "Partly used buckets"
+// @Failure      400  {object}  map[string]string  "Invalid client"
+// @Failure      500  {object}  map[string]string  "Could not read counters"
+// @Router       /admin/rate-limits/clients/{client} [get]
+func (h *Handler) GetRateLimitUsage(c *gin.Context) {
+	usage, err := h.Limiter.Usage(c, c.Param("client"))
+	if err != nil {
+		if errors.Is(err, middleware.ErrInvalidClient) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not read counters"})
+		return
+	}
+
+	now := time.Now().UTC()
+	resp := make([]RateLimitUsageResponse, 0, len(usage))
+	for _, u := range usage {
+		resp = append(resp, RateLimitUsageResponse{
+			Pattern:   u.Pattern,
+			Limit:     u.Limit,
+			Remaining: u.Remaining,
+			ResetAt:   now.Add(u.ResetAfter),
+		})
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// ResetRateLimitUsage godoc
+// @Summary      Reset a client's rate limit counters
+// @Description  Gives the client the full limit of every rule again
+// @Tags         admin
+// @Param        client  path      string  true  "Client, e.g. user:<id>"
+// @Success      204  "Counters reset"
+// @Failure      400  {object}  map[string]string  "Invalid client"
+// @Failure      500  {object}  map[string]string  "Could not reset counters"
+// @Router       /admin/rate-limits/clients/{client} [delete]
+func (h *Handler) ResetRateLimitUsage(c *gin.Context) {
+	client := c.Param("client")
+
+	if err := h.Limiter.Reset(c, client); err != nil {
+		if errors.Is(err, middleware.ErrInvalidClient) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not reset counters"})
+		return
+	}
+
+	h.Audit.Log(actorID(c), audit.ActionRateLimitReset, audit.Request(c).With("client", client))
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListRateLimitAPIKeys godoc
+// @Summary      List rate limit API keys
+// @Description  Lists the ids of issued API keys. The keys themselves are not stored.
+// @Tags         admin
+// @Produce      json
+// @Success      200  {array}   APIKeyResponse  "API keys"
+// @Failure      500  {object}  map[string]string  "Could not retrieve API keys"
+// @Router       /admin/rate-limits/api-keys [get]
+func (h *Handler) ListRateLimitAPIKeys(c *gin.Context) {
+	ids, err := h.Limiter.APIKeys(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not retrieve api keys"})
+		return
+	}
+
+	resp := make([]APIKeyResponse, 0, len(ids))
+	for _, id := range ids {
+		resp = append(resp, APIKeyResponse{ID: id, Client: middleware.ClientAPIKey + id})
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// CreateRateLimitAPIKey godoc
+// @Summary      Issue a rate limit API key
+// @Description  Issues a key that requests without an access token send as X-API-Key to be counted as apikey:<id> instead of by IP. The key is only shown in this response.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        key  body      CreateAPIKeyRequest  true  "Key id"
+// @Success      201  {object}  APIKeyResponse  "The key"
+// @Failure      400  {object}  map[string]string  "Invalid id"
+// @Failure      409  {object}  map[string]string  "Id already in use"
+// @Failure      500  {object}  map[string]string  "Could not create API key"
+// @Router       /admin/rate-limits/api-keys [post]
+func (h *Handler) CreateRateLimitAPIKey(c *gin.Context) {
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required"})
+		return
+	}
+
+	key, err := h.Limiter.CreateAPIKey(c, req.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, middleware.ErrInvalidAPIKeyID):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, middleware.ErrAPIKeyExists):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create api key"})
+		}
+		return
+	}
+
+	client := middleware.ClientAPIKey + req.ID
+	h.Audit.Log(actorID(c), audit.ActionRateLimitAPIKeyCreated, audit.Request(c).With("client", client))
+
+	c.JSON(http.StatusCreated, APIKeyResponse{ID: req.ID, Client: client, Key: key})
+}
+
+// RevokeRateLimitAPIKey godoc
+// @Summary      Revoke a rate limit API key
+// @Description  Requests sending the key are counted by IP again, and the key's override is removed
+// @Tags         admin
+// @Param        id  path  string  true  "Key id"
+// @Success      204  "Key revoked"
+// @Failure      404  {object}  map[string]string  "No such key"
+// @Failure      500  {object}  map[string]string  "Could not revoke API key"
+// @Router       /admin/rate-limits/api-keys/{id} [delete]
+func (h *Handler) RevokeRateLimitAPIKey(c *gin.Context) {
+	id := c.Param("id")
+
+	found, err := h.Limiter.RevokeAPIKey(c, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not revoke api key"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+		return
+	}
+
+	h.Audit.Log(actorID(c), audit.ActionRateLimitAPIKeyRevoked, audit.Request(c).With("client", middleware.ClientAPIKey+id))
+
+	c.Status(http.StatusNoContent)
+}
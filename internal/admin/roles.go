@@ -0,0 +1,361 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
+	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"github.com/DMaryanskiy/bookshare-api/internal/rbac"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errRoleNotFound      = errors.New("role not found")
+	errRoleExists        = errors.New("role already exists")
+	errRoleInUse         = errors.New("role is assigned to users")
+	errBuiltinRole       = errors.New("built-in roles cannot be deleted")
+	errUnknownPermission = errors.New("unknown permission")
+)
+
+type RoleResponse struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Permissions []rbac.Permission `json:"permissions"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+type PermissionResponse struct {
+	Name        rbac.Permission `json:"name"`
+	Description string          `json:"description"`
+}
+
+type CreateRoleRequest struct {
+	Name        string            `json:"name" binding:"required,max=64,excludesall=:/ "`
+	Description string            `json:"description" binding:"max=500"`
+	Permissions []rbac.Permission `json:"permissions"`
+}
+
+type SetPermissionsRequest struct {
+	Permissions []rbac.Permission `json:"permissions" binding:"required"`
+}
+
+// loadRoles returns roles with their permissions, optionally only the
+// named ones.
+func loadRoles(tx *gorm.DB, names ...string) ([]RoleResponse, error) {
+	query := tx.Table("auth.roles").Order("name")
+	if len(names) > 0 {
+		query = query.Where("name IN ?", names)
+	}
+	var roles []models.Role
+	if err := query.Find(&roles).Error; err != nil {
+		return nil, err
+	}
+
+	var grants []models.RolePermission
+	grantQuery := tx.Table("auth.role_permissions").Order("permission")
+	if len(names) > 0 {
+		grantQuery = grantQuery.Where("role IN ?", names)
+	}
+	if err := grantQuery.Find(&grants).Error; err != nil {
+		return nil, err
+	}
+
+	byRole := make(map[string][]rbac.Permission)
+	for _, g := range grants {
+		byRole[g.Role] = append(byRole[g.Role], rbac.Permission(g.Permission))
+	}
+
+	resp := make([]RoleResponse, 0, len(roles))
+	for _, r := range roles {
+		perms := byRole[r.Name]
+		if perms == nil {
+			perms = []rbac.Permission{}
+		}
+		resp = append(resp, RoleResponse{Name: r.Name, Description: r.Description, Permissions: perms, CreatedAt: r.CreatedAt})
+	}
+	return resp, nil
+}
+
+// setPermissions replaces the role's permissions inside tx. Unknown
+// permission names are rejected rather than left to the foreign key.
+func setPermissions(tx *gorm.DB, role string, perms []rbac.Permission) error {
+	perms = slices.Compact(slices.Sorted(slices.Values(perms)))
+
+	if len(perms) > 0 {
+		var known int64
+		if err := tx.Table("auth.permissions").Where("name IN ?", perms).Count(&known).Error; err != nil {
+			return err
+		}
+		if known != int64(len(perms)) {
+			return errUnknownPermission
+		}
+	}
+
+	if err := tx.Table("auth.role_permissions").Where("role = ?", role).Delete(&models.RolePermission{}).Error; err != nil {
+		return err
+	}
+	if len(perms) == 0 {
+		return nil
+	}
+
+	rows := make([]models.RolePermission, len(perms))
+	for i, p := range perms {
+		rows[i] = models.RolePermission{Role: role, Permission: string(p)}
+	}
+	return tx.Table("auth.role_permissions").Create(&rows).Error
+}
+
+func (h *Handler) respondRoleError(c *gin.Context, action audit.Action, err error) {
+	switch {
+	case errors.Is(err, errRoleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errUnknownPermission):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, errRoleExists), errors.Is(err, errRoleInUse), errors.Is(err, errBuiltinRole):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, errLastAdmin):
+		h.Audit.Log(actorID(c), audit.ActionAdminActionFailed, audit.Request(c).
+			Failure("last_admin").
+			With("role", c.Param("name")).
+			With("attempted", string(action)))
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not update role"})
+	}
+}
+
+// ListRoles godoc
+// @Summary      List roles
+// @Description  Lists every role with the permissions it grants
+// @Tags         admin
+// @Produce      json
+// @Success      200  {array}   RoleResponse  "Roles"
+// @Failure      500  {object}  map[string]string  "Could not retrieve roles"
+// @Router       /admin/roles [get]
+func (h *Handler) ListRoles(c *gin.Context) {
+	roles, err := loadRoles(h.DB.WithContext(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not retrieve roles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// ListPermissions godoc
+// @Summary      List permissions
+// @Description  Lists every permission that can be granted to a role
+// @Tags         admin
+// @Produce      json
+// @Success      200  {array}   PermissionResponse  "Permissions"
+// @Failure      500  {object}  map[string]string  "Could not retrieve permissions"
+// @Router       /admin/permissions [get]
+func (h *Handler) ListPermissions(c *gin.Context) {
+	var perms []models.Permission
+	if err := h.DB.WithContext(c).Table("auth.permissions").Order("name").Find(&perms).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not retrieve permissions"})
+		return
+	}
+
+	resp := make([]PermissionResponse, 0, len(perms))
+	for _, p := range perms {
+		resp = append(resp, PermissionResponse{Name: rbac.Permission(p.Name), Description: p.Description})
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// CreateRole godoc
+// @Summary      Create a role
+// @Description  Creates a role granting the given permissions
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        role  body      CreateRoleRequest  true  "Role"
+// @Success      201  {object}  RoleResponse  "The new role"
+// @Failure      400  {object}  map[string]string  "Invalid input or unknown permission"
+// @Failure      409  {object}  map[string]string  "Role already exists"
+// @Failure      500  {object}  map[string]string  "Could not create role"
+// @Router       /admin/roles [post]
+func (h *Handler) CreateRole(c *gin.Context) {
+	var req CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid role input"})
+		return
+	}
+
+	var role RoleResponse
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		created := tx.Table("auth.roles").
+			Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.Role{Name: req.Name, Description: req.Description})
+		if created.Error != nil {
+			return created.Error
+		}
+		if created.RowsAffected == 0 {
+			return errRoleExists
+		}
+
+		if err := setPermissions(tx, req.Name, req.Permissions); err != nil {
+			return err
+		}
+
+		roles, err := loadRoles(tx, req.Name)
+		if err != nil {
+			return err
+		}
+		role = roles[0]
+		return nil
+	})
+	if err != nil {
+		h.respondRoleError(c, audit.ActionRoleCreated, err)
+		return
+	}
+	h.Roles.Invalidate()
+
+	h.Audit.Log(actorID(c), audit.ActionRoleCreated, audit.Request(c).
+		With("role", role.Name).
+		With("permissions", role.Permissions))
+
+	c.JSON(http.StatusCreated, role)
+}
+
+// SetRolePermissions godoc
+// @Summary      Replace a role's permissions
+// @Description  Sets the complete list of permissions a role grants. Taking users:manage away from the last active users holding it is refused.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        name         path      string                 true  "Role name"
+// @Param        permissions  body      SetPermissionsRequest  true  "Permissions"
+// @Success      200  {object}  RoleResponse  "The updated role"
+// @Failure      400  {object}  map[string]string  "Invalid input or unknown permission"
+// @Failure      404  {object}  map[string]string  "Role not found"
+// @Failure      409  {object}  map[string]string  "Would remove the last active admin"
+// @Failure      500  {object}  map[string]string  "Could not update role"
+// @Router       /admin/roles/{name}/permissions [put]
+func (h *Handler) SetRolePermissions(c *gin.Context) {
+	var req SetPermissionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "permissions are required"})
+		return
+	}
+	name := c.Param("name")
+
+	var before, after RoleResponse
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		// Same lock order as updateUser: admins first, then the role
+		var admins []models.User
+		if err := activeAdmins(tx).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "role").
+			Find(&admins).Error; err != nil {
+			return err
+		}
+
+		var role models.Role
+		if err := tx.Table("auth.roles").
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&role, "name = ?", name).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errRoleNotFound
+			}
+			return err
+		}
+
+		roles, err := loadRoles(tx, name)
+		if err != nil {
+			return err
+		}
+		before = roles[0]
+
+		if !slices.Contains(req.Permissions, rbac.PermUsersManage) {
+			remaining := slices.DeleteFunc(admins, func(u models.User) bool { return u.Role == name })
+			if len(remaining) == 0 && len(admins) > 0 {
+				return errLastAdmin
+			}
+		}
+
+		if err := setPermissions(tx, name, req.Permissions); err != nil {
+			return err
+		}
+
+		roles, err = loadRoles(tx, name)
+		if err != nil {
+			return err
+		}
+		after = roles[0]
+		return nil
+	})
+	if err != nil {
+		h.respondRoleError(c, audit.ActionRolePermissionsChanged, err)
+		return
+	}
+	h.Roles.Invalidate()
+
+	if !slices.Equal(before.Permissions, after.Permissions) {
+		h.Audit.Log(actorID(c), audit.ActionRolePermissionsChanged, audit.Request(c).
+			With("role", name).
+			With("from", before.Permissions).
+			With("to", after.Permissions))
+	}
+
+	c.JSON(http.StatusOK, after)
+}
+
+// DeleteRole godoc
+// @Summary      Delete a role
+// @Description  Deletes a role no user is assigned to. The built-in user and admin roles cannot be deleted.
+// @Tags         admin
+// @Param        name  path      string  true  "Role name"
+// @Success      204  "Role deleted"
+// @Failure      404  {object}  map[string]string  "Role not found"
+// @Failure      409  {object}  map[string]string  "Built-in role, or assigned to users"
+// @Failure      500  {object}  map[string]string  "Could not delete role"
+// @Router       /admin/roles/{name} [delete]
+func (h *Handler) DeleteRole(c *gin.Context) {
+	name := c.Param("name")
+	if name == models.RoleUser || name == models.RoleAdmin {
+		h.respondRoleError(c, audit.ActionRoleDeleted, errBuiltinRole)
+		return
+	}
+
+	var deleted RoleResponse
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		roles, err := loadRoles(tx, name)
+		if err != nil {
+			return err
+		}
+		if len(roles) == 0 {
+			return errRoleNotFound
+		}
+		deleted = roles[0]
+
+		var assigned int64
+		if err := tx.Table("auth.users").Where("role = ?", name).Count(&assigned).Error; err != nil {
+			return err
+		}
+		if assigned > 0 {
+			return errRoleInUse
+		}
+
+		// A user assigned concurrently makes this fail on the foreign key
+		return tx.Table("auth.roles").Delete(&models.Role{}, "name = ?", name).Error
+	})
+	if err != nil {
+		h.respondRoleError(c, audit.ActionRoleDeleted, err)
+		return
+	}
+	h.Roles.Invalidate()
+
+	h.Audit.Log(actorID(c), audit.ActionRoleDeleted, audit.Request(c).
+		With("role", name).
+		With("permissions", deleted.Permissions))
+
+	c.Status(http.StatusNoContent)
+}
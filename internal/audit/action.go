@@ -0,0 +1,65 @@
+package audit
+
+// Action identifies what happened in an audit log entry. The string values
+// are stored in logs.audit_logs.action and must not change once released.
+type Action string
+
+const (
+	// Registration and email verification
+	ActionRegistrationSuccess     Action = "registration_success"
+	ActionRegistrationFailed      Action = "registration_failed"
+	ActionEmailVerified           Action = "email_verified"
+	ActionEmailVerificationFailed Action = "email_verification_failed"
+	ActionVerificationResent      Action = "verification_resent"
+
+	// Login
+	ActionLoginSuccess     Action = "login_success"
+	ActionLoginFailed      Action = "login_failed"
+	ActionLoginMFARequired Action = "login_mfa_required"
+	ActionAccountLocked    Action = "account_locked"
+	ActionRecoveryCodeUsed Action = "recovery_code_used"
+
+	// Tokens and sessions
+	ActionTokenRefreshed            Action = "token_refreshed"
+	ActionTokenRefreshFailed        Action = "token_refresh_failed"
+	ActionRefreshTokenReuseDetected Action = "refresh_token_reuse_detected"
+	ActionLogout                    Action = "logout"
+	ActionSessionRevoked            Action = "session_revoked"
+	ActionSessionsRevoked           Action = "sessions_revoked"
+
+	// Password reset
+	ActionPasswordResetRequested Action = "password_reset_requested"
+	ActionPasswordReset          Action = "password_reset"
+	ActionPasswordResetFailed    Action = "password_reset_failed"
+
+	// Two-factor authentication
+	ActionTOTPEnrollmentStarted Action = "totp_enrollment_started"
+	ActionTOTPEnabled           Action = "totp_enabled"
+	ActionTOTPDisabled          Action = "totp_disabled"
+	ActionTOTPChangeFailed      Action = "totp_change_failed"
+
+	// Admin
+	ActionAdminAccessGranted       Action = "admin_access_granted"
+	ActionAdminAccessDenied        Action = "admin_access_denied"
+	ActionUserRoleChanged          Action = "user_role_changed"
+	ActionUserSuspended            Action = "user_suspended"
+	ActionUserUnsuspended          Action = "user_unsuspended"
+	ActionUserForceVerified        Action = "user_force_verified"
+	ActionUserDeleted              Action = "user_deleted"
+	ActionAdminActionFailed        Action = "admin_action_failed"
+	ActionRoleCreated              Action = "role_created"
+	ActionRolePermissionsChanged   Action = "role_permissions_changed"
+	ActionRoleDeleted              Action = "role_deleted"
+	ActionBookRemoved              Action = "book_removed"
+	ActionRateLimitOverrideSet     Action = "rate_limit_override_set"
+	ActionRateLimitOverrideRemoved Action = "rate_limit_override_removed"
+	ActionRateLimitReset           Action = "rate_limit_reset"
+	ActionRateLimitAPIKeyCreated   Action = "rate_limit_api_key_created"
+	ActionRateLimitAPIKeyRevoked   Action = "rate_limit_api_key_revoked"
+
+	// Lending
+	ActionLoanRequested Action = "loan_requested"
+	ActionLoanApproved  Action = "loan_approved"
+	ActionLoanRejected  Action = "loan_rejected"
+	ActionLoanReturned  Action = "loan_returned"
+)
@@ -0,0 +1,72 @@
+package audit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
+	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func chainEntry(metadata string) models.AuditLog {
+	userID := uuid.New()
+	return models.AuditLog{
+		ID:        uuid.New(),
+		UserID:    &userID,
+		Action:    string(audit.ActionLoginSuccess),
+		Metadata:  &metadata,
+		CreatedAt: time.Date(2025, 3, 4, 5, 6, 7, 123456789, time.UTC),
+		Seq:       42,
+		PrevHash:  "abc",
+	}
+}
+
+func TestChainHash_IgnoresJSONLayout(t *testing.T) {
+	written := chainEntry(`{"outcome":"success","ip":"10.0.0.1","details":{"method":"totp","attempt":3}}`)
+	hash, err := audit.ChainHash(written)
+	require.NoError(t, err)
+	require.Len(t, hash, 64)
+
+	// JSONB as Postgres returns it, timestamp rounded to microseconds
+	read := written
+	stored := `{"ip": "10.0.0.1", "details": {"attempt": 3, "method": "totp"}, "outcome": "success"}`
+	read.Metadata = &stored
+	read.CreatedAt = time.Date(2025, 3, 4, 8, 6, 7, 123456000, time.FixedZone("MSK", 3*3600))
+
+	rehash, err := audit.ChainHash(read)
+	require.NoError(t, err)
+	require.Equal(t, hash, rehash)
+}
+
+func TestChainHash_CoversEveryField(t *testing.T) {
+	base := chainEntry(`{"outcome":"success"}`)
+	hash, err := audit.ChainHash(base)
+	require.NoError(t, err)
+
+	otherMeta := `{"outcome":"failure"}`
+	otherUser := uuid.New()
+	changes := map[string]func(*models.AuditLog){
+		"seq":        func(e *models.AuditLog) { e.Seq++ },
+		"id":         func(e *models.AuditLog) { e.ID = uuid.New() },
+		"user_id":    func(e *models.AuditLog) { e.UserID = &otherUser },
+		"no user":    func(e *models.AuditLog) { e.UserID = nil },
+		"action":     func(e *models.AuditLog) { e.Action = string(audit.ActionLoginFailed) },
+		"metadata":   func(e *models.AuditLog) { e.Metadata = &otherMeta },
+		"created_at": func(e *models.AuditLog) { e.CreatedAt = e.CreatedAt.Add(time.Microsecond) },
+		"prev_hash":  func(e *models.AuditLog) { e.PrevHash = "abd" },
+	}
+	for name, change := range changes {
+		e := base
+		change(&e)
+		changed, err := audit.ChainHash(e)
+		require.NoError(t, err)
+		require.NotEqual(t, hash, changed, name)
+	}
+}
+
+func TestChainHash_InvalidMetadata(t *testing.T) {
+	_, err := audit.ChainHash(chainEntry(`{"outcome":`))
+	require.Error(t, err)
+}
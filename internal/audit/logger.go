@@ -4,27 +4,57 @@ import (
 	"context"
 	"encoding/json"
 	"log"
+	"time"
 
-	"github.com/DMaryanskiy/bookshare-api/internal/db"
 	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
 	"github.com/google/uuid"
 )
 
-func Log(userID uuid.UUID, action string, meta any) {
-	metaStr := ""
-	if meta != nil {
-		if b, err := json.Marshal(meta); err == nil {
-			metaStr = string(b)
-		}
+// Logger records audit events, either through a Writer, which batches
+// them off the request path, or straight into a Store.
+type Logger struct {
+	writer *Writer
+	store  Store
+}
+
+// NewLogger returns a Logger that hands entries to w.
+func NewLogger(w *Writer) *Logger {
+	return &Logger{writer: w}
+}
+
+// NewSyncLogger returns a Logger that inserts every entry into store before
+// Log returns, which is what tests rely on.
+func NewSyncLogger(store Store) *Logger {
+	return &Logger{store: store}
+}
+
+// Log records an audit event. Pass uuid.Nil as userID when the actor is not
+// a known user (e.g. a login attempt for an unregistered email).
+func (l *Logger) Log(userID uuid.UUID, action Action, meta Meta) {
+	var metaStr *string
+	if b, err := json.Marshal(meta); err == nil {
+		s := string(b)
+		metaStr = &s
 	}
 
+	// ID and timestamp are fixed here so that a delayed or replayed insert
+	// keeps the original time and is written only once
 	entry := models.AuditLog{
-		UserID:   userID,
-		Action:   action,
-		Metadata: metaStr,
+		ID:        uuid.New(),
+		Action:    string(action),
+		Metadata:  metaStr,
+		CreatedAt: time.Now(),
+	}
+	if userID != uuid.Nil {
+		entry.UserID = &userID
+	}
+
+	if l.writer != nil {
+		_ = l.writer.Write(entry)
+		return
 	}
 
-	if err := db.DB.WithContext(context.Background()).Table("logs.audit_logs").Create(&entry).Error; err != nil {
+	if err := l.store.InsertAuditLogs(context.Background(), []models.AuditLog{entry}); err != nil {
 		log.Printf("Failed to write audit log: %v", err)
 	}
 }
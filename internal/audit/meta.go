@@ -0,0 +1,47 @@
+package audit
+
+import "github.com/gin-gonic/gin"
+
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeFailure Outcome = "failure"
+)
+
+// Meta is the structured metadata stored with every audit log entry.
+// Reason is a short snake_case code, not a user-facing message.
+type Meta struct {
+	IP        string         `json:"ip,omitempty"`
+	UserAgent string         `json:"user_agent,omitempty"`
+	Outcome   Outcome        `json:"outcome"`
+	Reason    string         `json:"reason,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+// Request starts a successful entry with the client details of the request.
+func Request(c *gin.Context) Meta {
+	return Meta{
+		IP:        c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+		Outcome:   OutcomeSuccess,
+	}
+}
+
+// Failure marks the entry as failed for the given reason.
+func (m Meta) Failure(reason string) Meta {
+	m.Outcome = OutcomeFailure
+	m.Reason = reason
+	return m
+}
+
+// With adds an action-specific detail.
+func (m Meta) With(key string, value any) Meta {
+	details := make(map[string]any, len(m.Details)+1)
+	for k, v := range m.Details {
+		details[k] = v
+	}
+	details[key] = value
+	m.Details = details
+	return m
+}
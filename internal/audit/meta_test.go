@@ -0,0 +1,41 @@
+package audit_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMeta_Builders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/login", nil)
+	c.Request.Header.Set("User-Agent", "meta-test")
+
+	base := audit.Request(c)
+	require.Equal(t, audit.OutcomeSuccess, base.Outcome)
+	require.Equal(t, "meta-test", base.UserAgent)
+	require.NotEmpty(t, base.IP)
+
+	failed := base.Failure("invalid_password").With("failures", 3)
+	require.Equal(t, audit.OutcomeFailure, failed.Outcome)
+	require.Equal(t, "invalid_password", failed.Reason)
+
+	// Builders return copies and leave the original untouched
+	require.Equal(t, audit.OutcomeSuccess, base.Outcome)
+	require.Nil(t, base.Details)
+
+	data, err := json.Marshal(failed)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"ip": "192.0.2.1",
+		"user_agent": "meta-test",
+		"outcome": "failure",
+		"reason": "invalid_password",
+		"details": {"failures": 3}
+	}`, string(data))
+}
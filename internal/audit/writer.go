@@ -0,0 +1,240 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"github.com/DMaryanskiy/bookshare-api/internal/task"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrWriterClosed = errors.New("audit writer closed")
+	ErrBufferFull   = errors.New("audit buffer full")
+)
+
+// Stats are published at /debug/vars under "audit_writer".
+var stats = expvar.NewMap("audit_writer")
+
+const (
+	statEnqueued    = "enqueued"
+	statWritten     = "written"
+	statSpilled     = "spilled"
+	statDropped     = "dropped"
+	statFlushErrors = "flush_errors"
+)
+
+// Store persists a batch of audit log entries.
+type Store interface {
+	InsertAuditLogs(ctx context.Context, entries []models.AuditLog) error
+}
+
+// Spiller hands entries the store could not take to the task queue, from
+// where the worker retries the insert. *distributor.TaskDistributor
+// implements it.
+type Spiller interface {
+	DistributeAuditLogs(ctx context.Context, payload task.PayloadWriteAuditLogs) error
+}
+
+// DBStore inserts into logs.audit_logs, extending the hash chain. Entries
+// carry their ID, so a batch replayed from the task queue is not written
+// twice.
+type DBStore struct {
+	DB *gorm.DB
+}
+
+func (s DBStore) InsertAuditLogs(ctx context.Context, entries []models.AuditLog) error {
+	return InsertAuditLogs(ctx, s.DB, entries)
+}
+
+type WriterConfig struct {
+	// BufferSize is the number of entries waiting to be flushed before
+	// Write starts blocking.
+	BufferSize int
+	// BatchSize entries are inserted at once; a full batch is flushed
+	// without waiting for FlushInterval.
+	BatchSize     int
+	FlushInterval time.Duration
+	// EnqueueTimeout bounds how long Write blocks on a full buffer before
+	// the entry is dropped.
+	EnqueueTimeout time.Duration
+	// WriteTimeout bounds a single batch insert or spill.
+	WriteTimeout time.Duration
+}
+
+func DefaultWriterConfig() WriterConfig {
+	return WriterConfig{
+		BufferSize:     1000,
+		BatchSize:      100,
+		FlushInterval:  time.Second,
+		EnqueueTimeout: 50 * time.Millisecond,
+		WriteTimeout:   5 * time.Second,
+	}
+}
+
+// Writer takes audit entries off the request path. Entries are buffered,
+// inserted in batches, and spilled to the task queue when the store fails.
+type Writer struct {
+	cfg     WriterConfig
+	store   Store
+	spiller Spiller
+
+	entries chan models.AuditLog
+	done    chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewWriter starts a writer. spiller may be nil, in which case batches the
+// store rejects are dropped.
+func NewWriter(cfg WriterConfig, store Store, spiller Spiller) *Writer {
+	w := &Writer{
+		cfg:     cfg,
+		store:   store,
+		spiller: spiller,
+		entries: make(chan models.AuditLog, cfg.BufferSize),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Write queues an entry. When the buffer stays full for EnqueueTimeout the
+// entry is dropped and counted rather than holding up the request.
+func (w *Writer) Write(entry models.AuditLog) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		stats.Add(statDropped, 1)
+		return ErrWriterClosed
+	}
+
+	select {
+	case w.entries <- entry:
+		stats.Add(statEnqueued, 1)
+		return nil
+	default:
+	}
+
+	timer := time.NewTimer(w.cfg.EnqueueTimeout)
+	defer timer.Stop()
+	select {
+	case w.entries <- entry:
+		stats.Add(statEnqueued, 1)
+		return nil
+	case <-timer.C:
+		stats.Add(statDropped, 1)
+		log.Printf("Audit buffer full, dropped %s entry", entry.Action)
+		return ErrBufferFull
+	}
+}
+
+// Close stops accepting entries and flushes everything still buffered.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return ErrWriterClosed
+	}
+	w.closed = true
+	close(w.entries)
+	w.mu.Unlock()
+
+	<-w.done
+	return nil
+}
+
+func (w *Writer) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.AuditLog, 0, w.cfg.BatchSize)
+	for {
+		select {
+		case entry, ok := <-w.entries:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+			batch = append(batch, entry)
+			if len(batch) >= w.cfg.BatchSize {
+				w.flush(batch)
+				batch = make([]models.AuditLog, 0, w.cfg.BatchSize)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				w.flush(batch)
+				batch = make([]models.AuditLog, 0, w.cfg.BatchSize)
+			}
+		}
+	}
+}
+
+func (w *Writer) flush(batch []models.AuditLog) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), w.cfg.WriteTimeout)
+	err := w.store.InsertAuditLogs(ctx, batch)
+	cancel()
+	if err == nil {
+		stats.Add(statWritten, int64(len(batch)))
+		return
+	}
+	stats.Add(statFlushErrors, 1)
+	log.Printf("Failed to write %d audit log entries: %v", len(batch), err)
+
+	failed := batch
+	if len(batch) > 1 {
+		failed = w.insertEach(batch)
+		if len(failed) == 0 {
+			return
+		}
+	}
+
+	if w.spiller != nil {
+		// A timed-out insert has used up its context; the spill gets its own
+		ctx, cancel := context.WithTimeout(context.Background(), w.cfg.WriteTimeout)
+		spillErr := w.spiller.DistributeAuditLogs(ctx, task.PayloadWriteAuditLogs{Entries: failed})
+		cancel()
+		if spillErr == nil {
+			stats.Add(statSpilled, int64(len(failed)))
+			return
+		}
+		log.Printf("Failed to spill audit log entries: %v", spillErr)
+	}
+
+	stats.Add(statDropped, int64(len(failed)))
+}
+
+// insertEach retries a rejected batch one entry at a time, so a single bad
+// entry does not take the rest of the batch with it, and returns the
+// entries that still fail. All inserts share one WriteTimeout: when the
+// store is down, the remaining entries fail fast and are spilled together.
+func (w *Writer) insertEach(batch []models.AuditLog) []models.AuditLog {
+	ctx, cancel := context.WithTimeout(context.Background(), w.cfg.WriteTimeout)
+	defer cancel()
+
+	var failed []models.AuditLog
+	for _, entry := range batch {
+		if ctx.Err() != nil {
+			failed = append(failed, entry)
+			continue
+		}
+		if err := w.store.InsertAuditLogs(ctx, []models.AuditLog{entry}); err != nil {
+			failed = append(failed, entry)
+			continue
+		}
+		stats.Add(statWritten, 1)
+	}
+	return failed
+}
@@ -0,0 +1,202 @@
+package audit_test
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
+	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"github.com/DMaryanskiy/bookshare-api/internal/task"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStore struct {
+	mu      sync.Mutex
+	batches [][]models.AuditLog
+	err     error
+	entered chan struct{}
+	block   chan struct{}
+	// reject fails any batch holding an entry it returns true for
+	reject func(models.AuditLog) bool
+}
+
+func (s *fakeStore) InsertAuditLogs(_ context.Context, entries []models.AuditLog) error {
+	if s.block != nil {
+		s.entered <- struct{}{}
+		<-s.block
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	for _, e := range entries {
+		if s.reject != nil && s.reject(e) {
+			return errors.New("invalid input syntax for type json")
+		}
+	}
+	s.batches = append(s.batches, entries)
+	return nil
+}
+
+func (s *fakeStore) written() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, b := range s.batches {
+		n += len(b)
+	}
+	return n
+}
+
+type fakeSpiller struct {
+	mu      sync.Mutex
+	entries []models.AuditLog
+	err     error
+}
+
+func (s *fakeSpiller) DistributeAuditLogs(_ context.Context, payload task.PayloadWriteAuditLogs) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.entries = append(s.entries, payload.Entries...)
+	return nil
+}
+
+func stat(name string) int64 {
+	v := expvar.Get("audit_writer").(*expvar.Map).Get(name)
+	if v == nil {
+		return 0
+	}
+	return v.(*expvar.Int).Value()
+}
+
+func entry(action audit.Action) models.AuditLog {
+	return models.AuditLog{ID: uuid.New(), Action: string(action), CreatedAt: time.Now()}
+}
+
+func testConfig() audit.WriterConfig {
+	cfg := audit.DefaultWriterConfig()
+	cfg.BatchSize = 3
+	cfg.FlushInterval = time.Hour
+	return cfg
+}
+
+func TestWriter_FlushesFullBatchesAndOnClose(t *testing.T) {
+	store := &fakeStore{}
+	w := audit.NewWriter(testConfig(), store, nil)
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, w.Write(entry(audit.ActionLoginSuccess)))
+	}
+
+	// The full batch goes out without waiting for the interval
+	require.Eventually(t, func() bool { return store.written() == 3 }, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, w.Close())
+	require.Equal(t, 4, store.written())
+	require.Len(t, store.batches, 2)
+
+	require.ErrorIs(t, w.Write(entry(audit.ActionLoginSuccess)), audit.ErrWriterClosed)
+}
+
+func TestWriter_FlushesOnInterval(t *testing.T) {
+	store := &fakeStore{}
+	cfg := testConfig()
+	cfg.FlushInterval = 20 * time.Millisecond
+	w := audit.NewWriter(cfg, store, nil)
+	defer w.Close()
+
+	require.NoError(t, w.Write(entry(audit.ActionLogout)))
+	require.Eventually(t, func() bool { return store.written() == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestWriter_SpillsWhenStoreFails(t *testing.T) {
+	store := &fakeStore{err: errors.New("connection refused")}
+	spiller := &fakeSpiller{}
+	w := audit.NewWriter(testConfig(), store, spiller)
+
+	spilled := stat("spilled")
+	e := entry(audit.ActionLoginFailed)
+	require.NoError(t, w.Write(e))
+	require.NoError(t, w.Close())
+
+	require.Len(t, spiller.entries, 1)
+	require.Equal(t, e.ID, spiller.entries[0].ID)
+	require.Equal(t, spilled+1, stat("spilled"))
+}
+
+func TestWriter_SpillsOnlyRejectedEntries(t *testing.T) {
+	poison := entry(audit.ActionLoginFailed)
+	store := &fakeStore{reject: func(e models.AuditLog) bool { return e.ID == poison.ID }}
+	spiller := &fakeSpiller{}
+	w := audit.NewWriter(testConfig(), store, spiller)
+
+	spilled := stat("spilled")
+	require.NoError(t, w.Write(entry(audit.ActionLogout)))
+	require.NoError(t, w.Write(poison))
+	require.NoError(t, w.Write(entry(audit.ActionLogout)))
+	require.NoError(t, w.Close())
+
+	// The batch of three fails, then its good entries go in one by one
+	require.Equal(t, 2, store.written())
+	require.Len(t, spiller.entries, 1)
+	require.Equal(t, poison.ID, spiller.entries[0].ID)
+	require.Equal(t, spilled+1, stat("spilled"))
+}
+
+func TestWriter_DropsWhenSpillFails(t *testing.T) {
+	store := &fakeStore{err: errors.New("connection refused")}
+	spiller := &fakeSpiller{err: errors.New("redis down")}
+	w := audit.NewWriter(testConfig(), store, spiller)
+
+	dropped := stat("dropped")
+	require.NoError(t, w.Write(entry(audit.ActionLoginFailed)))
+	require.NoError(t, w.Close())
+
+	require.Equal(t, dropped+1, stat("dropped"))
+}
+
+func TestWriter_DropsAfterBackpressureTimeout(t *testing.T) {
+	store := &fakeStore{entered: make(chan struct{}, 2), block: make(chan struct{})}
+	cfg := testConfig()
+	cfg.BufferSize = 1
+	cfg.BatchSize = 1
+	cfg.EnqueueTimeout = 10 * time.Millisecond
+	w := audit.NewWriter(cfg, store, nil)
+
+	dropped := stat("dropped")
+
+	// The first entry is stuck in the store, the second fills the buffer
+	require.NoError(t, w.Write(entry(audit.ActionLoginSuccess)))
+	<-store.entered
+	require.NoError(t, w.Write(entry(audit.ActionLoginSuccess)))
+	require.Equal(t, dropped, stat("dropped"))
+
+	start := time.Now()
+	require.ErrorIs(t, w.Write(entry(audit.ActionLoginSuccess)), audit.ErrBufferFull)
+	require.GreaterOrEqual(t, time.Since(start), cfg.EnqueueTimeout)
+	require.Equal(t, dropped+1, stat("dropped"))
+
+	close(store.block)
+	require.NoError(t, w.Close())
+	require.Equal(t, 2, store.written())
+}
+
+func TestSyncLogger_InsertsBeforeReturning(t *testing.T) {
+	store := &fakeStore{}
+	logger := audit.NewSyncLogger(store)
+
+	logger.Log(uuid.Nil, audit.ActionLogout, audit.Meta{IP: "10.0.0.1"})
+
+	require.Equal(t, 1, store.written())
+	require.Equal(t, string(audit.ActionLogout), store.batches[0][0].Action)
+	require.Nil(t, store.batches[0][0].UserID)
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/pkg/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+// userRevocationTTL bounds how long a "revoke everything issued before"
+// marker is kept. It must outlive the longest access token we issue.
+const userRevocationTTL = 24 * time.Hour
+
+// Denylist revokes access tokens before they expire. Single tokens are
+// denied by their jti; all of a user's tokens are denied by recording the
+// moment of revocation and rejecting anything issued up to then.
+type Denylist struct {
+	Redis *redis.Client
+}
+
+func NewDenylist(rdb *redis.Client) *Denylist {
+	return &Denylist{Redis: rdb}
+}
+
+func deniedTokenKey(jti string) string      { return "denied_access_token:" + jti }
+func userRevokedAtKey(userID string) string { return "access_tokens_revoked_at:" + userID }
+func suspendedUserKey(userID string) string { return "suspended_user:" + userID }
+
+// RevokeToken denies one access token for the rest of its lifetime.
+func (d *Denylist) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if jti == "" || ttl <= 0 {
+		return nil
+	}
+	return d.Redis.Set(ctx, deniedTokenKey(jti), 1, ttl).Err()
+}
+
+// RevokeUserTokens denies every access token issued to the user so far.
+func (d *Denylist) RevokeUserTokens(ctx context.Context, userID string) error {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	return d.Redis.Set(ctx, userRevokedAtKey(userID), now, userRevocationTTL).Err()
+}
+
+func (d *Denylist) IsRevoked(ctx context.Context, claims *utils.JWTClaims) (bool, error) {
+	vals, err := d.Redis.MGet(ctx, deniedTokenKey(claims.ID), userRevokedAtKey(claims.UserID)).Result()
+	if err != nil {
+		return false, err
+	}
+
+	if vals[0] != nil {
+		return true, nil
+	}
+
+	if raw, ok := vals[1].(string); ok {
+		revokedAt, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return false, err
+		}
+		// iat has second precision, so a token from the same second as the
+		// revocation is treated as revoked
+		if claims.IssuedAt == nil || claims.IssuedAt.Unix() <= revokedAt {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// SuspendUser denies every access token the user holds and keeps denying
+// new ones until UnsuspendUser. The marker has no expiry; the database
+// remains the source of truth and login re-checks it.
+func (d *Denylist) SuspendUser(ctx context.Context, userID string) error {
+	if err := d.Redis.Set(ctx, suspendedUserKey(userID), 1, 0).Err(); err != nil {
+		return err
+	}
+	return d.RevokeUserTokens(ctx, userID)
+}
+
+func (d *Denylist) UnsuspendUser(ctx context.Context, userID string) error {
+	return d.Redis.Del(ctx, suspendedUserKey(userID)).Err()
+}
+
+func (d *Denylist) IsSuspended(ctx context.Context, userID string) (bool, error) {
+	n, err := d.Redis.Exists(ctx, suspendedUserKey(userID)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
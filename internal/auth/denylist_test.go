@@ -0,0 +1,98 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/auth"
+	"github.com/DMaryanskiy/bookshare-api/internal/tests"
+	"github.com/DMaryanskiy/bookshare-api/pkg/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func issueClaims(t *testing.T, userID string) *utils.JWTClaims {
+	token, err := utils.GenerateAccessToken(userID, "user", time.Hour)
+	require.NoError(t, err)
+
+	claims, err := utils.ParseToken(token)
+	require.NoError(t, err)
+	return claims
+}
+
+func TestDenylist_RevokeToken(t *testing.T) {
+	tests.SetupTestRedis()
+	dl := auth.NewDenylist(tests.TestRedis)
+	ctx := context.Background()
+
+	revoked := issueClaims(t, "user-1")
+	other := issueClaims(t, "user-1")
+
+	require.NoError(t, dl.RevokeToken(ctx, revoked.ID, revoked.ExpiresAt.Time))
+
+	denied, err := dl.IsRevoked(ctx, revoked)
+	require.NoError(t, err)
+	require.True(t, denied)
+
+	denied, err = dl.IsRevoked(ctx, other)
+	require.NoError(t, err)
+	require.False(t, denied)
+
+	ttl := tests.TestRedis.TTL(ctx, "denied_access_token:"+revoked.ID).Val()
+	require.Greater(t, ttl, 59*time.Minute)
+}
+
+func TestDenylist_RevokeUserTokens(t *testing.T) {
+	tests.SetupTestRedis()
+	dl := auth.NewDenylist(tests.TestRedis)
+	ctx := context.Background()
+
+	before := issueClaims(t, "user-1")
+	unrelated := issueClaims(t, "user-2")
+
+	require.NoError(t, dl.RevokeUserTokens(ctx, "user-1"))
+
+	denied, err := dl.IsRevoked(ctx, before)
+	require.NoError(t, err)
+	require.True(t, denied)
+
+	denied, err = dl.IsRevoked(ctx, unrelated)
+	require.NoError(t, err)
+	require.False(t, denied)
+
+	// Tokens issued after the revocation are accepted
+	time.Sleep(time.Second)
+	after := issueClaims(t, "user-1")
+
+	denied, err = dl.IsRevoked(ctx, after)
+	require.NoError(t, err)
+	require.False(t, denied)
+}
+
+func TestDenylist_SuspendUser(t *testing.T) {
+	tests.SetupTestRedis()
+	dl := auth.NewDenylist(tests.TestRedis)
+	ctx := context.Background()
+
+	before := issueClaims(t, "user-1")
+
+	require.NoError(t, dl.SuspendUser(ctx, "user-1"))
+
+	suspended, err := dl.IsSuspended(ctx, "user-1")
+	require.NoError(t, err)
+	require.True(t, suspended)
+
+	denied, err := dl.IsRevoked(ctx, before)
+	require.NoError(t, err)
+	require.True(t, denied)
+
+	suspended, err = dl.IsSuspended(ctx, "user-2")
+	require.NoError(t, err)
+	require.False(t, suspended)
+
+	require.NoError(t, dl.UnsuspendUser(ctx, "user-1"))
+
+	suspended, err = dl.IsSuspended(ctx, "user-1")
+	require.NoError(t, err)
+	require.False(t, suspended)
+}
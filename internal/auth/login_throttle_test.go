@@ -0,0 +1,73 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/auth"
+	"github.com/DMaryanskiy/bookshare-api/internal/tests"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginThrottle_BackoffThenLock(t *testing.T) {
+	tests.SetupTestRedis()
+	lt := auth.NewLoginThrottle(tests.TestRedis)
+	ctx := context.Background()
+
+	for i := int64(1); i < auth.BackoffThreshold; i++ {
+		state, err := lt.RecordFailure(ctx, "victim@example.com")
+		require.NoError(t, err)
+		require.Equal(t, i, state.Failures)
+		require.Zero(t, state.RetryAfter)
+	}
+
+	check, err := lt.Check(ctx, "victim@example.com")
+	require.NoError(t, err)
+	require.Zero(t, check.RetryAfter)
+
+	// Delays double from the threshold on
+	want := auth.BaseBackoff
+	for i := int64(auth.BackoffThreshold); i < auth.LockThreshold; i++ {
+		state, err := lt.RecordFailure(ctx, "victim@example.com")
+		require.NoError(t, err)
+		require.False(t, state.Locked)
+		require.Equal(t, min(want, auth.MaxBackoff), state.RetryAfter)
+		want *= 2
+	}
+
+	check, err = lt.Check(ctx, "Victim@Example.com")
+	require.NoError(t, err)
+	require.Greater(t, check.RetryAfter, time.Duration(0))
+	require.False(t, check.Locked)
+
+	state, err := lt.RecordFailure(ctx, "victim@example.com")
+	require.NoError(t, err)
+	require.True(t, state.Locked)
+	require.Equal(t, auth.LockDuration, state.RetryAfter)
+
+	check, err = lt.Check(ctx, "victim@example.com")
+	require.NoError(t, err)
+	require.True(t, check.Locked)
+	require.Greater(t, check.RetryAfter, auth.LockDuration-time.Minute)
+}
+
+func TestLoginThrottle_Reset(t *testing.T) {
+	tests.SetupTestRedis()
+	lt := auth.NewLoginThrottle(tests.TestRedis)
+	ctx := context.Background()
+
+	for i := 0; i < auth.BackoffThreshold; i++ {
+		_, err := lt.RecordFailure(ctx, "reset@example.com")
+		require.NoError(t, err)
+	}
+	require.NoError(t, lt.Reset(ctx, "reset@example.com"))
+
+	check, err := lt.Check(ctx, "reset@example.com")
+	require.NoError(t, err)
+	require.Zero(t, check.RetryAfter)
+
+	state, err := lt.RecordFailure(ctx, "reset@example.com")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), state.Failures)
+}
@@ -2,16 +2,49 @@ package auth
 
 import (
 	"context"
+	"errors"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+	ErrSessionNotFound     = errors.New("session not found")
+)
+
+// SessionMeta describes the client a refresh token was issued to.
+type SessionMeta struct {
+	Device    string
+	IP        string
+	UserAgent string
+}
+
+// Session is a login on one device. Its refresh token changes on every
+// rotation but the session keeps its ID until it is revoked or expires, so
+// a session is also the family of all refresh tokens descended from a login.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"-"`
+	Device     string    `json:"device"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
 type RefreshTokenStore interface {
-	CreateRefreshToken(ctx context.Context, userID string, ttl time.Duration) (string, error)
+	CreateRefreshToken(ctx context.Context, userID string, meta SessionMeta, ttl time.Duration) (string, error)
 	VerifyRefreshToken(ctx context.Context, token string) (string, error)
+	RotateRefreshToken(ctx context.Context, token string, meta SessionMeta, ttl time.Duration) (newToken, userID string, err error)
 	DeleteRefreshToken(ctx context.Context, token string) error
+	DeleteUserRefreshTokens(ctx context.Context, userID string) error
+	ListSessions(ctx context.Context, userID string) ([]Session, error)
+	DeleteSession(ctx context.Context, userID, sessionID string) error
 }
 
 type TokenStore struct {
@@ -23,16 +56,248 @@ func NewTokenStore(addr string) *TokenStore {
 	return &TokenStore{Redis: rdb}
 }
 
-func (ts *TokenStore) CreateRefreshToken(ctx context.Context, userID string, ttl time.Duration) (string, error) {
+// Keys:
+//
+//	refresh_token:<token>         -> session ID
+//	rotated_refresh_token:<token> -> "<userID>:<session ID>" of an already rotated token
+//	session:<id>                  -> hash of session fields and the current token
+//	user_sessions:<userID>        -> set of session IDs
+func refreshTokenKey(token string) string        { return "refresh_token:" + token }
+func rotatedRefreshTokenKey(token string) string { return "rotated_refresh_token:" + token }
+func sessionKey(sessionID string) string         { return "session:" + sessionID }
+func userSessionsKey(userID string) string       { return "user_sessions:" + userID }
+
+// consumeTokenScript deletes a refresh token and, in the same step, leaves a
+// marker behind so that presenting the token again can be recognised as reuse.
+var consumeTokenScript = redis.NewScript(`
+local sid = redis.call('GETDEL', KEYS[1])
+if not sid then
+	return false
+end
+local user_id = redis.call('HGET', KEYS[3] .. sid, 'user_id')
+if not user_id then
+	return false
+end
+redis.call('SET', KEYS[2], user_id .. ':' .. sid, 'PX', ARGV[1])
+return {sid, user_id}
+`)
+
+func (ts *TokenStore) CreateRefreshToken(ctx context.Context, userID string, meta SessionMeta, ttl time.Duration) (string, error) {
 	token := uuid.New().String()
-	err := ts.Redis.Set(ctx, token, userID, ttl).Err()
+	sessionID := uuid.New().String()
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+
+	pipe := ts.Redis.TxPipeline()
+	pipe.Set(ctx, refreshTokenKey(token), sessionID, ttl)
+	pipe.HSet(ctx, sessionKey(sessionID), map[string]any{
+		"user_id":      userID,
+		"token":        token,
+		"device":       meta.Device,
+		"ip":           meta.IP,
+		"user_agent":   meta.UserAgent,
+		"created_at":   now,
+		"last_used_at": now,
+	})
+	pipe.Expire(ctx, sessionKey(sessionID), ttl)
+	pipe.SAdd(ctx, userSessionsKey(userID), sessionID)
+	pipe.Expire(ctx, userSessionsKey(userID), ttl)
+	_, err := pipe.Exec(ctx)
 	return token, err
 }
 
 func (ts *TokenStore) VerifyRefreshToken(ctx context.Context, token string) (string, error) {
-	return ts.Redis.Get(ctx, token).Result()
+	sessionID, err := ts.Redis.Get(ctx, refreshTokenKey(token)).Result()
+	if err == redis.Nil {
+		return "", ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return "", err
+	}
+
+	userID, err := ts.Redis.HGet(ctx, sessionKey(sessionID), "user_id").Result()
+	if err == redis.Nil {
+		return "", ErrInvalidRefreshToken
+	}
+	return userID, err
+}
+
+// RotateRefreshToken consumes token and issues its replacement within the
+// same session. Each token can be rotated exactly once: presenting a token
+// that was already rotated means it has leaked, so the whole session is
+// revoked and ErrRefreshTokenReused is returned along with the owner's ID.
+func (ts *TokenStore) RotateRefreshToken(ctx context.Context, token string, meta SessionMeta, ttl time.Duration) (string, string, error) {
+	keys := []string{refreshTokenKey(token), rotatedRefreshTokenKey(token), sessionKey("")}
+	res, err := consumeTokenScript.Run(ctx, ts.Redis, keys, ttl.Milliseconds()).StringSlice()
+	if err == redis.Nil {
+		return "", "", ts.detectReuse(ctx, token)
+	}
+	if err != nil {
+		return "", "", err
+	}
+	sessionID, userID := res[0], res[1]
+
+	newToken := uuid.New().String()
+
+	pipe := ts.Redis.TxPipeline()
+	pipe.Set(ctx, refreshTokenKey(newToken), sessionID, ttl)
+	pipe.HSet(ctx, sessionKey(sessionID), map[string]any{
+		"token":        newToken,
+		"ip":           meta.IP,
+		"user_agent":   meta.UserAgent,
+		"last_used_at": time.Now().UTC().Format(time.RFC3339Nano),
+	})
+	pipe.Expire(ctx, sessionKey(sessionID), ttl)
+	pipe.Expire(ctx, userSessionsKey(userID), ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", "", err
+	}
+
+	return newToken, userID, nil
 }
 
+// detectReuse is called for a token that is no longer valid. If it was
+// rotated before, its session is revoked to lock out whoever holds the
+// newer tokens.
+func (ts *TokenStore) detectReuse(ctx context.Context, token string) error {
+	marker, err := ts.Redis.Get(ctx, rotatedRefreshTokenKey(token)).Result()
+	if err == redis.Nil {
+		return ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return err
+	}
+
+	userID, sessionID, _ := strings.Cut(marker, ":")
+	if err := ts.deleteSessions(ctx, userID, sessionID); err != nil {
+		return err
+	}
+
+	return &ReuseError{UserID: userID, SessionID: sessionID}
+}
+
+// ReuseError reports which session was revoked after a rotated refresh
+// token was presented again. It matches ErrRefreshTokenReused with errors.Is.
+type ReuseError struct {
+	UserID    string
+	SessionID string
+}
+
+func (e *ReuseError) Error() string { return ErrRefreshTokenReused.Error() }
+
+func (e *ReuseError) Is(target error) bool { return target == ErrRefreshTokenReused }
+
+// DeleteRefreshToken ends the session the token belongs to.
 func (ts *TokenStore) DeleteRefreshToken(ctx context.Context, token string) error {
-	return ts.Redis.Del(ctx, token).Err()
+	sessionID, err := ts.Redis.Get(ctx, refreshTokenKey(token)).Result()
+	if err == redis.Nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	userID, err := ts.Redis.HGet(ctx, sessionKey(sessionID), "user_id").Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+
+	return ts.deleteSessions(ctx, userID, sessionID)
+}
+
+func (ts *TokenStore) DeleteUserRefreshTokens(ctx context.Context, userID string) error {
+	sessionIDs, err := ts.Redis.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+
+	if err := ts.deleteSessions(ctx, userID, sessionIDs...); err != nil {
+		return err
+	}
+	return ts.Redis.Del(ctx, userSessionsKey(userID)).Err()
+}
+
+func (ts *TokenStore) ListSessions(ctx context.Context, userID string) ([]Session, error) {
+	sessionIDs, err := ts.Redis.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]Session, 0, len(sessionIDs))
+	var expired []any
+	for _, id := range sessionIDs {
+		fields, err := ts.Redis.HGetAll(ctx, sessionKey(id)).Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) == 0 {
+			expired = append(expired, id)
+			continue
+		}
+		sessions = append(sessions, sessionFromHash(id, fields))
+	}
+
+	// Sessions expire on their own; drop their stale index entries
+	if len(expired) > 0 {
+		ts.Redis.SRem(ctx, userSessionsKey(userID), expired...)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+	return sessions, nil
+}
+
+func (ts *TokenStore) DeleteSession(ctx context.Context, userID, sessionID string) error {
+	owner, err := ts.Redis.HGet(ctx, sessionKey(sessionID), "user_id").Result()
+	if err == redis.Nil || (err == nil && owner != userID) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return ts.deleteSessions(ctx, userID, sessionID)
+}
+
+func (ts *TokenStore) deleteSessions(ctx context.Context, userID string, sessionIDs ...string) error {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(sessionIDs)*2)
+	members := make([]any, 0, len(sessionIDs))
+	for _, id := range sessionIDs {
+		token, err := ts.Redis.HGet(ctx, sessionKey(id), "token").Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if token != "" {
+			keys = append(keys, refreshTokenKey(token))
+		}
+		keys = append(keys, sessionKey(id))
+		members = append(members, id)
+	}
+
+	pipe := ts.Redis.TxPipeline()
+	pipe.Del(ctx, keys...)
+	if userID != "" {
+		pipe.SRem(ctx, userSessionsKey(userID), members...)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func sessionFromHash(id string, fields map[string]string) Session {
+	createdAt, _ := time.Parse(time.RFC3339Nano, fields["created_at"])
+	lastUsedAt, _ := time.Parse(time.RFC3339Nano, fields["last_used_at"])
+
+	return Session{
+		ID:         id,
+		UserID:     fields["user_id"],
+		Device:     fields["device"],
+		IP:         fields["ip"],
+		UserAgent:  fields["user_agent"],
+		CreatedAt:  createdAt,
+		LastUsedAt: lastUsedAt,
+	}
 }
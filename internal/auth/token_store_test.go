@@ -0,0 +1,116 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/auth"
+	"github.com/DMaryanskiy/bookshare-api/internal/tests"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTokenStore() *auth.TokenStore {
+	tests.SetupTestRedis()
+	return &auth.TokenStore{Redis: tests.TestRedis}
+}
+
+func TestTokenStore_RotateKeepsSession(t *testing.T) {
+	ts := setupTokenStore()
+	ctx := context.Background()
+
+	token, err := ts.CreateRefreshToken(ctx, "user-1", auth.SessionMeta{Device: "laptop", IP: "10.0.0.1"}, time.Hour)
+	require.NoError(t, err)
+
+	before, err := ts.ListSessions(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, before, 1)
+	require.Equal(t, "laptop", before[0].Device)
+
+	newToken, userID, err := ts.RotateRefreshToken(ctx, token, auth.SessionMeta{IP: "10.0.0.2"}, time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, "user-1", userID)
+	require.NotEqual(t, token, newToken)
+
+	_, err = ts.VerifyRefreshToken(ctx, token)
+	require.ErrorIs(t, err, auth.ErrInvalidRefreshToken)
+
+	after, err := ts.ListSessions(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, after, 1)
+	require.Equal(t, before[0].ID, after[0].ID)
+	require.Equal(t, "laptop", after[0].Device)
+	require.Equal(t, "10.0.0.2", after[0].IP)
+}
+
+func TestTokenStore_DeleteSession(t *testing.T) {
+	ts := setupTokenStore()
+	ctx := context.Background()
+
+	token, err := ts.CreateRefreshToken(ctx, "user-1", auth.SessionMeta{}, time.Hour)
+	require.NoError(t, err)
+	sessions, err := ts.ListSessions(ctx, "user-1")
+	require.NoError(t, err)
+
+	// Users can only revoke their own sessions
+	err = ts.DeleteSession(ctx, "user-2", sessions[0].ID)
+	require.ErrorIs(t, err, auth.ErrSessionNotFound)
+
+	require.NoError(t, ts.DeleteSession(ctx, "user-1", sessions[0].ID))
+
+	_, err = ts.VerifyRefreshToken(ctx, token)
+	require.ErrorIs(t, err, auth.ErrInvalidRefreshToken)
+}
+
+func TestTokenStore_DeleteUserRefreshTokens(t *testing.T) {
+	ts := setupTokenStore()
+	ctx := context.Background()
+
+	first, err := ts.CreateRefreshToken(ctx, "user-1", auth.SessionMeta{Device: "phone"}, time.Hour)
+	require.NoError(t, err)
+	second, err := ts.CreateRefreshToken(ctx, "user-1", auth.SessionMeta{Device: "tablet"}, time.Hour)
+	require.NoError(t, err)
+	other, err := ts.CreateRefreshToken(ctx, "user-2", auth.SessionMeta{}, time.Hour)
+	require.NoError(t, err)
+
+	require.NoError(t, ts.DeleteUserRefreshTokens(ctx, "user-1"))
+
+	for _, token := range []string{first, second} {
+		_, err := ts.VerifyRefreshToken(ctx, token)
+		require.ErrorIs(t, err, auth.ErrInvalidRefreshToken)
+	}
+
+	userID, err := ts.VerifyRefreshToken(ctx, other)
+	require.NoError(t, err)
+	require.Equal(t, "user-2", userID)
+}
+
+func TestTokenStore_ReuseRevokesFamily(t *testing.T) {
+	ts := setupTokenStore()
+	ctx := context.Background()
+
+	original, err := ts.CreateRefreshToken(ctx, "user-1", auth.SessionMeta{}, time.Hour)
+	require.NoError(t, err)
+
+	rotated, _, err := ts.RotateRefreshToken(ctx, original, auth.SessionMeta{}, time.Hour)
+	require.NoError(t, err)
+
+	_, _, err = ts.RotateRefreshToken(ctx, original, auth.SessionMeta{}, time.Hour)
+	require.ErrorIs(t, err, auth.ErrRefreshTokenReused)
+
+	var reuse *auth.ReuseError
+	require.ErrorAs(t, err, &reuse)
+	require.Equal(t, "user-1", reuse.UserID)
+
+	// The descendant token is gone along with the session
+	_, err = ts.VerifyRefreshToken(ctx, rotated)
+	require.ErrorIs(t, err, auth.ErrInvalidRefreshToken)
+
+	sessions, err := ts.ListSessions(ctx, "user-1")
+	require.NoError(t, err)
+	require.Empty(t, sessions)
+
+	// Unknown tokens are simply invalid
+	_, _, err = ts.RotateRefreshToken(ctx, "never-issued", auth.SessionMeta{}, time.Hour)
+	require.ErrorIs(t, err, auth.ErrInvalidRefreshToken)
+}
@@ -7,38 +7,46 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/DMaryanskiy/bookshare-api/internal/auth"
 	"github.com/DMaryanskiy/bookshare-api/internal/books"
-	"github.com/DMaryanskiy/bookshare-api/internal/db"
 	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
 	"github.com/DMaryanskiy/bookshare-api/internal/middleware"
+	"github.com/DMaryanskiy/bookshare-api/internal/pagination"
+	"github.com/DMaryanskiy/bookshare-api/internal/task/distributor"
 	"github.com/DMaryanskiy/bookshare-api/internal/tests"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 )
 
-func setupBookRouter() *gin.Engine {
+func setupBookRouter(gdb *gorm.DB) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 
 	router := gin.Default()
 
 	// Middleware for JWT auth
-	router.Use(middleware.JWTAuthMiddleware())
+	router.Use(middleware.JWTAuthMiddleware(auth.NewDenylist(tests.TestRedis)))
 
-	bh := books.NewHandler()
+	dist := distributor.NewTaskDistributor(tests.TestRedis.Options().Addr)
+	bh := books.NewHandler(tests.Conns(gdb), dist)
 	router.POST("/books", bh.CreateBook)
+	router.GET("/books", bh.ListBooks)
+	router.GET("/books/search", bh.SearchBooks)
 	router.GET("/books/:id", bh.GetBook)
 	router.PUT("/books/:id", bh.UpdateBook)
 	router.DELETE("/books/:id", bh.DeleteBook)
+	router.GET("/catalog", bh.ListCatalog)
+	router.GET("/catalog/:id", bh.GetCatalogBook)
 
 	return router
 }
 
 func TestCreateBook_Success(t *testing.T) {
-	tests.SetupTestDB(t)
+	gdb := tests.SetupTestDB(t)
 	tests.SetupTestRedis()
 
-	_, token := tests.CreateTestUser(t, "bookuser@example.com", "password123")
-	r := setupBookRouter()
+	_, token := tests.CreateTestUser(t, gdb, "bookuser@example.com", "password123")
+	r := setupBookRouter(gdb)
 
 	body := map[string]string{
 		"title":       "Test Book",
@@ -56,7 +64,7 @@ func TestCreateBook_Success(t *testing.T) {
 
 	require.Equal(t, http.StatusCreated, w.Code)
 
-	var resp models.Book
+	var resp books.BookResponse
 	err := json.Unmarshal(w.Body.Bytes(), &resp)
 	require.NoError(t, err)
 
@@ -66,19 +74,19 @@ func TestCreateBook_Success(t *testing.T) {
 }
 
 func TestGetBook_Success(t *testing.T) {
-	tests.SetupTestDB(t)
+	gdb := tests.SetupTestDB(t)
 	tests.SetupTestRedis()
 
-	user, token := tests.CreateTestUser(t, "reader@example.com", "password123")
+	user, token := tests.CreateTestUser(t, gdb, "reader@example.com", "password123")
 	book := models.Book{
 		Title:       "Readable Book",
 		Author:      "Read Author",
 		Description: "Description",
 		UserID:      user.ID,
 	}
-	require.NoError(t, db.DB.Table("books.books").Create(&book).Error)
+	require.NoError(t, gdb.Table("books.books").Create(&book).Error)
 
-	r := setupBookRouter()
+	r := setupBookRouter(gdb)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/books/"+book.ID.String(), nil)
@@ -89,19 +97,19 @@ func TestGetBook_Success(t *testing.T) {
 }
 
 func TestUpdateBook_Success(t *testing.T) {
-	tests.SetupTestDB(t)
+	gdb := tests.SetupTestDB(t)
 	tests.SetupTestRedis()
 
-	user, token := tests.CreateTestUser(t, "updater@example.com", "password123")
+	user, token := tests.CreateTestUser(t, gdb, "updater@example.com", "password123")
 	book := models.Book{
 		Title:       "Old Title",
 		Author:      "Old Author",
 		Description: "Description",
 		UserID:      user.ID,
 	}
-	require.NoError(t, db.DB.Table("books.books").Create(&book).Error)
+	require.NoError(t, gdb.Table("books.books").Create(&book).Error)
 
-	r := setupBookRouter()
+	r := setupBookRouter(gdb)
 
 	updateBody := map[string]string{
 		"title":  "Updated Title",
@@ -119,25 +127,25 @@ func TestUpdateBook_Success(t *testing.T) {
 
 	// Fetch updated book
 	var updated models.Book
-	err := db.DB.Table("books.books").First(&updated, "id = ?", book.ID).Error
+	err := gdb.Table("books.books").First(&updated, "id = ?", book.ID).Error
 	require.NoError(t, err)
 	require.Equal(t, "Updated Title", updated.Title)
 }
 
 func TestDeleteBook_Success(t *testing.T) {
-	tests.SetupTestDB(t)
+	gdb := tests.SetupTestDB(t)
 	tests.SetupTestRedis()
 
-	user, token := tests.CreateTestUser(t, "deleter@example.com", "password123")
+	user, token := tests.CreateTestUser(t, gdb, "deleter@example.com", "password123")
 	book := models.Book{
 		Title:       "Book to Delete",
 		Author:      "Ghost Author",
 		Description: "Description",
 		UserID:      user.ID,
 	}
-	require.NoError(t, db.DB.Table("books.books").Create(&book).Error)
+	require.NoError(t, gdb.Table("books.books").Create(&book).Error)
 
-	r := setupBookRouter()
+	r := setupBookRouter(gdb)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("DELETE", "/books/"+book.ID.String(), nil)
@@ -147,15 +155,34 @@ func TestDeleteBook_Success(t *testing.T) {
 	require.Equal(t, http.StatusOK, w.Code)
 
 	var count int64
-	db.DB.Table("books.books").Model(&models.Book{}).Where("id = ?", book.ID).Count(&count)
+	gdb.Table("books.books").Model(&models.Book{}).Where("id = ?", book.ID).Count(&count)
 	require.Equal(t, int64(0), count)
 }
 
+func TestCreateBook_RejectsUnknownVisibility(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	_, token := tests.CreateTestUser(t, gdb, "visibility@example.com", "password123")
+	r := setupBookRouter(gdb)
+
+	// There is no friend relation, so books are either private or public
+	data, _ := json.Marshal(map[string]string{"title": "Test Book", "visibility": "friends"})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/books", bytes.NewBuffer(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", tests.GetAuthHeader(token))
+
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
 func TestCreateBook_Unauthorized(t *testing.T) {
-	tests.SetupTestDB(t)
+	gdb := tests.SetupTestDB(t)
 	tests.SetupTestRedis()
 
-	r := setupBookRouter()
+	r := setupBookRouter(gdb)
 
 	body := map[string]string{
 		"title":        "Unauthorized Book",
@@ -172,3 +199,249 @@ func TestCreateBook_Unauthorized(t *testing.T) {
 	r.ServeHTTP(w, req)
 	require.Equal(t, http.StatusUnauthorized, w.Code)
 }
+
+func TestCatalog_ListsOnlyPublicBooks(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	owner, _ := tests.CreateTestUser(t, gdb, "sharer@example.com", "password123")
+	_, token := tests.CreateTestUser(t, gdb, "browser@example.com", "password123")
+
+	public := models.Book{Title: "Shared", UserID: owner.ID, Visibility: models.BookVisibilityPublic}
+	private := models.Book{Title: "Hidden", UserID: owner.ID, Visibility: models.BookVisibilityPrivate}
+	require.NoError(t, gdb.Table("books.books").Create(&public).Error)
+	require.NoError(t, gdb.Table("books.books").Create(&private).Error)
+
+	r := setupBookRouter(gdb)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/catalog", nil)
+	req.Header.Set("Authorization", tests.GetAuthHeader(token))
+
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotContains(t, w.Body.String(), "sharer@example.com")
+
+	var resp pagination.Page[books.CatalogBook]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Data, 1)
+	require.Equal(t, public.ID, resp.Data[0].ID)
+	require.Equal(t, owner.ID, resp.Data[0].OwnerID)
+}
+
+func TestCatalog_PrivateBookNotFound(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	owner, _ := tests.CreateTestUser(t, gdb, "secretive@example.com", "password123")
+	_, token := tests.CreateTestUser(t, gdb, "curious@example.com", "password123")
+
+	book := models.Book{Title: "Diary", UserID: owner.ID}
+	require.NoError(t, gdb.Table("books.books").Create(&book).Error)
+
+	r := setupBookRouter(gdb)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/catalog/"+book.ID.String(), nil)
+	req.Header.Set("Authorization", tests.GetAuthHeader(token))
+
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestSearchBooks_RanksAndHighlights(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	user, token := tests.CreateTestUser(t, gdb, "searcher@example.com", "password123")
+	other, _ := tests.CreateTestUser(t, gdb, "other@example.com", "password123")
+
+	titleMatch := models.Book{Title: "Dragons of Autumn", Author: "Weis", UserID: user.ID}
+	descMatch := models.Book{Title: "Fantasy Atlas", Description: "Maps where dragons live", UserID: user.ID}
+	hidden := models.Book{Title: "Secret Dragons", UserID: other.ID}
+	shared := models.Book{Title: "Public Dragons", UserID: other.ID, Visibility: models.BookVisibilityPublic}
+	for _, b := range []*models.Book{&titleMatch, &descMatch, &hidden, &shared} {
+		require.NoError(t, gdb.Table("books.books").Create(b).Error)
+	}
+
+	r := setupBookRouter(gdb)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/books/search?q=dragon", nil)
+	req.Header.Set("Authorization", tests.GetAuthHeader(token))
+
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp []books.SearchResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp, 3)
+
+	ids := make([]string, 0, len(resp))
+	for _, res := range resp {
+		ids = append(ids, res.ID.String())
+	}
+	require.NotContains(t, ids, hidden.ID.String())
+
+	// Title matches outrank description matches
+	require.NotEqual(t, descMatch.ID, resp[0].ID)
+	require.Equal(t, descMatch.ID, resp[len(resp)-1].ID)
+	require.Contains(t, resp[len(resp)-1].Highlights.Description, "<mark>dragons</mark>")
+}
+
+func TestSearchBooks_EscapesHighlights(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	user, token := tests.CreateTestUser(t, gdb, "escaper@example.com", "password123")
+	book := models.Book{
+		Title:       "Dragons <b>bold</b>",
+		Description: `Where dragons live <img src=x onerror="alert(1)"> & sleep`,
+		UserID:      user.ID,
+	}
+	require.NoError(t, gdb.Table("books.books").Create(&book).Error)
+
+	r := setupBookRouter(gdb)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/books/search?q=dragons", nil)
+	req.Header.Set("Authorization", tests.GetAuthHeader(token))
+
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp []books.SearchResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp, 1)
+
+	// Only the <mark> tags are markup; the book's own text is escaped
+	highlights := resp[0].Highlights
+	require.Contains(t, highlights.Title, "<mark>Dragons</mark>")
+	require.Contains(t, highlights.Title, "&lt;b&gt;bold&lt;/b&gt;")
+	require.NotContains(t, highlights.Description, "<img")
+	require.Contains(t, highlights.Description, "&lt;img src=x onerror=&quot;alert(1)&quot;&gt;")
+
+	// The plain fields are returned as stored
+	require.Equal(t, book.Description, resp[0].Description)
+}
+
+func TestSearchBooks_MissingQuery(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	_, token := tests.CreateTestUser(t, gdb, "noquery@example.com", "password123")
+	r := setupBookRouter(gdb)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/books/search", nil)
+	req.Header.Set("Authorization", tests.GetAuthHeader(token))
+
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestListBooks_Paginates(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	user, token := tests.CreateTestUser(t, gdb, "pager@example.com", "password123")
+	for _, title := range []string{"One", "Two", "Three", "Four", "Five"} {
+		book := models.Book{Title: title, Author: "Pager", UserID: user.ID}
+		require.NoError(t, gdb.Table("books.books").Create(&book).Error)
+	}
+
+	r := setupBookRouter(gdb)
+
+	fetch := func(query string) pagination.Page[books.BookResponse] {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/books?"+query, nil)
+		req.Header.Set("Authorization", tests.GetAuthHeader(token))
+
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var page pagination.Page[books.BookResponse]
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		return page
+	}
+
+	seen := map[string]bool{}
+	page := fetch("limit=2&author=pager")
+	for {
+		for _, b := range page.Data {
+			require.False(t, seen[b.ID.String()], "book returned twice")
+			seen[b.ID.String()] = true
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		page = fetch("limit=2&author=pager&cursor=" + page.NextCursor)
+	}
+	require.Len(t, seen, 5)
+
+	// The author filter excludes everything else
+	require.Empty(t, fetch("author=nobody").Data)
+}
+
+func TestListBooks_InvalidCursor(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	_, token := tests.CreateTestUser(t, gdb, "badcursor@example.com", "password123")
+	r := setupBookRouter(gdb)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/books?cursor=garbage", nil)
+	req.Header.Set("Authorization", tests.GetAuthHeader(token))
+
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreateBook_NormalizesISBN(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	_, token := tests.CreateTestUser(t, gdb, "isbn@example.com", "password123")
+	r := setupBookRouter(gdb)
+
+	body := map[string]string{
+		"title": "The Go Programming Language",
+		"isbn":  "0-13-419044-0",
+	}
+	data, _ := json.Marshal(body)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/books", bytes.NewBuffer(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", tests.GetAuthHeader(token))
+
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var resp books.BookResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, "9780134190440", resp.ISBN)
+}
+
+func TestCreateBook_InvalidISBN(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	_, token := tests.CreateTestUser(t, gdb, "badisbn@example.com", "password123")
+	r := setupBookRouter(gdb)
+
+	body := map[string]string{
+		"title": "Mystery",
+		"isbn":  "1234567890",
+	}
+	data, _ := json.Marshal(body)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/books", bytes.NewBuffer(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", tests.GetAuthHeader(token))
+
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,93 @@
+package books
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"github.com/DMaryanskiy/bookshare-api/internal/pagination"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CatalogBook is the public view of a shared book. It deliberately carries
+// only the owner's ID so that browsing the catalog never reveals emails.
+type CatalogBook struct {
+	ID          uuid.UUID `json:"id"`
+	OwnerID     uuid.UUID `json:"owner_id"`
+	Title       string    `json:"title"`
+	Author      string    `json:"author"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func newCatalogBook(b models.Book) CatalogBook {
+	return CatalogBook{
+		ID:          b.ID,
+		OwnerID:     b.UserID,
+		Title:       b.Title,
+		Author:      b.Author,
+		Description: b.Description,
+		CreatedAt:   b.CreatedAt,
+	}
+}
+
+// ListCatalog godoc
+// @Summary      Browse the public catalog
+// @Description  Returns a page of other users' books whose visibility is public
+// @Tags         catalog
+// @Produce      json
+// @Param        limit   query     int     false  "Page size (default 20, max 100)"
+// @Param        cursor  query     string  false  "Cursor from a previous page's next_cursor"
+// @Param        sort    query     string  false  "created_at or -created_at (default)"
+// @Param        author  query     string  false  "Filter by author (case-insensitive)"
+// @Success      200  {object}  pagination.Page[CatalogBook]  "Page of public books"
+// @Failure      400  {object}  map[string]string  "Invalid pagination or filter"
+// @Failure      500  {object}  map[string]string  "Could not fetch catalog"
+// @Router       /catalog [get]
+func (h *Handler) ListCatalog(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	params, err := pagination.Parse(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := h.ReadDB.Table("books.books").
+		Where("visibility = ? AND user_id <> ?", models.BookVisibilityPublic, userID)
+	if author := c.Query("author"); author != "" {
+		query = query.Where("lower(author) = lower(?)", author)
+	}
+
+	var books []models.Book
+	if err := params.Apply(query).Find(&books).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch catalog"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pagination.MapPage(pagination.NewPage(books, params, bookCursor), newCatalogBook))
+}
+
+// GetCatalogBook godoc
+// @Summary      Get a public book
+// @Description  Retrieves a single book from the public catalog
+// @Tags         catalog
+// @Produce      json
+// @Param        id   path      string  true  "Book ID"
+// @Success      200  {object}  CatalogBook  "Book retrieved successfully"
+// @Failure      404  {object}  map[string]string  "Book not found"
+// @Router       /catalog/{id} [get]
+func (h *Handler) GetCatalogBook(c *gin.Context) {
+	bookID := c.Param("id")
+
+	var book models.Book
+	if err := h.DB.Table("books.books").
+		Where("id = ? AND visibility = ?", bookID, models.BookVisibilityPublic).
+		First(&book).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newCatalogBook(book))
+}
@@ -3,20 +3,20 @@ package books
 import (
 	"net/http"
 
-	"github.com/DMaryanskiy/bookshare-api/internal/db"
 	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"github.com/DMaryanskiy/bookshare-api/internal/metadata"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 // CreateBook godoc
 // @Summary      Create a new book
-// @Description  Creates a new book record for the authenticated user
+// @Description  Creates a new book record for the authenticated user. When an ISBN is given, cover, publisher, page count and publication year are filled in asynchronously.
 // @Tags         books
 // @Accept       json
 // @Produce      json
 // @Param        book  body  BookInput  true  "Book details"
-// @Success      201  {object}  models.Book  "Book created successfully"
+// @Success      201  {object}  BookResponse  "Book created successfully"
 // @Failure      400  {object}  map[string]string  "Invalid input"
 // @Failure      500  {object}  map[string]string  "Failed to create book"
 // @Router       /books [post]
@@ -34,12 +34,26 @@ func (h *Handler) CreateBook(c *gin.Context) {
 		Title:       req.Title,
 		Author:      req.Author,
 		Description: req.Description,
+		Visibility:  models.BookVisibility(req.Visibility),
 	}
 
-	if err := db.DB.Table("books.books").Create(&book).Error; err != nil {
+	if req.ISBN != "" {
+		isbn, err := metadata.NormalizeISBN(req.ISBN)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid isbn"})
+			return
+		}
+		book.ISBN = isbn
+	}
+
+	if err := h.DB.Table("books.books").Create(&book).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create book"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, book)
+	if book.ISBN != "" {
+		h.enqueueEnrichment(c, book)
+	}
+
+	c.JSON(http.StatusCreated, newBookResponse(book))
 }
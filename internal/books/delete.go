@@ -3,7 +3,6 @@ package books
 import (
 	"net/http"
 
-	"github.com/DMaryanskiy/bookshare-api/internal/db"
 	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
 	"github.com/gin-gonic/gin"
 )
@@ -21,7 +20,7 @@ func (h *Handler) DeleteBook(c *gin.Context) {
 	userID := c.GetString("user_id")
 	bookID := c.Param("id")
 
-	if err := db.DB.Table("books.books").
+	if err := h.DB.Table("books.books").
 		Where("id = ? AND user_id = ?", bookID, userID).
 		Delete(&models.Book{}).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not delete book"})
@@ -1,13 +1,80 @@
 package books
 
-type Handler struct {}
+import (
+	"log"
+	"time"
 
-func NewHandler() *Handler {
-	return &Handler{}
+	"github.com/DMaryanskiy/bookshare-api/internal/db"
+	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"github.com/DMaryanskiy/bookshare-api/internal/task"
+	"github.com/DMaryanskiy/bookshare-api/internal/task/distributor"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type Handler struct {
+	DB              *gorm.DB
+	ReadDB          *gorm.DB // listings and search, may lag behind DB
+	TaskDistributor *distributor.TaskDistributor
+}
+
+func NewHandler(conns db.Conns, dist *distributor.TaskDistributor) *Handler {
+	return &Handler{DB: conns.Primary, ReadDB: conns.Replica, TaskDistributor: dist}
 }
 
 type BookInput struct {
 	Title       string `json:"title" binding:"required"`
 	Author      string `json:"author"`
 	Description string `json:"description"`
+	Visibility  string `json:"visibility" binding:"omitempty,oneof=private public"`
+	ISBN        string `json:"isbn"`
+}
+
+// BookResponse is a book as its owner sees it, including the metadata
+// filled in from the ISBN.
+type BookResponse struct {
+	ID              uuid.UUID             `json:"id"`
+	OwnerID         uuid.UUID             `json:"owner_id"`
+	Title           string                `json:"title"`
+	Author          string                `json:"author"`
+	Description     string                `json:"description"`
+	Visibility      models.BookVisibility `json:"visibility"`
+	ISBN            string                `json:"isbn,omitempty"`
+	CoverURL        string                `json:"cover_url,omitempty"`
+	Publisher       string                `json:"publisher,omitempty"`
+	PageCount       int                   `json:"page_count,omitempty"`
+	PublicationYear int                   `json:"publication_year,omitempty"`
+	CreatedAt       time.Time             `json:"created_at"`
+	UpdatedAt       time.Time             `json:"updated_at"`
+}
+
+func newBookResponse(b models.Book) BookResponse {
+	return BookResponse{
+		ID:              b.ID,
+		OwnerID:         b.UserID,
+		Title:           b.Title,
+		Author:          b.Author,
+		Description:     b.Description,
+		Visibility:      b.Visibility,
+		ISBN:            b.ISBN,
+		CoverURL:        b.CoverURL,
+		Publisher:       b.Publisher,
+		PageCount:       b.PageCount,
+		PublicationYear: b.PublicationYear,
+		CreatedAt:       b.CreatedAt,
+		UpdatedAt:       b.UpdatedAt,
+	}
+}
+
+// enqueueEnrichment schedules the metadata lookup for a book's ISBN.
+// Enrichment is best effort, so failures are logged rather than returned.
+func (h *Handler) enqueueEnrichment(c *gin.Context, book models.Book) {
+	payload := task.PayloadEnrichBookMetadata{
+		BookId: book.ID.String(),
+		ISBN:   book.ISBN,
+	}
+	if err := h.TaskDistributor.DistributeBookMetadataEnrichment(c, payload); err != nil {
+		log.Printf("Failed to enqueue metadata enrichment for book %s: %v", book.ID, err)
+	}
 }
@@ -3,30 +3,49 @@ package books
 import (
 	"net/http"
 
-	"github.com/DMaryanskiy/bookshare-api/internal/db"
 	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"github.com/DMaryanskiy/bookshare-api/internal/pagination"
 	"github.com/gin-gonic/gin"
 )
 
+func bookCursor(b models.Book) pagination.Cursor {
+	return pagination.Cursor{CreatedAt: b.CreatedAt, ID: b.ID}
+}
+
 // ListBooks godoc
 // @Summary      List all books
-// @Description  Returns a list of all books owned by the authenticated user
+// @Description  Returns a page of books owned by the authenticated user
 // @Tags         books
 // @Produce      json
-// @Success      200  {array}   models.Book  "List of books"
+// @Param        limit           query     int     false  "Page size (default 20, max 100)"
+// @Param        cursor          query     string  false  "Cursor from a previous page's next_cursor"
+// @Param        sort            query     string  false  "created_at or -created_at (default)"
+// @Param        author          query     string  false  "Filter by author (case-insensitive)"
+// @Param        created_after   query     string  false  "Only books created at or after this RFC3339 time"
+// @Param        created_before  query     string  false  "Only books created before this RFC3339 time"
+// @Success      200  {object}  pagination.Page[BookResponse]  "Page of books"
+// @Failure      400  {object}  map[string]string  "Invalid pagination or filter"
 // @Failure      500  {object}  map[string]string  "Could not fetch books"
 // @Router       /books [get]
 func (h *Handler) ListBooks(c *gin.Context) {
 	userID := c.GetString("user_id")
-	var books []models.Book
 
-	if err := db.DB.Table("books.books").
-		Where("user_id = ?", userID).
-		Find(&books).
-		Order("created_at desc").Error; err != nil {
+	params, err := pagination.Parse(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := h.ReadDB.Table("books.books").Where("user_id = ?", userID)
+	if author := c.Query("author"); author != "" {
+		query = query.Where("lower(author) = lower(?)", author)
+	}
+
+	var books []models.Book
+	if err := params.Apply(query).Find(&books).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch books"})
 		return
 	}
 
-	c.JSON(http.StatusOK, books)
+	c.JSON(http.StatusOK, pagination.MapPage(pagination.NewPage(books, params, bookCursor), newBookResponse))
 }
@@ -0,0 +1,122 @@
+package books
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+)
+
+// Matched terms are wrapped in <mark> tags in the highlights
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>"
+
+// escapeHTML is the SQL for col with HTML special characters escaped.
+// Highlights are meant to be rendered as HTML, so the book's text is
+// escaped before ts_headline adds the <mark> tags.
+func escapeHTML(col string) string {
+	return `replace(replace(replace(replace(replace(` + col +
+		`, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;')`
+}
+
+var searchQuery = `
+SELECT b.id, b.user_id, b.title, b.author, b.description, b.visibility, b.created_at, b.updated_at,
+  ts_rank(b.search_vector, q) AS rank,
+  ts_headline('english', ` + escapeHTML("b.title") + `, q, '` + headlineOptions + `, HighlightAll=true') AS title_highlight,
+  ts_headline('english', ` + escapeHTML("coalesce(b.author, '')") + `, q, '` + headlineOptions + `, HighlightAll=true') AS author_highlight,
+  ts_headline('english', ` + escapeHTML("coalesce(b.description, '')") + `, q, '` + headlineOptions + `, MaxFragments=2, MinWords=5, MaxWords=20') AS description_highlight
+FROM books.books b, websearch_to_tsquery('english', ?) q
+WHERE b.search_vector @@ q
+  AND (b.user_id = ? OR b.visibility = ?)
+ORDER BY rank DESC, b.created_at DESC
+LIMIT ?`
+
+type SearchHighlights struct {
+	Title       string `json:"title"`
+	Author      string `json:"author"`
+	Description string `json:"description"`
+}
+
+type SearchResult struct {
+	ID          uuid.UUID             `json:"id"`
+	OwnerID     uuid.UUID             `json:"owner_id"`
+	Title       string                `json:"title"`
+	Author      string                `json:"author"`
+	Description string                `json:"description"`
+	Visibility  models.BookVisibility `json:"visibility"`
+	Rank        float64               `json:"rank"`
+	Highlights  SearchHighlights      `json:"highlights"`
+}
+
+type searchRow struct {
+	models.Book          `gorm:"embedded"`
+	Rank                 float64
+	TitleHighlight       string
+	AuthorHighlight      string
+	DescriptionHighlight string
+}
+
+// SearchBooks godoc
+// @Summary      Search books
+// @Description  Full-text search over title, author and description of the user's own and public books, ranked by relevance
+// @Tags         books
+// @Produce      json
+// @Param        q      query     string  true   "Search query (web search syntax)"
+// @Param        limit  query     int     false  "Maximum number of results (default 20, max 50)"
+// @Success      200  {array}   SearchResult  "Ranked search results"
+// @Failure      400  {object}  map[string]string  "Missing or invalid query"
+// @Failure      500  {object}  map[string]string  "Could not search books"
+// @Router       /books/search [get]
+func (h *Handler) SearchBooks(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query parameter q is required"})
+		return
+	}
+
+	limit := defaultSearchLimit
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 50"})
+			return
+		}
+		limit = n
+	}
+
+	var rows []searchRow
+	if err := h.ReadDB.Raw(searchQuery, q, userID, models.BookVisibilityPublic, limit).
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not search books"})
+		return
+	}
+
+	results := make([]SearchResult, 0, len(rows))
+	for _, row := range rows {
+		results = append(results, SearchResult{
+			ID:          row.ID,
+			OwnerID:     row.UserID,
+			Title:       row.Title,
+			Author:      row.Author,
+			Description: row.Description,
+			Visibility:  row.Visibility,
+			Rank:        row.Rank,
+			Highlights: SearchHighlights{
+				Title:       row.TitleHighlight,
+				Author:      row.AuthorHighlight,
+				Description: row.DescriptionHighlight,
+			},
+		})
+	}
+
+	c.JSON(http.StatusOK, results)
+}
@@ -0,0 +1,54 @@
+package books_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/books"
+	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"github.com/DMaryanskiy/bookshare-api/internal/tests"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponses_NoSensitiveFields(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	owner, _ := tests.CreateTestUser(t, gdb, "owner@example.com", "password123")
+	_, token := tests.CreateTestUser(t, gdb, "browser@example.com", "password123")
+	r := setupBookRouter(gdb)
+
+	shared := models.Book{Title: "Shared Dragons", UserID: owner.ID, Visibility: models.BookVisibilityPublic}
+	require.NoError(t, gdb.Table("books.books").Create(&shared).Error)
+
+	send := func(method, path string, body any) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			_ = json.NewEncoder(&buf).Encode(body)
+		}
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, &buf)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", tests.GetAuthHeader(token))
+		r.ServeHTTP(w, req)
+
+		require.Less(t, w.Code, 300, "%s %s: %s", method, path, w.Body.String())
+		tests.RequireNoSensitiveFields(t, w.Body.Bytes())
+		return w
+	}
+
+	w := send(http.MethodPost, "/books", map[string]string{"title": "My Dragons", "isbn": "0-13-419044-0"})
+	var created books.BookResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	send(http.MethodGet, "/books", nil)
+	send(http.MethodGet, "/books/"+created.ID.String(), nil)
+	send(http.MethodPut, "/books/"+created.ID.String(), map[string]string{"title": "Our Dragons"})
+	send(http.MethodGet, "/books/search?q=dragons", nil)
+	send(http.MethodGet, "/catalog", nil)
+	send(http.MethodGet, "/catalog/"+shared.ID.String(), nil)
+	send(http.MethodDelete, "/books/"+created.ID.String(), nil)
+}
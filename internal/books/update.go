@@ -3,8 +3,8 @@ package books
 import (
 	"net/http"
 
-	"github.com/DMaryanskiy/bookshare-api/internal/db"
 	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"github.com/DMaryanskiy/bookshare-api/internal/metadata"
 	"github.com/gin-gonic/gin"
 )
 
@@ -16,7 +16,7 @@ import (
 // @Produce      json
 // @Param        id    path      string     true  "Book ID"
 // @Param        book  body      BookInput  true  "Updated book data"
-// @Success      200  {object}  models.Book  "Updated book"
+// @Success      200  {object}  BookResponse  "Updated book"
 // @Failure      400  {object}  map[string]string  "Invalid input"
 // @Failure      404  {object}  map[string]string  "Book not found"
 // @Failure      500  {object}  map[string]string  "Failed to update book"
@@ -26,7 +26,7 @@ func (h *Handler) UpdateBook(c *gin.Context) {
 	bookID := c.Param("id")
 
 	var book models.Book
-	if err := db.DB.Table("books.books").
+	if err := h.DB.Table("books.books").
 		Where("id = ? AND user_id = ?", bookID, userID).
 		First(&book).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
@@ -42,11 +42,36 @@ func (h *Handler) UpdateBook(c *gin.Context) {
 	book.Title = req.Title
 	book.Author = req.Author
 	book.Description = req.Description
+	if req.Visibility != "" {
+		book.Visibility = models.BookVisibility(req.Visibility)
+	}
+
+	isbnChanged := false
+	if req.ISBN != "" {
+		isbn, err := metadata.NormalizeISBN(req.ISBN)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid isbn"})
+			return
+		}
+		if isbn != book.ISBN {
+			// Metadata from the previous edition no longer applies
+			book.ISBN = isbn
+			book.CoverURL = ""
+			book.Publisher = ""
+			book.PageCount = 0
+			book.PublicationYear = 0
+			isbnChanged = true
+		}
+	}
 
-	if err := db.DB.Table("books.books").Save(&book).Error; err != nil {
+	if err := h.DB.Table("books.books").Save(&book).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not update book"})
 		return
 	}
 
-	c.JSON(http.StatusOK, book)
+	if isbnChanged {
+		h.enqueueEnrichment(c, book)
+	}
+
+	c.JSON(http.StatusOK, newBookResponse(book))
 }
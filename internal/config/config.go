@@ -0,0 +1,250 @@
+// Package config reads the settings of every binary in one place. Values
+// come from an optional YAML file named by CONFIG_FILE, overridden by
+// environment variables, and are validated before anything is started.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/middleware"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	HTTP      HTTPConfig      `yaml:"http"`
+	DB        DBConfig        `yaml:"db"`
+	Redis     RedisConfig     `yaml:"redis"`
+	JWT       JWTConfig       `yaml:"jwt"`
+	SMTP      SMTPConfig      `yaml:"smtp"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Metadata  MetadataConfig  `yaml:"metadata"`
+}
+
+type HTTPConfig struct {
+	// Port the API listens on (PORT).
+	Port int `yaml:"port"`
+	// Host the API is reached at, used in links sent by email (HOST).
+	Host string `yaml:"host"`
+}
+
+type DBConfig struct {
+	// Source is the Postgres connection string (DB_SOURCE).
+	Source string `yaml:"source"`
+	// ReplicaSource optionally names a read replica that listings and
+	// search are served from (DB_REPLICA_SOURCE).
+	ReplicaSource string `yaml:"replica_source"`
+}
+
+type RedisConfig struct {
+	Addr string `yaml:"addr"` // REDIS_ADDR
+}
+
+type JWTConfig struct {
+	Secret    string `yaml:"secret"`     // JWT_SECRET
+	KeysDir   string `yaml:"keys_dir"`   // JWT_KEYS_DIR
+	ActiveKID string `yaml:"active_kid"` // JWT_ACTIVE_KID
+}
+
+type SMTPConfig struct {
+	Host string `yaml:"host"` // SMTP_HOST
+	Port int    `yaml:"port"` // SMTP_PORT
+	User string `yaml:"user"` // SMTP_USER
+	Pass string `yaml:"pass"` // SMTP_PASS
+}
+
+type RateLimitConfig struct {
+	RulesFile     string `yaml:"rules_file"`     // RATE_LIMIT_RULES_FILE
+	FailurePolicy string `yaml:"failure_policy"` // RATE_LIMIT_FAILURE_POLICY
+}
+
+type MetadataConfig struct {
+	File string `yaml:"file"` // ISBN_METADATA_FILE
+}
+
+// Section names a group of settings a binary needs. Load only requires the
+// settings of the sections it is given; values that are set are always
+// checked.
+type Section string
+
+const (
+	SectionHTTP      Section = "http"
+	SectionDB        Section = "db"
+	SectionRedis     Section = "redis"
+	SectionJWT       Section = "jwt"
+	SectionSMTP      Section = "smtp"
+	SectionRateLimit Section = "rate_limit"
+	SectionMetadata  Section = "metadata"
+)
+
+func defaults() Config {
+	return Config{
+		HTTP:      HTTPConfig{Port: 8080, Host: "localhost"},
+		RateLimit: RateLimitConfig{RulesFile: "data/rate_limits.yaml", FailurePolicy: "local"},
+		Metadata:  MetadataConfig{File: "data/isbn_metadata.json"},
+	}
+}
+
+// Load builds the configuration and validates it for sections. With DEBUG
+// set, a .env file in the working directory is loaded first if there is
+// one. All problems are reported together.
+func Load(sections ...Section) (*Config, error) {
+	if os.Getenv("DEBUG") != "" {
+		if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("load .env: %w", err)
+		}
+	}
+
+	cfg := defaults()
+	if file := os.Getenv("CONFIG_FILE"); file != "" {
+		if err := cfg.readFile(file); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := errors.Join(cfg.applyEnv(), cfg.Validate(sections...)); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return &cfg, nil
+}
+
+func (c *Config) readFile(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse config file %s: %w", file, err)
+	}
+	return nil
+}
+
+// applyEnv overrides settings with the environment variables that are set
+// and not empty.
+func (c *Config) applyEnv() error {
+	str := func(v *string) func(string) error {
+		return func(s string) error { *v = s; return nil }
+	}
+	num := func(v *int) func(string) error {
+		return func(s string) error {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return errors.New("must be a number")
+			}
+			*v = n
+			return nil
+		}
+	}
+
+	vars := []struct {
+		name string
+		set  func(string) error
+	}{
+		{"PORT", num(&c.HTTP.Port)},
+		{"HOST", str(&c.HTTP.Host)},
+		{"DB_SOURCE", str(&c.DB.Source)},
+		{"DB_REPLICA_SOURCE", str(&c.DB.ReplicaSource)},
+		{"REDIS_ADDR", str(&c.Redis.Addr)},
+		{"JWT_SECRET", str(&c.JWT.Secret)},
+		{"JWT_KEYS_DIR", str(&c.JWT.KeysDir)},
+		{"JWT_ACTIVE_KID", str(&c.JWT.ActiveKID)},
+		{"SMTP_HOST", str(&c.SMTP.Host)},
+		{"SMTP_PORT", num(&c.SMTP.Port)},
+		{"SMTP_USER", str(&c.SMTP.User)},
+		{"SMTP_PASS", str(&c.SMTP.Pass)},
+		{"RATE_LIMIT_RULES_FILE", str(&c.RateLimit.RulesFile)},
+		{"RATE_LIMIT_FAILURE_POLICY", str(&c.RateLimit.FailurePolicy)},
+		{"ISBN_METADATA_FILE", str(&c.Metadata.File)},
+	}
+
+	var errs []error
+	for _, v := range vars {
+		value := os.Getenv(v.name)
+		if value == "" {
+			continue
+		}
+		if err := v.set(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", v.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks every value that is set, and that the settings needed by
+// sections are present.
+func (c *Config) Validate(sections ...Section) error {
+	need := make(map[Section]bool, len(sections))
+	for _, s := range sections {
+		need[s] = true
+	}
+
+	var errs []error
+	fail := func(format string, args ...any) { errs = append(errs, fmt.Errorf(format, args...)) }
+	validPort := func(p int) bool { return p > 0 && p <= 65535 }
+	fileExists := func(name, file string) {
+		if _, err := os.Stat(file); err != nil {
+			fail("%s: %v", name, err)
+		}
+	}
+
+	if !validPort(c.HTTP.Port) {
+		fail("PORT: %d is not a valid port", c.HTTP.Port)
+	}
+	if need[SectionHTTP] && c.HTTP.Host == "" {
+		fail("HOST is required")
+	}
+
+	if need[SectionDB] && c.DB.Source == "" {
+		fail("DB_SOURCE is required")
+	}
+
+	if need[SectionRedis] && c.Redis.Addr == "" {
+		fail("REDIS_ADDR is required")
+	}
+
+	if need[SectionJWT] {
+		if c.JWT.Secret == "" && c.JWT.KeysDir == "" {
+			fail("JWT_SECRET or JWT_KEYS_DIR is required")
+		}
+		if c.JWT.KeysDir != "" {
+			fileExists("JWT_KEYS_DIR", c.JWT.KeysDir)
+		}
+	}
+
+	if c.SMTP.Port != 0 && !validPort(c.SMTP.Port) {
+		fail("SMTP_PORT: %d is not a valid port", c.SMTP.Port)
+	}
+	if need[SectionSMTP] {
+		if c.SMTP.Host == "" {
+			fail("SMTP_HOST is required")
+		}
+		if c.SMTP.Port == 0 {
+			fail("SMTP_PORT is required")
+		}
+		if c.SMTP.User == "" {
+			fail("SMTP_USER is required")
+		}
+	}
+
+	if _, err := middleware.ParseFailurePolicy(c.RateLimit.FailurePolicy); err != nil {
+		fail("RATE_LIMIT_FAILURE_POLICY: %v", err)
+	}
+	if need[SectionRateLimit] {
+		fileExists("RATE_LIMIT_RULES_FILE", c.RateLimit.RulesFile)
+	}
+
+	if need[SectionMetadata] {
+		fileExists("ISBN_METADATA_FILE", c.Metadata.File)
+	}
+
+	return errors.Join(errs...)
+}
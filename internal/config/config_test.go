@@ -0,0 +1,100 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+// clearEnv unsets every variable Load reads for the duration of the test.
+func clearEnv(t *testing.T) {
+	for _, name := range []string{
+		"DEBUG", "CONFIG_FILE", "PORT", "HOST", "DB_SOURCE", "DB_REPLICA_SOURCE", "REDIS_ADDR",
+		"JWT_SECRET", "JWT_KEYS_DIR", "JWT_ACTIVE_KID",
+		"SMTP_HOST", "SMTP_PORT", "SMTP_USER", "SMTP_PASS",
+		"RATE_LIMIT_RULES_FILE", "RATE_LIMIT_FAILURE_POLICY", "ISBN_METADATA_FILE",
+	} {
+		t.Setenv(name, "")
+	}
+}
+
+func TestLoad_EnvOverridesFile(t *testing.T) {
+	clearEnv(t)
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+http:
+  port: 9090
+db:
+  source: postgres://file
+redis:
+  addr: redis:6379
+smtp:
+  host: smtp.example.com
+  port: 587
+  user: mailer@example.com
+`), 0o644))
+
+	t.Setenv("CONFIG_FILE", file)
+	t.Setenv("DB_SOURCE", "postgres://env")
+	t.Setenv("SMTP_PORT", "2525")
+
+	cfg, err := config.Load(config.SectionDB, config.SectionRedis, config.SectionSMTP)
+	require.NoError(t, err)
+
+	require.Equal(t, 9090, cfg.HTTP.Port)
+	require.Equal(t, "localhost", cfg.HTTP.Host)
+	require.Equal(t, "postgres://env", cfg.DB.Source)
+	require.Equal(t, "redis:6379", cfg.Redis.Addr)
+	require.Equal(t, config.SMTPConfig{Host: "smtp.example.com", Port: 2525, User: "mailer@example.com"}, cfg.SMTP)
+	require.Equal(t, "local", cfg.RateLimit.FailurePolicy)
+}
+
+func TestLoad_ReportsEveryProblem(t *testing.T) {
+	clearEnv(t)
+
+	t.Setenv("PORT", "eighty")
+	t.Setenv("SMTP_PORT", "70000")
+	t.Setenv("RATE_LIMIT_FAILURE_POLICY", "sometimes")
+	t.Setenv("ISBN_METADATA_FILE", filepath.Join(t.TempDir(), "missing.json"))
+
+	_, err := config.Load(config.SectionDB, config.SectionRedis, config.SectionJWT, config.SectionSMTP, config.SectionMetadata)
+	require.Error(t, err)
+
+	for _, want := range []string{
+		"PORT: must be a number",
+		"SMTP_PORT: 70000 is not a valid port",
+		`RATE_LIMIT_FAILURE_POLICY: "sometimes"`,
+		"DB_SOURCE is required",
+		"REDIS_ADDR is required",
+		"JWT_SECRET or JWT_KEYS_DIR is required",
+		"SMTP_HOST is required",
+		"SMTP_USER is required",
+		"ISBN_METADATA_FILE: stat",
+	} {
+		require.ErrorContains(t, err, want)
+	}
+}
+
+func TestLoad_OnlyRequiresRequestedSections(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DB_SOURCE", "postgres://db")
+
+	cfg, err := config.Load(config.SectionDB)
+	require.NoError(t, err)
+	require.Equal(t, config.DBConfig{Source: "postgres://db"}, cfg.DB)
+}
+
+func TestLoad_RejectsUnknownFileKeys(t *testing.T) {
+	clearEnv(t)
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("db:\n  sorce: postgres://typo\n"), 0o644))
+	t.Setenv("CONFIG_FILE", file)
+
+	_, err := config.Load()
+	require.ErrorContains(t, err, "sorce")
+}
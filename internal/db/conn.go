@@ -1,22 +1,49 @@
 package db
 
 import (
+	"fmt"
 	"log"
-	"os"
 
+	"github.com/DMaryanskiy/bookshare-api/internal/config"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-var DB *gorm.DB
+// Conns are the database connections a binary hands to its components.
+// Replica serves reads that can lag slightly behind writes (listings and
+// search); it is the same connection as Primary when no replica is
+// configured.
+type Conns struct {
+	Primary *gorm.DB
+	Replica *gorm.DB
+}
 
-func InitDB() {
-	dsn := os.Getenv("DB_SOURCE")
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+// Open connects to the Postgres database at source.
+func Open(source string) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(source), &gorm.Config{})
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		return nil, fmt.Errorf("connect to database: %w", err)
 	}
+	return db, nil
+}
 
-	DB = db
+// Connect opens the primary database and, if cfg names one, the read
+// replica.
+func Connect(cfg config.DBConfig) (Conns, error) {
+	primary, err := Open(cfg.Source)
+	if err != nil {
+		return Conns{}, err
+	}
 	log.Println("Database connected")
+
+	if cfg.ReplicaSource == "" {
+		return Conns{Primary: primary, Replica: primary}, nil
+	}
+
+	replica, err := Open(cfg.ReplicaSource)
+	if err != nil {
+		return Conns{}, fmt.Errorf("replica: %w", err)
+	}
+	log.Println("Read replica connected")
+	return Conns{Primary: primary, Replica: replica}, nil
 }
@@ -7,9 +7,14 @@ import (
 )
 
 type AuditLog struct {
-	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
-	UserID    uuid.UUID `gorm:"type:uuid"`
-	Action    string    `gorm:"not null"`
-	Metadata  string    // optional JSON string
-	CreatedAt time.Time `gorm:"autoCreateTime"`
+	ID        uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	UserID    *uuid.UUID `gorm:"type:uuid"`
+	Action    string     `gorm:"not null"`
+	Metadata  *string    `gorm:"type:jsonb"` // optional JSON document
+	CreatedAt time.Time  `gorm:"autoCreateTime"`
+
+	// Hash chain, assigned when the entry is inserted
+	Seq      int64  `gorm:"not null;uniqueIndex"`
+	PrevHash string `gorm:"default:null"`
+	Hash     string `gorm:"default:null"`
 }
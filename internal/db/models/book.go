@@ -6,12 +6,28 @@ import (
 	"github.com/google/uuid"
 )
 
+type BookVisibility string
+
+const (
+	BookVisibilityPrivate BookVisibility = "private"
+	BookVisibilityPublic  BookVisibility = "public"
+)
+
 type Book struct {
 	ID          uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
 	UserID      uuid.UUID `gorm:"type:uuid;not null"`
 	Title       string    `gorm:"not null"`
 	Author      string
 	Description string
-	CreatedAt   time.Time `gorm:"autoCreateTime"`
-	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+	Visibility  BookVisibility `gorm:"not null;default:private"`
+
+	// Filled in asynchronously from the ISBN
+	ISBN            string
+	CoverURL        string
+	Publisher       string
+	PageCount       int
+	PublicationYear int
+
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
 }
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type LoanStatus string
+
+const (
+	LoanStatusRequested LoanStatus = "requested"
+	LoanStatusApproved  LoanStatus = "approved"
+	LoanStatusRejected  LoanStatus = "rejected"
+	LoanStatusReturned  LoanStatus = "returned"
+)
+
+type Loan struct {
+	ID         uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	BookID     uuid.UUID  `gorm:"type:uuid;not null"`
+	OwnerID    uuid.UUID  `gorm:"type:uuid;not null"`
+	BorrowerID uuid.UUID  `gorm:"type:uuid;not null"`
+	Status     LoanStatus `gorm:"not null;default:requested"`
+	Message    string
+	DecidedAt  *time.Time
+	ReturnedAt *time.Time
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime"`
+}
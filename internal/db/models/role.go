@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+type Role struct {
+	Name        string `gorm:"primaryKey"`
+	Description string
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+}
+
+type Permission struct {
+	Name        string `gorm:"primaryKey"`
+	Description string
+}
+
+type RolePermission struct {
+	Role       string `gorm:"primaryKey"`
+	Permission string `gorm:"primaryKey"`
+}
@@ -6,12 +6,20 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
 	ID           uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
 	Email        string    `gorm:"uniqueIndex;not null"`
-	PasswordHash string    `gorm:"not null"`
+	PasswordHash string    `gorm:"not null" json:"-"`
 	IsVerified    bool      `gorm:"default:false"`
 	Role         string    `gorm:"default:user"`
+	TOTPSecret   *string   `gorm:"column:totp_secret" json:"-"`
+	TOTPEnabled  bool      `gorm:"column:totp_enabled;default:false"`
+	SuspendedAt  *time.Time
 	CreatedAt    time.Time `gorm:"autoCreateTime"`
 	UpdatedAt    time.Time `gorm:"autoCreateTime"`
 }
@@ -6,11 +6,19 @@ import (
 	"github.com/google/uuid"
 )
 
+type TokenPurpose string
+
+const (
+	TokenPurposeEmailVerification TokenPurpose = "email_verification"
+	TokenPurposePasswordReset     TokenPurpose = "password_reset"
+)
+
 type VerificationToken struct {
-	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
-	UserID    uuid.UUID `gorm:"type:uuid;not null"`
-	Token     string    `gorm:"uniqueIndex;not null"`
-	ExpiresAt time.Time `gorm:"not null"`
-	CreatedAt time.Time `gorm:"autoCreateTime"`
-	UpdatedAt time.Time `gorm:"autoCreateTime"`
+	ID        uuid.UUID    `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	UserID    uuid.UUID    `gorm:"type:uuid;not null"`
+	Token     string       `gorm:"uniqueIndex;not null"`
+	Purpose   TokenPurpose `gorm:"not null;default:email_verification"`
+	ExpiresAt time.Time    `gorm:"not null"`
+	CreatedAt time.Time    `gorm:"autoCreateTime"`
+	UpdatedAt time.Time    `gorm:"autoCreateTime"`
 }
@@ -1,10 +1,7 @@
 package email
 
 import (
-	"log"
-	"os"
-	"strconv"
-
+	"github.com/DMaryanskiy/bookshare-api/internal/config"
 	"gopkg.in/mail.v2"
 )
 
@@ -16,19 +13,13 @@ type EmailSender struct {
 	Pass string
 }
 
-func NewEmailSender() *EmailSender {
-	portStr := os.Getenv("SMTP_PORT")
-	port, err := strconv.Atoi(portStr)
-	if err != nil {
-		log.Fatalln("failed to convert port to int:", err)
-	}
-
+func NewEmailSender(cfg config.SMTPConfig) *EmailSender {
 	return &EmailSender{
-		From: os.Getenv("SMTP_USER"),
-		Host: os.Getenv("SMTP_HOST"),
-		Port: port,
-		User: os.Getenv("SMTP_USER"),
-		Pass: os.Getenv("SMTP_PASS"),
+		From: cfg.User,
+		Host: cfg.Host,
+		Port: cfg.Port,
+		User: cfg.User,
+		Pass: cfg.Pass,
 	}
 }
 
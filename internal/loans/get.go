@@ -0,0 +1,43 @@
+package loans
+
+import (
+	"net/http"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"github.com/gin-gonic/gin"
+)
+
+// GetLoan godoc
+// @Summary      Get a loan
+// @Description  Retrieves a loan where the authenticated user is the borrower or the owner
+// @Tags         loans
+// @Produce      json
+// @Param        id   path      string  true  "Loan ID"
+// @Success      200  {object}  LoanResponse  "Loan retrieved successfully"
+// @Failure      404  {object}  map[string]string  "Loan not found"
+// @Router       /loans/{id} [get]
+func (h *Handler) GetLoan(c *gin.Context) {
+	loan, ok := h.findLoan(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, newLoanResponse(loan))
+}
+
+// findLoan loads the loan from the path if the current user is a party to it,
+// writing a 404 response otherwise.
+func (h *Handler) findLoan(c *gin.Context) (models.Loan, bool) {
+	userID := c.GetString("user_id")
+	loanID := c.Param("id")
+
+	var loan models.Loan
+	if err := h.DB.Table("books.loans").
+		Where("id = ? AND (borrower_id = ? OR owner_id = ?)", loanID, userID, userID).
+		First(&loan).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "loan not found"})
+		return loan, false
+	}
+
+	return loan, true
+}
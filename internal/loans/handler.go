@@ -0,0 +1,83 @@
+package loans
+
+import (
+	"errors"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
+	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+type Handler struct {
+	DB    *gorm.DB
+	Audit *audit.Logger
+}
+
+func NewHandler(gdb *gorm.DB, auditLog *audit.Logger) *Handler {
+	return &Handler{DB: gdb, Audit: auditLog}
+}
+
+type LoanInput struct {
+	BookID  string `json:"book_id" binding:"required,uuid"`
+	Message string `json:"message" binding:"max=500"`
+}
+
+// LoanResponse is a loan as either party to it sees it.
+type LoanResponse struct {
+	ID         uuid.UUID         `json:"id"`
+	BookID     uuid.UUID         `json:"book_id"`
+	OwnerID    uuid.UUID         `json:"owner_id"`
+	BorrowerID uuid.UUID         `json:"borrower_id"`
+	Status     models.LoanStatus `json:"status"`
+	Message    string            `json:"message"`
+	DecidedAt  *time.Time        `json:"decided_at"`
+	ReturnedAt *time.Time        `json:"returned_at"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+}
+
+func newLoanResponse(l models.Loan) LoanResponse {
+	return LoanResponse{
+		ID:         l.ID,
+		BookID:     l.BookID,
+		OwnerID:    l.OwnerID,
+		BorrowerID: l.BorrowerID,
+		Status:     l.Status,
+		Message:    l.Message,
+		DecidedAt:  l.DecidedAt,
+		ReturnedAt: l.ReturnedAt,
+		CreatedAt:  l.CreatedAt,
+		UpdatedAt:  l.UpdatedAt,
+	}
+}
+
+// transitions lists the statuses a loan may move to from each status.
+// Rejected and returned loans are final.
+var transitions = map[models.LoanStatus][]models.LoanStatus{
+	models.LoanStatusRequested: {models.LoanStatusApproved, models.LoanStatusRejected},
+	models.LoanStatusApproved:  {models.LoanStatusReturned},
+}
+
+// transitionActions is the audit action recorded for each target status.
+var transitionActions = map[models.LoanStatus]audit.Action{
+	models.LoanStatusApproved: audit.ActionLoanApproved,
+	models.LoanStatusRejected: audit.ActionLoanRejected,
+	models.LoanStatusReturned: audit.ActionLoanReturned,
+}
+
+func canTransition(from, to models.LoanStatus) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
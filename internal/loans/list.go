@@ -0,0 +1,52 @@
+package loans
+
+import (
+	"net/http"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"github.com/gin-gonic/gin"
+)
+
+// ListLoans godoc
+// @Summary      List loans
+// @Description  Returns loans where the authenticated user is the borrower or the owner
+// @Tags         loans
+// @Produce      json
+// @Param        role    query     string  false  "borrower or owner (default: both)"
+// @Param        status  query     string  false  "Filter by status"
+// @Success      200  {array}   LoanResponse  "List of loans"
+// @Failure      400  {object}  map[string]string  "Invalid filter"
+// @Failure      500  {object}  map[string]string  "Could not fetch loans"
+// @Router       /loans [get]
+func (h *Handler) ListLoans(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	query := h.DB.Table("books.loans")
+	switch c.Query("role") {
+	case "":
+		query = query.Where("borrower_id = ? OR owner_id = ?", userID, userID)
+	case "borrower":
+		query = query.Where("borrower_id = ?", userID)
+	case "owner":
+		query = query.Where("owner_id = ?", userID)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be borrower or owner"})
+		return
+	}
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var loans []models.Loan
+	if err := query.Order("created_at desc").Find(&loans).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch loans"})
+		return
+	}
+
+	resp := make([]LoanResponse, len(loans))
+	for i, loan := range loans {
+		resp[i] = newLoanResponse(loan)
+	}
+	c.JSON(http.StatusOK, resp)
+}
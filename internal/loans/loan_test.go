@@ -0,0 +1,219 @@
+package loans_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/auth"
+	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"github.com/DMaryanskiy/bookshare-api/internal/loans"
+	"github.com/DMaryanskiy/bookshare-api/internal/middleware"
+	"github.com/DMaryanskiy/bookshare-api/internal/tests"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupLoanRouter(gdb *gorm.DB) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+
+	// Middleware for JWT auth
+	router.Use(middleware.JWTAuthMiddleware(auth.NewDenylist(tests.TestRedis)))
+
+	lh := loans.NewHandler(gdb, tests.AuditLogger(gdb))
+	router.POST("/loans", lh.RequestLoan)
+	router.GET("/loans", lh.ListLoans)
+	router.GET("/loans/:id", lh.GetLoan)
+	router.POST("/loans/:id/approve", lh.ApproveLoan)
+	router.POST("/loans/:id/reject", lh.RejectLoan)
+	router.POST("/loans/:id/return", lh.ReturnLoan)
+
+	return router
+}
+
+func createBook(t *testing.T, gdb *gorm.DB, owner models.User) models.Book {
+	book := models.Book{
+		Title:      "Lendable Book",
+		Author:     "Some Author",
+		UserID:     owner.ID,
+		Visibility: models.BookVisibilityPublic,
+	}
+	require.NoError(t, gdb.Table("books.books").Create(&book).Error)
+	return book
+}
+
+func doRequest(r *gin.Engine, method, path, token string, body any) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		_ = json.NewEncoder(&buf).Encode(body)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", tests.GetAuthHeader(token))
+
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func requestLoan(t *testing.T, r *gin.Engine, token string, book models.Book) loans.LoanResponse {
+	w := doRequest(r, "POST", "/loans", token, map[string]string{"book_id": book.ID.String()})
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var loan loans.LoanResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &loan))
+	return loan
+}
+
+func TestLoan_FullLifecycle(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	owner, ownerToken := tests.CreateTestUser(t, gdb, "owner@example.com", "password123")
+	_, borrowerToken := tests.CreateTestUser(t, gdb, "borrower@example.com", "password123")
+	book := createBook(t, gdb, owner)
+
+	r := setupLoanRouter(gdb)
+
+	loan := requestLoan(t, r, borrowerToken, book)
+	require.Equal(t, models.LoanStatusRequested, loan.Status)
+
+	// Borrower cannot approve their own request
+	w := doRequest(r, "POST", "/loans/"+loan.ID.String()+"/approve", borrowerToken, nil)
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	w = doRequest(r, "POST", "/loans/"+loan.ID.String()+"/approve", ownerToken, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(r, "POST", "/loans/"+loan.ID.String()+"/return", borrowerToken, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var returned models.Loan
+	require.NoError(t, gdb.Table("books.loans").First(&returned, "id = ?", loan.ID).Error)
+	require.Equal(t, models.LoanStatusReturned, returned.Status)
+	require.NotNil(t, returned.ReturnedAt)
+}
+
+func TestLoan_ResponseUsesSnakeCase(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	owner, _ := tests.CreateTestUser(t, gdb, "owner6@example.com", "password123")
+	_, borrowerToken := tests.CreateTestUser(t, gdb, "borrower6@example.com", "password123")
+	book := createBook(t, gdb, owner)
+
+	r := setupLoanRouter(gdb)
+	requestLoan(t, r, borrowerToken, book)
+
+	w := doRequest(r, "GET", "/loans", borrowerToken, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var body []map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body, 1)
+	for _, key := range []string{"id", "book_id", "owner_id", "borrower_id", "status", "message", "decided_at", "returned_at", "created_at", "updated_at"} {
+		require.Contains(t, body[0], key)
+	}
+	require.Equal(t, book.ID.String(), body[0]["book_id"])
+	require.Equal(t, string(models.LoanStatusRequested), body[0]["status"])
+	require.NotContains(t, body[0], "BookID")
+}
+
+func TestLoan_InvalidTransition(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	owner, ownerToken := tests.CreateTestUser(t, gdb, "owner2@example.com", "password123")
+	_, borrowerToken := tests.CreateTestUser(t, gdb, "borrower2@example.com", "password123")
+	book := createBook(t, gdb, owner)
+
+	r := setupLoanRouter(gdb)
+
+	loan := requestLoan(t, r, borrowerToken, book)
+
+	// Cannot return a book that was never lent
+	w := doRequest(r, "POST", "/loans/"+loan.ID.String()+"/return", borrowerToken, nil)
+	require.Equal(t, http.StatusConflict, w.Code)
+
+	w = doRequest(r, "POST", "/loans/"+loan.ID.String()+"/reject", ownerToken, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// Rejected is final
+	w = doRequest(r, "POST", "/loans/"+loan.ID.String()+"/approve", ownerToken, nil)
+	require.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestLoan_BookAlreadyLent(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	owner, ownerToken := tests.CreateTestUser(t, gdb, "owner3@example.com", "password123")
+	_, firstToken := tests.CreateTestUser(t, gdb, "first@example.com", "password123")
+	_, secondToken := tests.CreateTestUser(t, gdb, "second@example.com", "password123")
+	book := createBook(t, gdb, owner)
+
+	r := setupLoanRouter(gdb)
+
+	first := requestLoan(t, r, firstToken, book)
+	second := requestLoan(t, r, secondToken, book)
+
+	w := doRequest(r, "POST", "/loans/"+first.ID.String()+"/approve", ownerToken, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(r, "POST", "/loans/"+second.ID.String()+"/approve", ownerToken, nil)
+	require.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestLoan_CannotBorrowOwnBook(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	owner, ownerToken := tests.CreateTestUser(t, gdb, "selfish@example.com", "password123")
+	book := createBook(t, gdb, owner)
+
+	r := setupLoanRouter(gdb)
+
+	w := doRequest(r, "POST", "/loans", ownerToken, map[string]string{"book_id": book.ID.String()})
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestLoan_HiddenFromOtherUsers(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	owner, _ := tests.CreateTestUser(t, gdb, "owner4@example.com", "password123")
+	_, borrowerToken := tests.CreateTestUser(t, gdb, "borrower4@example.com", "password123")
+	_, strangerToken := tests.CreateTestUser(t, gdb, "stranger@example.com", "password123")
+	book := createBook(t, gdb, owner)
+
+	r := setupLoanRouter(gdb)
+
+	loan := requestLoan(t, r, borrowerToken, book)
+
+	w := doRequest(r, "GET", "/loans/"+loan.ID.String(), strangerToken, nil)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestLoan_PrivateBookNotBorrowable(t *testing.T) {
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	owner, _ := tests.CreateTestUser(t, gdb, "private@example.com", "password123")
+	_, borrowerToken := tests.CreateTestUser(t, gdb, "borrower5@example.com", "password123")
+	book := models.Book{
+		Title:  "Private Book",
+		UserID: owner.ID,
+	}
+	require.NoError(t, gdb.Table("books.books").Create(&book).Error)
+
+	r := setupLoanRouter(gdb)
+
+	w := doRequest(r, "POST", "/loans", borrowerToken, map[string]string{"book_id": book.ID.String()})
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,70 @@
+package loans
+
+import (
+	"net/http"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
+	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestLoan godoc
+// @Summary      Request to borrow a book
+// @Description  Creates a borrow request for another user's public book; the owner must approve it
+// @Tags         loans
+// @Accept       json
+// @Produce      json
+// @Param        loan  body  LoanInput  true  "Book to borrow"
+// @Success      201  {object}  LoanResponse  "Loan requested"
+// @Failure      400  {object}  map[string]string  "Invalid input or own book"
+// @Failure      404  {object}  map[string]string  "Book not found"
+// @Failure      409  {object}  map[string]string  "Request already pending"
+// @Failure      500  {object}  map[string]string  "Failed to create loan"
+// @Router       /loans [post]
+func (h *Handler) RequestLoan(c *gin.Context) {
+	userID := uuid.MustParse(c.GetString("user_id"))
+
+	var req LoanInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+
+	// Only books shared in the public catalog can be borrowed
+	var book models.Book
+	if err := h.DB.Table("books.books").
+		Where("id = ? AND visibility = ?", req.BookID, models.BookVisibilityPublic).
+		First(&book).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
+		return
+	}
+
+	if book.UserID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot borrow your own book"})
+		return
+	}
+
+	loan := models.Loan{
+		BookID:     book.ID,
+		OwnerID:    book.UserID,
+		BorrowerID: userID,
+		Status:     models.LoanStatusRequested,
+		Message:    req.Message,
+	}
+
+	if err := h.DB.Table("books.loans").Create(&loan).Error; err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "a request for this book is already pending"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create loan"})
+		return
+	}
+
+	h.Audit.Log(userID, audit.ActionLoanRequested, audit.Request(c).
+		With("loan_id", loan.ID).
+		With("book_id", book.ID))
+
+	c.JSON(http.StatusCreated, newLoanResponse(loan))
+}
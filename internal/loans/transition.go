@@ -0,0 +1,120 @@
+package loans
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
+	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ApproveLoan godoc
+// @Summary      Approve a loan request
+// @Description  Lends the book to the borrower; only the owner may approve
+// @Tags         loans
+// @Produce      json
+// @Param        id   path      string  true  "Loan ID"
+// @Success      200  {object}  LoanResponse  "Loan approved"
+// @Failure      403  {object}  map[string]string  "Not the owner"
+// @Failure      404  {object}  map[string]string  "Loan not found"
+// @Failure      409  {object}  map[string]string  "Invalid state transition or book already lent"
+// @Failure      500  {object}  map[string]string  "Failed to update loan"
+// @Router       /loans/{id}/approve [post]
+func (h *Handler) ApproveLoan(c *gin.Context) {
+	h.transition(c, models.LoanStatusApproved)
+}
+
+// RejectLoan godoc
+// @Summary      Reject a loan request
+// @Description  Declines a pending borrow request; only the owner may reject
+// @Tags         loans
+// @Produce      json
+// @Param        id   path      string  true  "Loan ID"
+// @Success      200  {object}  LoanResponse  "Loan rejected"
+// @Failure      403  {object}  map[string]string  "Not the owner"
+// @Failure      404  {object}  map[string]string  "Loan not found"
+// @Failure      409  {object}  map[string]string  "Invalid state transition"
+// @Failure      500  {object}  map[string]string  "Failed to update loan"
+// @Router       /loans/{id}/reject [post]
+func (h *Handler) RejectLoan(c *gin.Context) {
+	h.transition(c, models.LoanStatusRejected)
+}
+
+// ReturnLoan godoc
+// @Summary      Return a borrowed book
+// @Description  Marks an approved loan as returned; only the borrower may return
+// @Tags         loans
+// @Produce      json
+// @Param        id   path      string  true  "Loan ID"
+// @Success      200  {object}  LoanResponse  "Loan returned"
+// @Failure      403  {object}  map[string]string  "Not the borrower"
+// @Failure      404  {object}  map[string]string  "Loan not found"
+// @Failure      409  {object}  map[string]string  "Invalid state transition"
+// @Failure      500  {object}  map[string]string  "Failed to update loan"
+// @Router       /loans/{id}/return [post]
+func (h *Handler) ReturnLoan(c *gin.Context) {
+	h.transition(c, models.LoanStatusReturned)
+}
+
+func (h *Handler) transition(c *gin.Context, to models.LoanStatus) {
+	userID := uuid.MustParse(c.GetString("user_id"))
+
+	loan, ok := h.findLoan(c)
+	if !ok {
+		return
+	}
+
+	// Owners decide on requests, borrowers hand the book back
+	actor := loan.OwnerID
+	if to == models.LoanStatusReturned {
+		actor = loan.BorrowerID
+	}
+	if userID != actor {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to change this loan"})
+		return
+	}
+
+	if !canTransition(loan.Status, to) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("cannot move loan from %s to %s", loan.Status, to)})
+		return
+	}
+
+	now := time.Now()
+	updates := map[string]any{"status": to}
+	if to == models.LoanStatusReturned {
+		updates["returned_at"] = now
+	} else {
+		updates["decided_at"] = now
+	}
+
+	// Guard on the current status so concurrent transitions cannot both win
+	res := h.DB.Table("books.loans").
+		Where("id = ? AND status = ?", loan.ID, loan.Status).
+		Updates(updates)
+	if res.Error != nil {
+		if isUniqueViolation(res.Error) {
+			c.JSON(http.StatusConflict, gin.H{"error": "book is already on loan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not update loan"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "loan was modified concurrently"})
+		return
+	}
+
+	if err := h.DB.Table("books.loans").First(&loan, "id = ?", loan.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch loan"})
+		return
+	}
+
+	h.Audit.Log(userID, transitionActions[to], audit.Request(c).
+		With("loan_id", loan.ID).
+		With("book_id", loan.BookID))
+
+	c.JSON(http.StatusOK, newLoanResponse(loan))
+}
@@ -0,0 +1,47 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// FileProvider serves metadata from a JSON file containing an array of
+// BookMetadata records. It needs no network access, which makes it suitable
+// for offline deployments and tests.
+type FileProvider struct {
+	records map[string]BookMetadata
+}
+
+func NewFileProvider(path string) (*FileProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read metadata file: %w", err)
+	}
+
+	var list []BookMetadata
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("parse metadata file: %w", err)
+	}
+
+	records := make(map[string]BookMetadata, len(list))
+	for _, m := range list {
+		isbn, err := NormalizeISBN(m.ISBN)
+		if err != nil {
+			return nil, fmt.Errorf("metadata file: %q: %w", m.ISBN, err)
+		}
+		m.ISBN = isbn
+		records[isbn] = m
+	}
+
+	return &FileProvider{records: records}, nil
+}
+
+func (p *FileProvider) Lookup(_ context.Context, isbn string) (*BookMetadata, error) {
+	m, ok := p.records[isbn]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &m, nil
+}
@@ -0,0 +1,73 @@
+package metadata
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalidISBN = errors.New("invalid isbn")
+
+// NormalizeISBN strips separators, validates the check digit and converts
+// ISBN-10 to ISBN-13 so every ISBN is stored in one canonical form.
+func NormalizeISBN(raw string) (string, error) {
+	s := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(raw))
+
+	switch len(s) {
+	case 10:
+		if !validISBN10(s) {
+			return "", ErrInvalidISBN
+		}
+		body := "978" + s[:9]
+		return body + isbn13CheckDigit(body), nil
+	case 13:
+		if !allDigits(s) || isbn13CheckDigit(s[:12]) != s[12:] {
+			return "", ErrInvalidISBN
+		}
+		return s, nil
+	default:
+		return "", ErrInvalidISBN
+	}
+}
+
+func validISBN10(s string) bool {
+	if !allDigits(s[:9]) {
+		return false
+	}
+
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(s[i]-'0') * (10 - i)
+	}
+
+	switch last := s[9]; {
+	case last == 'X':
+		sum += 10
+	case last >= '0' && last <= '9':
+		sum += int(last - '0')
+	default:
+		return false
+	}
+
+	return sum%11 == 0
+}
+
+func isbn13CheckDigit(body string) string {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		d := int(body[i] - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return string(rune('0' + (10-sum%10)%10))
+}
+
+func allDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
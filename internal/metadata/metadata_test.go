@@ -0,0 +1,51 @@
+package metadata_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/metadata"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeISBN(t *testing.T) {
+	cases := map[string]string{
+		"9780306406157":     "9780306406157",
+		"978-0-306-40615-7": "9780306406157",
+		"0306406152":        "9780306406157",
+		"0-306-40615-2":     "9780306406157",
+		"080442957X":        "9780804429573",
+	}
+
+	for in, want := range cases {
+		got, err := metadata.NormalizeISBN(in)
+		require.NoError(t, err, in)
+		require.Equal(t, want, got, in)
+	}
+}
+
+func TestNormalizeISBN_Invalid(t *testing.T) {
+	for _, in := range []string{"", "123", "9780306406158", "0306406153", "97803064061X7", "X306406152"} {
+		_, err := metadata.NormalizeISBN(in)
+		require.ErrorIs(t, err, metadata.ErrInvalidISBN, in)
+	}
+}
+
+func TestFileProvider_Lookup(t *testing.T) {
+	p, err := metadata.NewFileProvider("testdata/isbn.json")
+	require.NoError(t, err)
+
+	m, err := p.Lookup(context.Background(), "9780306406157")
+	require.NoError(t, err)
+	require.Equal(t, "Test Press", m.Publisher)
+	require.Equal(t, 320, m.PageCount)
+	require.Equal(t, 1999, m.PublicationYear)
+
+	_, err = p.Lookup(context.Background(), "9780804429573")
+	require.ErrorIs(t, err, metadata.ErrNotFound)
+}
+
+func TestFileProvider_MissingFile(t *testing.T) {
+	_, err := metadata.NewFileProvider("testdata/missing.json")
+	require.Error(t, err)
+}
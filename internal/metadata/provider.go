@@ -0,0 +1,25 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrNotFound = errors.New("no metadata found for isbn")
+
+// BookMetadata is what a provider knows about an edition.
+type BookMetadata struct {
+	ISBN            string `json:"isbn"`
+	Title           string `json:"title"`
+	Author          string `json:"author"`
+	Publisher       string `json:"publisher"`
+	CoverURL        string `json:"cover_url"`
+	PageCount       int    `json:"page_count"`
+	PublicationYear int    `json:"publication_year"`
+}
+
+// MetadataProvider resolves a normalized ISBN-13 to book metadata.
+// Implementations return ErrNotFound when the ISBN is unknown.
+type MetadataProvider interface {
+	Lookup(ctx context.Context, isbn string) (*BookMetadata, error)
+}
@@ -1,30 +0,0 @@
-package middleware
-
-import (
-	"net/http"
-
-	"github.com/DMaryanskiy/bookshare-api/internal/db"
-	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
-	"github.com/gin-gonic/gin"
-)
-
-func AdminOnly() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		userID := c.GetString("user_id")
-		var user models.User
-		if err := db.DB.Table("auth.users").First(&user, "id = ?", userID).Error; err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "no user found"})
-			c.Abort()
-			return
-		}
-
-		// TODO: add enum
-		if user.Role != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "admin access required"})
-			c.Abort()
-			return
-		}
-
-		c.Next()
-	}
-}
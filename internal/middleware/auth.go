@@ -4,11 +4,12 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/DMaryanskiy/bookshare-api/internal/auth"
 	"github.com/DMaryanskiy/bookshare-api/pkg/utils"
 	"github.com/gin-gonic/gin"
 )
 
-func JWTAuthMiddleware() gin.HandlerFunc {
+func JWTAuthMiddleware(denylist *auth.Denylist) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -32,8 +33,37 @@ func JWTAuthMiddleware() gin.HandlerFunc {
             return
 		}
 
+		revoked, err := denylist.IsRevoked(c, claims)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not validate token"})
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "token has been revoked"})
+			c.Abort()
+			return
+		}
+
+		suspended, err := denylist.IsSuspended(c, claims.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not validate token"})
+			c.Abort()
+			return
+		}
+		if suspended {
+			c.JSON(http.StatusForbidden, gin.H{"error": "account suspended"})
+			c.Abort()
+			return
+		}
+
 		// Inject user ID into context
         c.Set("user_id", claims.UserID)
+		c.Set("role", claims.Role)
+		c.Set("token_id", claims.ID)
+		if claims.ExpiresAt != nil {
+			c.Set("token_expires_at", claims.ExpiresAt.Time)
+		}
 
         c.Next()
 	}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (f *fakeClock) now() time.Time          { return f.t }
+func (f *fakeClock) advance(d time.Duration) { f.t = f.t.Add(d) }
+
+func TestBreaker_OpensAndProbes(t *testing.T) {
+	clock := &fakeClock{t: time.Now()}
+	b := newBreaker(2, 10*time.Second)
+	b.now = clock.now
+
+	require.True(t, b.allow())
+	require.False(t, b.failure())
+	require.True(t, b.allow())
+	require.True(t, b.failure(), "second failure opens the breaker")
+	require.Equal(t, breakerOpen, b.current())
+	require.False(t, b.allow())
+
+	// After the cooldown exactly one probe goes through
+	clock.advance(10 * time.Second)
+	require.True(t, b.allow())
+	require.Equal(t, breakerHalfOpen, b.current())
+	require.False(t, b.allow())
+
+	// A failed probe reopens for another cooldown
+	require.True(t, b.failure())
+	require.False(t, b.allow())
+
+	clock.advance(10 * time.Second)
+	require.True(t, b.allow())
+	b.success()
+	require.Equal(t, breakerClosed, b.current())
+	require.True(t, b.allow())
+}
+
+func TestBreaker_SuccessResetsFailures(t *testing.T) {
+	b := newBreaker(2, time.Second)
+
+	b.failure()
+	b.success()
+	require.False(t, b.failure())
+	require.Equal(t, breakerClosed, b.current())
+}
+
+func TestLocalLimiter_RefillsOverTime(t *testing.T) {
+	clock := &fakeClock{t: time.Now()}
+	l := newLocalLimiter()
+	l.now = clock.now
+
+	for i := 2; i >= 0; i-- {
+		res := l.allow("k", 3, 30*time.Second)
+		require.True(t, res.Allowed)
+		require.Equal(t, i, res.Remaining)
+	}
+
+	res := l.allow("k", 3, 30*time.Second)
+	require.False(t, res.Allowed)
+	require.Equal(t, 10*time.Second, res.RetryAfter)
+	require.Equal(t, 30*time.Second, res.ResetAfter)
+
+	// Other keys have their own bucket
+	require.True(t, l.allow("other", 3, 30*time.Second).Allowed)
+
+	clock.advance(10 * time.Second)
+	require.True(t, l.allow("k", 3, 30*time.Second).Allowed)
+	require.False(t, l.allow("k", 3, 30*time.Second).Allowed)
+}
+
+func TestLocalLimiter_SweepsFullBuckets(t *testing.T) {
+	clock := &fakeClock{t: time.Now()}
+	l := newLocalLimiter()
+	l.now = clock.now
+
+	l.allow("a", 1, time.Second)
+	clock.advance(localSweepInterval)
+	l.allow("b", 1, time.Hour)
+	require.Len(t, l.buckets, 1)
+}
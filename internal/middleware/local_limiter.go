@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// localLimiter is an in-process token bucket per key, used while Redis is
+// unavailable. Each API instance counts on its own, so a client spread
+// over several instances gets up to that many times its limit.
+type localLimiter struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*localBucket
+	lastSweep time.Time
+}
+
+type localBucket struct {
+	tokens  float64
+	updated time.Time
+	// full is when the bucket will have refilled completely, after which
+	// it is indistinguishable from a new one and can be dropped.
+	full time.Time
+}
+
+// localSweepInterval is how often full buckets are dropped.
+const localSweepInterval = time.Minute
+
+func newLocalLimiter() *localLimiter {
+	return &localLimiter{now: time.Now, buckets: make(map[string]*localBucket)}
+}
+
+func (l *localLimiter) allow(key string, limit int, window time.Duration) RateLimitResult {
+	result := RateLimitResult{Limit: limit}
+	if limit <= 0 {
+		result.RetryAfter, result.ResetAfter = window, window
+		return result
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	// Tokens per nanosecond
+	rate := float64(limit) / float64(window)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &localBucket{tokens: float64(limit), updated: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(limit), b.tokens+float64(now.Sub(b.updated))*rate)
+	b.updated = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration(math.Ceil((1 - b.tokens) / rate))
+	}
+
+	result.Remaining = int(b.tokens)
+	result.ResetAfter = time.Duration(math.Ceil((float64(limit) - b.tokens) / rate))
+	b.full = now.Add(result.ResetAfter)
+	return result
+}
+
+func (l *localLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < localSweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if !now.Before(b.full) {
+			delete(l.buckets, key)
+		}
+	}
+}
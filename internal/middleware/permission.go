@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
+	"github.com/DMaryanskiy/bookshare-api/internal/rbac"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequirePermission lets the request through only if the role in the
+// access token grants every one of perms, recording the decision in
+// auditLog. It must run after JWTAuthMiddleware; tokens without a role
+// claim get no permissions.
+func RequirePermission(roles *rbac.Store, auditLog *audit.Logger, perms ...rbac.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+		role := c.GetString("role")
+
+		for _, perm := range perms {
+			ok, err := roles.Has(c, role, perm)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "could not check permissions"})
+				c.Abort()
+				return
+			}
+			if !ok {
+				auditLog.Log(userID, audit.ActionAdminAccessDenied, accessMeta(c, role, perms).Failure("missing_permission"))
+				c.JSON(http.StatusForbidden, gin.H{"error": "permission required: " + string(perm)})
+				c.Abort()
+				return
+			}
+		}
+
+		auditLog.Log(userID, audit.ActionAdminAccessGranted, accessMeta(c, role, perms))
+
+		c.Next()
+	}
+}
+
+func accessMeta(c *gin.Context, role string, perms []rbac.Permission) audit.Meta {
+	return audit.Request(c).
+		With("method", c.Request.Method).
+		With("path", c.FullPath()).
+		With("role", role).
+		With("permissions", perms)
+}
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"slices"
+
+	"github.com/DMaryanskiy/bookshare-api/pkg/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+// API keys let services without a user account be counted, and given an
+// override, on their own. Only a SHA-256 hash of each key is kept:
+// rateLimitAPIKeysKey maps it to the key's id, and rateLimitAPIKeyIDsKey
+// maps the id back for listing and revoking.
+const (
+	rateLimitAPIKeysKey   = "rate_limit_api_keys"
+	rateLimitAPIKeyIDsKey = "rate_limit_api_key_ids"
+)
+
+var (
+	ErrInvalidAPIKeyID = errors.New("api key id must be 1 to 64 letters, digits, - or _")
+	ErrAPIKeyExists    = errors.New("api key id already in use")
+)
+
+var apiKeyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Keys are 256 random bits, so a fast hash is enough.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// createAPIKeyScript stores a key hash unless the id is taken.
+//
+// KEYS: key hashes, ids. ARGV: id, hash. Returns 1 if stored.
+var createAPIKeyScript = redis.NewScript(`
+if redis.call('HSETNX', KEYS[2], ARGV[1], ARGV[2]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[1], ARGV[2], ARGV[1])
+return 1
+`)
+
+// revokeAPIKeyScript deletes a key and the override of its client.
+//
+// KEYS: key hashes, ids, overrides hash. ARGV: id, client. Returns 1 if
+// the id had a key.
+var revokeAPIKeyScript = redis.NewScript(`
+local hash = redis.call('HGET', KEYS[2], ARGV[1])
+if not hash then
+	return 0
+end
+redis.call('HDEL', KEYS[1], hash)
+redis.call('HDEL', KEYS[2], ARGV[1])
+redis.call('HDEL', KEYS[3], ARGV[2])
+return 1
+`)
+
+// CreateAPIKey issues a key counted as the client "apikey:<id>". The key
+// itself is not stored, so this is the only time it can be read.
+func (r *RateLimiter) CreateAPIKey(ctx context.Context, id string) (string, error) {
+	if !apiKeyIDPattern.MatchString(id) {
+		return "", ErrInvalidAPIKeyID
+	}
+
+	key, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	created, err := createAPIKeyScript.Run(ctx, r.Redis, []string{rateLimitAPIKeysKey, rateLimitAPIKeyIDsKey}, id, hashAPIKey(key)).Bool()
+	if err != nil {
+		return "", err
+	}
+	if !created {
+		return "", ErrAPIKeyExists
+	}
+	return key, nil
+}
+
+// APIKeys returns the ids of every issued key, sorted.
+func (r *RateLimiter) APIKeys(ctx context.Context) ([]string, error) {
+	ids, err := r.Redis.HKeys(ctx, rateLimitAPIKeyIDsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// RevokeAPIKey reports whether id had a key. The key's override is removed
+// with it; its counters expire on their own.
+func (r *RateLimiter) RevokeAPIKey(ctx context.Context, id string) (bool, error) {
+	keys := []string{rateLimitAPIKeysKey, rateLimitAPIKeyIDsKey, rateLimitOverridesKey}
+	return revokeAPIKeyScript.Run(ctx, r.Redis, keys, id, ClientAPIKey+id).Bool()
+}
+
+// apiKeyClient returns the client an issued key is counted as, or "" if
+// key was never issued or has been revoked.
+func (r *RateLimiter) apiKeyClient(ctx context.Context, key string) (string, error) {
+	id, err := r.Redis.HGet(ctx, rateLimitAPIKeysKey, hashAPIKey(key)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return ClientAPIKey + id, nil
+}
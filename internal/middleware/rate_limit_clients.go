@@ -0,0 +1,156 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Clients are named by what they are counted by: "user:<id>" for
+// authenticated requests, "apikey:<id>" for anonymous ones with an issued
+// X-API-Key and "ip:<address>" for the rest.
+const (
+	ClientUser   = "user:"
+	ClientAPIKey = "apikey:"
+	ClientIP     = "ip:"
+)
+
+// rateLimitOverridesKey is a hash from client to the limit that replaces
+// the rule's limit for that client in every rule.
+const rateLimitOverridesKey = "rate_limit_overrides"
+
+var (
+	ErrInvalidClient   = errors.New("client must be user:<id>, apikey:<id> or ip:<address>")
+	ErrInvalidOverride = errors.New("overrides apply to user:<id> or apikey:<id> clients with a limit of 0 or more")
+)
+
+func validClient(client string, prefixes ...string) bool {
+	for _, prefix := range prefixes {
+		if rest, ok := strings.CutPrefix(client, prefix); ok && rest != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Overrides returns every client with an overridden limit.
+func (r *RateLimiter) Overrides(ctx context.Context) (map[string]int, error) {
+	raw, err := r.Redis.HGetAll(ctx, rateLimitOverridesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := make(map[string]int, len(raw))
+	for client, v := range raw {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			continue
+		}
+		overrides[client] = limit
+	}
+	return overrides, nil
+}
+
+// SetOverride makes limit apply to client instead of the limit of whichever
+// rule matches. Overrides are read from Redis on every request, so they
+// take effect on all instances straight away.
+func (r *RateLimiter) SetOverride(ctx context.Context, client string, limit int) error {
+	if limit < 0 || !validClient(client, ClientUser, ClientAPIKey) {
+		return ErrInvalidOverride
+	}
+	return r.Redis.HSet(ctx, rateLimitOverridesKey, client, limit).Err()
+}
+
+// DeleteOverride reports whether client had an override.
+func (r *RateLimiter) DeleteOverride(ctx context.Context, client string) (bool, error) {
+	n, err := r.Redis.HDel(ctx, rateLimitOverridesKey, client).Result()
+	return n > 0, err
+}
+
+// RateLimitUsage is a client's state in one rule's bucket.
+type RateLimitUsage struct {
+	Pattern string
+	// Limit is the one the bucket was last counted with, so it includes
+	// any override and can differ from the rule's current limit.
+	Limit     int
+	Remaining int
+	// ResetAfter is how long until the full limit is available again.
+	ResetAfter time.Duration
+}
+
+// peekScript reads buckets without counting a request. Returns the Redis
+// time in milliseconds, then the TAT and limit of each key, -1 if absent.
+var peekScript = redis.NewScript(`
+local t = redis.call('TIME')
+local out = {tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)}
+for _, key in ipairs(KEYS) do
+	local vals = redis.call('HMGET', key, 'tat', 'limit')
+	table.insert(out, tonumber(vals[1]) or -1)
+	table.insert(out, tonumber(vals[2]) or -1)
+end
+return out
+`)
+
+// Usage returns client's partly used buckets under the current rules.
+// Rules the client has not used recently are left out; their full limit
+// is available.
+func (r *RateLimiter) Usage(ctx context.Context, client string) ([]RateLimitUsage, error) {
+	if !validClient(client, ClientUser, ClientAPIKey, ClientIP) {
+		return nil, ErrInvalidClient
+	}
+
+	rules := r.Rules()
+	if len(rules) == 0 {
+		return []RateLimitUsage{}, nil
+	}
+	keys := make([]string, len(rules))
+	for i, rule := range rules {
+		keys[i] = bucketKey(rule.Pattern, client)
+	}
+
+	vals, err := peekScript.Run(ctx, r.Redis, keys).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	now := vals[0]
+	usage := []RateLimitUsage{}
+	for i, rule := range rules {
+		tat, limit := vals[1+2*i], vals[2+2*i]
+		if tat <= now || limit <= 0 {
+			continue
+		}
+
+		window := rule.Window.Milliseconds()
+		interval := float64(window) / float64(limit)
+		remaining := int(float64(window-(tat-now)) / interval)
+		usage = append(usage, RateLimitUsage{
+			Pattern:    rule.Pattern,
+			Limit:      int(limit),
+			Remaining:  max(remaining, 0),
+			ResetAfter: time.Duration(tat-now) * time.Millisecond,
+		})
+	}
+	return usage, nil
+}
+
+// Reset empties client's bucket in every current rule.
+func (r *RateLimiter) Reset(ctx context.Context, client string) error {
+	if !validClient(client, ClientUser, ClientAPIKey, ClientIP) {
+		return ErrInvalidClient
+	}
+
+	rules := r.Rules()
+	if len(rules) == 0 {
+		return nil
+	}
+	keys := make([]string, len(rules))
+	for i, rule := range rules {
+		keys[i] = bucketKey(rule.Pattern, client)
+	}
+	return r.Redis.Del(ctx, keys...).Err()
+}
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RateLimitRulesFile is the layout of the rules file. JSON is valid YAML,
+// so either format can be used:
+//
+//	rules:
+//	  - pattern: /api/v1/login/**
+//	    limit: 5
+//	    window: 1m
+//	    role_limits:
+//	      admin: 50
+type RateLimitRulesFile struct {
+	Rules []RateLimitRule `yaml:"rules"`
+}
+
+// ValidateRateLimitRules reports every problem with rules at once.
+func ValidateRateLimitRules(rules []RateLimitRule) error {
+	var errs []error
+	seen := make(map[string]bool)
+
+	for i, rule := range rules {
+		prefix := fmt.Sprintf("rule %d (%s)", i, rule.Pattern)
+
+		glob, _ := strings.CutSuffix(rule.Pattern, "/**")
+		switch {
+		case !strings.HasPrefix(rule.Pattern, "/"):
+			errs = append(errs, fmt.Errorf("%s: pattern must start with /", prefix))
+		case strings.Contains(glob, "**"):
+			errs = append(errs, fmt.Errorf("%s: ** is only allowed as a trailing /**", prefix))
+		default:
+			if _, err := path.Match(glob, ""); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", prefix, err))
+			}
+		}
+		if seen[rule.Pattern] {
+			errs = append(errs, fmt.Errorf("%s: duplicate pattern", prefix))
+		}
+		seen[rule.Pattern] = true
+
+		if rule.Limit < 0 {
+			errs = append(errs, fmt.Errorf("%s: limit must not be negative", prefix))
+		}
+		if rule.Window < time.Millisecond {
+			errs = append(errs, fmt.Errorf("%s: window must be at least 1ms", prefix))
+		}
+		for role, limit := range rule.RoleLimits {
+			if limit < 0 {
+				errs = append(errs, fmt.Errorf("%s: limit for role %q must not be negative", prefix, role))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// LoadRateLimitRules reads and validates a rules file.
+func LoadRateLimitRules(file string) ([]RateLimitRule, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read rate limit rules: %w", err)
+	}
+
+	var parsed RateLimitRulesFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&parsed); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse rate limit rules %s: %w", file, err)
+	}
+
+	if err := ValidateRateLimitRules(parsed.Rules); err != nil {
+		return nil, fmt.Errorf("invalid rate limit rules %s: %w", file, err)
+	}
+	return parsed.Rules, nil
+}
+
+// WatchRules reloads the rules from file whenever its modification time or
+// size changes, checking every interval until ctx is done. A file that
+// fails to load is logged and the current rules stay in place.
+func (r *RateLimiter) WatchRules(ctx context.Context, file string, interval time.Duration) {
+	// The first check always reloads, so a change made between the initial
+	// load and this call is not missed
+	var lastMod time.Time
+	lastSize := int64(-1)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(file)
+		if err != nil {
+			log.Printf("Rate limiter: cannot stat rules file: %v", err)
+			continue
+		}
+		if info.ModTime().Equal(lastMod) && info.Size() == lastSize {
+			continue
+		}
+		lastMod, lastSize = info.ModTime(), info.Size()
+
+		rules, err := LoadRateLimitRules(file)
+		if err != nil {
+			log.Printf("Rate limiter: keeping current rules: %v", err)
+			continue
+		}
+		if err := r.SetRules(rules); err != nil {
+			log.Printf("Rate limiter: keeping current rules: %v", err)
+			continue
+		}
+		log.Printf("Rate limiter: loaded %d rules from %s", len(rules), file)
+	}
+}
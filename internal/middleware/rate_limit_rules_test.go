@@ -0,0 +1,131 @@
+package middleware_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/middleware"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func writeRules(t *testing.T, file, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(file, []byte(content), 0o644))
+}
+
+func TestLoadRateLimitRules(t *testing.T) {
+	dir := t.TempDir()
+
+	yamlFile := filepath.Join(dir, "rules.yaml")
+	writeRules(t, yamlFile, `
+rules:
+  - pattern: /api/v1/login/**
+    limit: 5
+    window: 1m
+    role_limits:
+      admin: 50
+      anonymous: 2
+`)
+	rules, err := middleware.LoadRateLimitRules(yamlFile)
+	require.NoError(t, err)
+	require.Equal(t, []middleware.RateLimitRule{{
+		Pattern:    "/api/v1/login/**",
+		Limit:      5,
+		Window:     time.Minute,
+		RoleLimits: map[string]int{"admin": 50, middleware.RoleAnonymous: 2},
+	}}, rules)
+
+	jsonFile := filepath.Join(dir, "rules.json")
+	writeRules(t, jsonFile, `{"rules": [{"pattern": "/api/v1/books/*", "limit": 10, "window": "30s"}]}`)
+	rules, err = middleware.LoadRateLimitRules(jsonFile)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	require.Equal(t, 30*time.Second, rules[0].Window)
+
+	writeRules(t, yamlFile, "rules:\n  - pattern: /x\n    limit: 1\n    window: 1m\n    burst: 3\n")
+	_, err = middleware.LoadRateLimitRules(yamlFile)
+	require.ErrorContains(t, err, "burst")
+
+	_, err = middleware.LoadRateLimitRules(filepath.Join(dir, "missing.yaml"))
+	require.Error(t, err)
+}
+
+func TestShippedRateLimitRules(t *testing.T) {
+	rules, err := middleware.LoadRateLimitRules("../../data/rate_limits.yaml")
+	require.NoError(t, err)
+
+	// Every public endpoint that checks credentials or sends email is limited
+	for _, p := range []string{
+		"/api/v1/login",
+		"/api/v1/login/mfa",
+		"/api/v1/register",
+		"/api/v1/verify",
+		"/api/v1/verify/resend",
+		"/api/v1/refresh",
+		"/api/v1/forgot-password",
+		"/api/v1/reset-password",
+	} {
+		limited := false
+		for _, rule := range rules {
+			if rule.Matches(p) {
+				limited = true
+				break
+			}
+		}
+		require.True(t, limited, p)
+	}
+}
+
+func TestValidateRateLimitRules(t *testing.T) {
+	err := middleware.ValidateRateLimitRules([]middleware.RateLimitRule{
+		{Pattern: "api/v1/login", Limit: 5, Window: time.Minute},
+		{Pattern: "/api/**/books", Limit: 5, Window: time.Minute},
+		{Pattern: "/api/[", Limit: 5, Window: time.Minute},
+		{Pattern: "/ok", Limit: -1, Window: 0, RoleLimits: map[string]int{"admin": -2}},
+		{Pattern: "/ok", Limit: 1, Window: time.Second},
+	})
+	require.Error(t, err)
+
+	for _, want := range []string{
+		"rule 0 (api/v1/login): pattern must start with /",
+		"rule 1 (/api/**/books): ** is only allowed",
+		"rule 2 (/api/[): syntax error",
+		"rule 3 (/ok): limit must not be negative",
+		"rule 3 (/ok): window must be at least 1ms",
+		`rule 3 (/ok): limit for role "admin"`,
+		"rule 4 (/ok): duplicate pattern",
+	} {
+		require.ErrorContains(t, err, want)
+	}
+
+	require.NoError(t, middleware.ValidateRateLimitRules([]middleware.RateLimitRule{
+		{Pattern: "/api/v1/books/**", Limit: 0, Window: time.Minute},
+		{Pattern: "/api/v1/*/search", Limit: 10, Window: time.Second},
+	}))
+}
+
+func TestRateLimiter_WatchRulesReloads(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rules.yaml")
+	writeRules(t, file, "rules:\n  - pattern: /a\n    limit: 1\n    window: 1m\n")
+
+	rules, err := middleware.LoadRateLimitRules(file)
+	require.NoError(t, err)
+	limiter := middleware.NewRateLimiter(redis.NewClient(&redis.Options{Addr: "127.0.0.1:1"}), rules, middleware.DefaultRateLimiterConfig())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go limiter.WatchRules(ctx, file, 10*time.Millisecond)
+
+	writeRules(t, file, "rules:\n  - pattern: /a\n    limit: 1\n    window: 1m\n  - pattern: /b/**\n    limit: 2\n    window: 1s\n")
+	require.Eventually(t, func() bool { return len(limiter.Rules()) == 2 }, time.Second, 10*time.Millisecond)
+
+	// An invalid file leaves the last good rules in place
+	writeRules(t, file, "rules:\n  - pattern: b\n    limit: 2\n    window: 1s\n")
+	time.Sleep(50 * time.Millisecond)
+	require.Len(t, limiter.Rules(), 2)
+	require.Equal(t, "/b/**", limiter.Rules()[1].Pattern)
+}
@@ -1,67 +1,360 @@
 package middleware
 
 import (
+	"context"
+	"expvar"
 	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/DMaryanskiy/bookshare-api/pkg/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
 )
 
+// RoleAnonymous is the RoleLimits key for requests without a valid access
+// token.
+const RoleAnonymous = "anonymous"
+
 type RateLimiter struct {
 	Redis *redis.Client
-	Rules map[string]RateLimitRule
+
+	rules   atomic.Pointer[[]RateLimitRule]
+	cfg     RateLimiterConfig
+	breaker *breaker
+	local   *localLimiter
+}
+
+// FailurePolicy decides what happens to requests while Redis cannot be
+// reached.
+type FailurePolicy string
+
+const (
+	// FailOpen lets every request through unlimited.
+	FailOpen FailurePolicy = "open"
+	// FailClosed rejects every limited request with 503.
+	FailClosed FailurePolicy = "closed"
+	// FailLocal applies the same rules with an in-process token bucket per
+	// API instance.
+	FailLocal FailurePolicy = "local"
+)
+
+// ParseFailurePolicy parses "open", "closed" or "local".
+func ParseFailurePolicy(s string) (FailurePolicy, error) {
+	switch p := FailurePolicy(s); p {
+	case FailOpen, FailClosed, FailLocal:
+		return p, nil
+	}
+	return "", fmt.Errorf("%q is not one of open, closed or local", s)
+}
+
+type RateLimiterConfig struct {
+	Policy FailurePolicy
+	// RedisTimeout bounds each call to Redis; a slow Redis counts as a
+	// failed one.
+	RedisTimeout time.Duration
+	// After BreakerThreshold consecutive Redis failures the limiter stops
+	// calling Redis for BreakerCooldown and uses the policy straight away.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
 }
 
+func DefaultRateLimiterConfig() RateLimiterConfig {
+	return RateLimiterConfig{
+		Policy:           FailLocal,
+		RedisTimeout:     100 * time.Millisecond,
+		BreakerThreshold: 5,
+		BreakerCooldown:  10 * time.Second,
+	}
+}
+
+// Stats are published at /debug/vars under "rate_limiter".
+var rateLimitStats = expvar.NewMap("rate_limiter")
+
+const (
+	statRedisErrors    = "redis_errors"
+	statBreakerTrips   = "breaker_trips"
+	statFallbackLocal  = "fallback_local"
+	statFallbackOpen   = "fallback_open"
+	statFallbackClosed = "fallback_closed"
+)
+
+// RateLimitRule allows Limit requests per Window to every path matching
+// Pattern, for each user (or client IP when unauthenticated).
+//
+// Pattern is matched against the request path with path.Match, so "*"
+// stands for one path segment; a trailing "/**" matches the prefix itself
+// and everything below it. Rules are tried in order and the first match
+// applies, so list specific patterns before broad ones.
 type RateLimitRule struct {
-	Limit   int
-	Window  time.Duration
+	Pattern string        `yaml:"pattern"`
+	Limit   int           `yaml:"limit"`
+	Window  time.Duration `yaml:"window"`
+	// RoleLimits overrides Limit for the role in the access token, or for
+	// RoleAnonymous. A limit of 0 blocks the role entirely.
+	RoleLimits map[string]int `yaml:"role_limits"`
+}
+
+// NewRateLimiter panics if rules are invalid; rules read at runtime go
+// through SetRules instead.
+func NewRateLimiter(redis *redis.Client, rules []RateLimitRule, cfg RateLimiterConfig) *RateLimiter {
+	r := &RateLimiter{
+		Redis:   redis,
+		cfg:     cfg,
+		breaker: newBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
+		local:   newLocalLimiter(),
+	}
+	if err := r.SetRules(rules); err != nil {
+		panic(err)
+	}
+	return r
 }
 
-func NewRateLimiter(redis *redis.Client, rules map[string]RateLimitRule) *RateLimiter {
-	return &RateLimiter{
-		Redis: redis,
-		Rules: rules,
+// Rules returns the rules currently applied. The slice must not be
+// modified.
+func (r *RateLimiter) Rules() []RateLimitRule {
+	return *r.rules.Load()
+}
+
+// SetRules validates rules and replaces the current ones for every
+// following request.
+func (r *RateLimiter) SetRules(rules []RateLimitRule) error {
+	if err := ValidateRateLimitRules(rules); err != nil {
+		return err
+	}
+	r.rules.Store(&rules)
+	return nil
+}
+
+// Matches reports whether the rule applies to the request path p.
+func (rule RateLimitRule) Matches(p string) bool {
+	if prefix, ok := strings.CutSuffix(rule.Pattern, "/**"); ok {
+		if p == prefix {
+			return true
+		}
+		p, ok = strings.CutPrefix(p, prefix+"/")
+		return ok && p != ""
+	}
+	ok, _ := path.Match(rule.Pattern, p)
+	return ok
+}
+
+// LimitFor returns the number of requests per window allowed to role.
+func (rule RateLimitRule) LimitFor(role string) int {
+	if limit, ok := rule.RoleLimits[role]; ok {
+		return limit
+	}
+	return rule.Limit
+}
+
+func (r *RateLimiter) match(p string) (RateLimitRule, bool) {
+	for _, rule := range r.Rules() {
+		if rule.Matches(p) {
+			return rule, true
+		}
+	}
+	return RateLimitRule{}, false
+}
+
+// RateLimitResult is the outcome of one request against a rule.
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long a denied client has to wait for the next
+	// request to be allowed.
+	RetryAfter time.Duration
+	// ResetAfter is how long until the full limit is available again.
+	ResetAfter time.Duration
+
+	// unavailable marks a request rejected by FailClosed rather than
+	// counted.
+	unavailable bool
+}
+
+// gcraScript implements the generic cell rate algorithm. The bucket hash
+// holds the theoretical arrival time (TAT) in milliseconds and the limit it
+// was counted with, which Usage reports: requests are spaced one emission
+// interval apart, and up to a window's worth may arrive early. Time comes from the Redis server so
+// that every API instance agrees.
+//
+// A limit override for the client in the overrides hash replaces ARGV[1].
+//
+// KEYS: client bucket, overrides hash.
+// ARGV: limit, window (ms), client. Returns {allowed, remaining, retry
+// after (ms), reset after (ms), limit}.
+var gcraScript = redis.NewScript(`
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+
+local override = redis.call('HGET', KEYS[2], ARGV[3])
+if override then
+	limit = tonumber(override)
+end
+if limit <= 0 then
+	return {0, 0, window, window, 0}
+end
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local interval = window / limit
+
+local tat = tonumber(redis.call('HGET', key, 'tat') or now)
+if tat < now then
+	tat = now
+end
+
+local new_tat = tat + interval
+local diff = now - (new_tat - window)
+if diff < 0 then
+	return {0, 0, math.ceil(-diff), math.ceil(tat - now), limit}
+end
+
+local ttl = math.ceil(new_tat - now)
+redis.call('HSET', key, 'tat', string.format('%.0f', new_tat), 'limit', limit)
+redis.call('PEXPIRE', key, ttl)
+return {1, math.floor(diff / interval), 0, ttl, limit}
+`)
+
+func bucketKey(pattern, client string) string { return "rate:" + pattern + ":" + client }
+
+// Allow counts one request by client against rule in Redis.
+func (r *RateLimiter) Allow(ctx context.Context, rule RateLimitRule, client string, limit int) (RateLimitResult, error) {
+	keys := []string{bucketKey(rule.Pattern, client), rateLimitOverridesKey}
+	vals, err := gcraScript.Run(ctx, r.Redis, keys, limit, rule.Window.Milliseconds(), client).Int64Slice()
+	if err != nil {
+		return RateLimitResult{Limit: limit}, err
+	}
+
+	return RateLimitResult{
+		Allowed:    vals[0] == 1,
+		Remaining:  int(vals[1]),
+		RetryAfter: time.Duration(vals[2]) * time.Millisecond,
+		ResetAfter: time.Duration(vals[3]) * time.Millisecond,
+		Limit:      int(vals[4]),
+	}, nil
+}
+
+// identify returns the client the request is counted against and its
+// role. The limiter runs before the route's JWTAuthMiddleware, so the token
+// is parsed here; only a signed token picks a user's bucket, and the
+// denylist is still checked by the auth middleware. Invalid tokens count as
+// anonymous.
+func identify(c *gin.Context) (client, role string) {
+	if userID := c.GetString("user_id"); userID != "" {
+		return ClientUser + userID, c.GetString("role")
+	}
+
+	if scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " "); ok && strings.EqualFold(scheme, "bearer") {
+		if claims, err := utils.ParseToken(token); err == nil && claims.UserID != "" {
+			return ClientUser + claims.UserID, claims.Role
+		}
+	}
+
+	return ClientIP + c.ClientIP(), RoleAnonymous
+}
+
+func setRateLimitHeaders(c *gin.Context, res RateLimitResult) {
+	c.Header("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	reset := time.Now().Add(res.ResetAfter)
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(int64(math.Ceil(float64(reset.UnixMilli())/1000)), 10))
+	if !res.Allowed {
+		c.Header("Retry-After", strconv.FormatInt(int64(math.Ceil(res.RetryAfter.Seconds())), 10))
+	}
+}
+
+// count counts the request against the issued API key's bucket, if apiKey
+// is one, and against client's otherwise. Keys get the rule's limit rather
+// than the anonymous one.
+func (r *RateLimiter) count(ctx context.Context, rule RateLimitRule, client, apiKey string, limit int) (RateLimitResult, error) {
+	if apiKey != "" {
+		keyClient, err := r.apiKeyClient(ctx, apiKey)
+		if err != nil {
+			return RateLimitResult{Limit: limit}, err
+		}
+		if keyClient != "" {
+			client, limit = keyClient, rule.Limit
+		}
+	}
+	return r.Allow(ctx, rule, client, limit)
+}
+
+// check counts the request in Redis, or applies the failure policy when
+// Redis is failing. handled is false when the request must be let through
+// without limiting.
+func (r *RateLimiter) check(ctx context.Context, rule RateLimitRule, client, apiKey string, limit int) (res RateLimitResult, handled bool) {
+	if r.breaker.allow() {
+		if r.cfg.RedisTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, r.cfg.RedisTimeout)
+			defer cancel()
+		}
+
+		res, err := r.count(ctx, rule, client, apiKey, limit)
+		if err == nil {
+			r.breaker.success()
+			return res, true
+		}
+
+		rateLimitStats.Add(statRedisErrors, 1)
+		if r.breaker.failure() {
+			rateLimitStats.Add(statBreakerTrips, 1)
+			log.Printf("Rate limiter: Redis unavailable, falling back to %q policy for %s: %v", r.cfg.Policy, r.cfg.BreakerCooldown, err)
+		}
+	}
+
+	switch r.cfg.Policy {
+	case FailOpen:
+		rateLimitStats.Add(statFallbackOpen, 1)
+		return RateLimitResult{}, false
+	case FailClosed:
+		rateLimitStats.Add(statFallbackClosed, 1)
+		return RateLimitResult{Limit: limit, RetryAfter: r.cfg.BreakerCooldown, unavailable: true}, true
+	default:
+		rateLimitStats.Add(statFallbackLocal, 1)
+		// Overrides and API keys live in Redis, so the local bucket uses the
+		// rule's limit and the client's IP
+		return r.local.allow(bucketKey(rule.Pattern, client), limit, rule.Window), true
 	}
 }
 
 func (r *RateLimiter) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		path := c.FullPath()
-		rule, exists := r.Rules[path]
+		rule, exists := r.match(c.Request.URL.Path)
 		if !exists {
 			c.Next()
 			return
 		}
 
-		userID := c.GetString("user_id")
-		if userID == "" {
-			userID = c.ClientIP()
-		}
-
-		limit := rule.Limit
+		client, role := identify(c)
 
-		key := fmt.Sprintf("rate:%s:%s", path, userID)
-		now := time.Now()
+		// A user's token takes precedence over an API key
+		var apiKey string
+		if role == RoleAnonymous {
+			apiKey = c.GetHeader("X-API-Key")
+		}
 
-		pipe := r.Redis.TxPipeline()
-		count := pipe.Incr(c, key)
-		pipe.Expire(c, key, rule.Window)
-		_, err := pipe.Exec(c)
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "rate limiter error"})
+		res, handled := r.check(c, rule, client, apiKey, rule.LimitFor(role))
+		if !handled {
+			c.Next()
 			return
 		}
 
-		current := count.Val()
-		remaining := limit - int(current)
-
-		c.Header("X-RateLimit-Limit", fmt.Sprintf("%d", limit))
-		c.Header("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
-		c.Header("X-RateLimit-Reset", fmt.Sprintf("%d", now.Add(rule.Window).Unix()))
+		setRateLimitHeaders(c, res)
 
-		if remaining < 0 {
+		if !res.Allowed {
+			if res.unavailable {
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "rate limiter unavailable"})
+				return
+			}
 			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
 			return
 		}
@@ -0,0 +1,285 @@
+package middleware_test
+
+import (
+	"context"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/middleware"
+	"github.com/DMaryanskiy/bookshare-api/internal/tests"
+	"github.com/DMaryanskiy/bookshare-api/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitRule_Matches(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/api/v1/login", "/api/v1/login", true},
+		{"/api/v1/login", "/api/v1/login/mfa", false},
+		{"/api/v1/books/*", "/api/v1/books/123", true},
+		{"/api/v1/books/*", "/api/v1/books", false},
+		{"/api/v1/books/*", "/api/v1/books/123/cover", false},
+		{"/api/v1/books/**", "/api/v1/books", true},
+		{"/api/v1/books/**", "/api/v1/books/123/cover", true},
+		{"/api/v1/books/**", "/api/v1/bookshelf", false},
+		{"/api/v1/*/search", "/api/v1/books/search", true},
+	}
+
+	for _, tc := range cases {
+		rule := middleware.RateLimitRule{Pattern: tc.pattern}
+		require.Equal(t, tc.want, rule.Matches(tc.path), "%s against %s", tc.pattern, tc.path)
+	}
+}
+
+func TestRateLimitRule_LimitFor(t *testing.T) {
+	rule := middleware.RateLimitRule{
+		Limit: 10,
+		RoleLimits: map[string]int{
+			"admin":                  100,
+			middleware.RoleAnonymous: 0,
+		},
+	}
+
+	require.Equal(t, 100, rule.LimitFor("admin"))
+	require.Equal(t, 10, rule.LimitFor("user"))
+	require.Equal(t, 0, rule.LimitFor(middleware.RoleAnonymous))
+}
+
+func setupLimitedRouter(rules ...middleware.RateLimitRule) *gin.Engine {
+	return routerFor(middleware.NewRateLimiter(tests.TestRedis, rules, middleware.DefaultRateLimiterConfig()))
+}
+
+func routerFor(limiter *middleware.RateLimiter) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(limiter.Middleware())
+	router.GET("/limited/*any", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return router
+}
+
+func hit(r *gin.Engine, path, token string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", tests.GetAuthHeader(token))
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimiter_DeniesOverLimit(t *testing.T) {
+	tests.SetupTestRedis()
+
+	r := setupLimitedRouter(middleware.RateLimitRule{Pattern: "/limited/**", Limit: 3, Window: time.Minute})
+
+	for i := 2; i >= 0; i-- {
+		w := hit(r, "/limited/a", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "3", w.Header().Get("X-RateLimit-Limit"))
+		require.Equal(t, strconv.Itoa(i), w.Header().Get("X-RateLimit-Remaining"))
+		require.Empty(t, w.Header().Get("Retry-After"))
+	}
+
+	// The bucket is shared by every path the pattern matches
+	w := hit(r, "/limited/b", "")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+
+	// One request is released every window/limit
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	require.NoError(t, err)
+	require.InDelta(t, 20, retryAfter, 1)
+
+	reset, err := strconv.ParseInt(w.Header().Get("X-RateLimit-Reset"), 10, 64)
+	require.NoError(t, err)
+	require.InDelta(t, time.Now().Add(time.Minute).Unix(), reset, 2)
+}
+
+func TestRateLimiter_ReleasesSteadyClient(t *testing.T) {
+	tests.SetupTestRedis()
+
+	r := setupLimitedRouter(middleware.RateLimitRule{Pattern: "/limited/**", Limit: 2, Window: 400 * time.Millisecond})
+
+	require.Equal(t, http.StatusOK, hit(r, "/limited/x", "").Code)
+	require.Equal(t, http.StatusOK, hit(r, "/limited/x", "").Code)
+	require.Equal(t, http.StatusTooManyRequests, hit(r, "/limited/x", "").Code)
+
+	// Denied requests do not push the release further back
+	for range 3 {
+		time.Sleep(250 * time.Millisecond)
+		require.Equal(t, http.StatusOK, hit(r, "/limited/x", "").Code)
+	}
+}
+
+func TestRateLimiter_KeysByUserAndRole(t *testing.T) {
+	tests.SetupTestRedis()
+
+	r := setupLimitedRouter(middleware.RateLimitRule{
+		Pattern: "/limited/**",
+		Limit:   1,
+		Window:  time.Minute,
+		RoleLimits: map[string]int{
+			"admin": 2,
+		},
+	})
+
+	userToken, err := utils.GenerateAccessToken("11111111-1111-1111-1111-111111111111", "user", time.Hour)
+	require.NoError(t, err)
+	otherToken, err := utils.GenerateAccessToken("22222222-2222-2222-2222-222222222222", "user", time.Hour)
+	require.NoError(t, err)
+	adminToken, err := utils.GenerateAccessToken("33333333-3333-3333-3333-333333333333", "admin", time.Hour)
+	require.NoError(t, err)
+
+	// Users behind the same IP get their own buckets
+	require.Equal(t, http.StatusOK, hit(r, "/limited/x", userToken).Code)
+	require.Equal(t, http.StatusTooManyRequests, hit(r, "/limited/x", userToken).Code)
+	require.Equal(t, http.StatusOK, hit(r, "/limited/x", otherToken).Code)
+
+	w := hit(r, "/limited/x", adminToken)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+	require.Equal(t, http.StatusOK, hit(r, "/limited/x", adminToken).Code)
+	require.Equal(t, http.StatusTooManyRequests, hit(r, "/limited/x", adminToken).Code)
+
+	// Anonymous and malformed-token requests share the IP bucket
+	require.Equal(t, http.StatusOK, hit(r, "/limited/x", "").Code)
+	require.Equal(t, http.StatusTooManyRequests, hit(r, "/limited/x", "not-a-token").Code)
+}
+
+func TestRateLimiter_CountsIssuedAPIKeys(t *testing.T) {
+	tests.SetupTestRedis()
+	ctx := context.Background()
+
+	limiter := middleware.NewRateLimiter(tests.TestRedis, []middleware.RateLimitRule{
+		{Pattern: "/limited/**", Limit: 2, Window: time.Minute, RoleLimits: map[string]int{middleware.RoleAnonymous: 1}},
+	}, middleware.DefaultRateLimiterConfig())
+	r := routerFor(limiter)
+
+	hitWithKey := func(key string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/limited/x", nil)
+		req.Header.Set("X-API-Key", key)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Keys that were never issued are counted by IP
+	require.Equal(t, http.StatusOK, hitWithKey("made-up").Code)
+	require.Equal(t, http.StatusTooManyRequests, hitWithKey("also-made-up").Code)
+
+	key, err := limiter.CreateAPIKey(ctx, "partner")
+	require.NoError(t, err)
+	_, err = limiter.CreateAPIKey(ctx, "partner")
+	require.ErrorIs(t, err, middleware.ErrAPIKeyExists)
+	_, err = limiter.CreateAPIKey(ctx, "not a valid id")
+	require.ErrorIs(t, err, middleware.ErrInvalidAPIKeyID)
+
+	// An issued key gets its own bucket at the rule's limit, or its override
+	w := hitWithKey(key)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+
+	require.NoError(t, limiter.Reset(ctx, middleware.ClientAPIKey+"partner"))
+	require.NoError(t, limiter.SetOverride(ctx, middleware.ClientAPIKey+"partner", 3))
+	require.Equal(t, http.StatusOK, hitWithKey(key).Code)
+	require.Equal(t, http.StatusOK, hitWithKey(key).Code)
+	require.Equal(t, http.StatusOK, hitWithKey(key).Code)
+	w = hitWithKey(key)
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "3", w.Header().Get("X-RateLimit-Limit"))
+
+	// Revoking the key drops its override and counts it by IP again
+	found, err := limiter.RevokeAPIKey(ctx, "partner")
+	require.NoError(t, err)
+	require.True(t, found)
+	overrides, err := limiter.Overrides(ctx)
+	require.NoError(t, err)
+	require.Empty(t, overrides)
+	w = hitWithKey(key)
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "1", w.Header().Get("X-RateLimit-Limit"))
+}
+
+// unreachableLimiter talks to a Redis nobody listens on.
+func unreachableLimiter(policy middleware.FailurePolicy) *middleware.RateLimiter {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	cfg := middleware.DefaultRateLimiterConfig()
+	cfg.Policy = policy
+	cfg.BreakerThreshold = 2
+	cfg.BreakerCooldown = time.Minute
+	return middleware.NewRateLimiter(client, []middleware.RateLimitRule{
+		{Pattern: "/limited/**", Limit: 2, Window: time.Minute},
+	}, cfg)
+}
+
+func limiterStat(name string) int64 {
+	v := expvar.Get("rate_limiter").(*expvar.Map).Get(name)
+	if v == nil {
+		return 0
+	}
+	return v.(*expvar.Int).Value()
+}
+
+func TestRateLimiter_FallsBackToLocalBucket(t *testing.T) {
+	r := routerFor(unreachableLimiter(middleware.FailLocal))
+	errorsBefore, tripsBefore := limiterStat("redis_errors"), limiterStat("breaker_trips")
+	localBefore := limiterStat("fallback_local")
+
+	require.Equal(t, http.StatusOK, hit(r, "/limited/x", "").Code)
+	w := hit(r, "/limited/x", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+
+	w = hit(r, "/limited/x", "")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "30", w.Header().Get("Retry-After"))
+
+	// The breaker opened after two failures, so the third request did not
+	// try Redis
+	require.Equal(t, int64(2), limiterStat("redis_errors")-errorsBefore)
+	require.Equal(t, int64(1), limiterStat("breaker_trips")-tripsBefore)
+	require.Equal(t, int64(3), limiterStat("fallback_local")-localBefore)
+}
+
+func TestRateLimiter_FailOpen(t *testing.T) {
+	r := routerFor(unreachableLimiter(middleware.FailOpen))
+	before := limiterStat("fallback_open")
+
+	for range 5 {
+		w := hit(r, "/limited/x", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+	}
+	require.Equal(t, int64(5), limiterStat("fallback_open")-before)
+}
+
+func TestRateLimiter_FailClosed(t *testing.T) {
+	r := routerFor(unreachableLimiter(middleware.FailClosed))
+
+	w := hit(r, "/limited/x", "")
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	// Unlimited paths are unaffected
+	r.GET("/free", func(c *gin.Context) { c.Status(http.StatusOK) })
+	require.Equal(t, http.StatusOK, hit(r, "/free", "").Code)
+}
+
+func TestParseFailurePolicy(t *testing.T) {
+	p, err := middleware.ParseFailurePolicy("closed")
+	require.NoError(t, err)
+	require.Equal(t, middleware.FailClosed, p)
+
+	_, err = middleware.ParseFailurePolicy("ignore")
+	require.Error(t, err)
+}
@@ -0,0 +1,169 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidLimit  = errors.New("limit must be between 1 and 100")
+	ErrInvalidSort   = errors.New("sort must be created_at or -created_at")
+	ErrInvalidTime   = errors.New("time filters must be RFC3339 timestamps")
+)
+
+// Cursor is the keyset position of the last item on a page. Clients only
+// ever see it as an opaque string.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+}
+
+func EncodeCursor(cur Cursor) string {
+	b, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodeCursor(s string) (Cursor, error) {
+	var cur Cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cur, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(b, &cur); err != nil || cur.ID == uuid.Nil {
+		return cur, ErrInvalidCursor
+	}
+	return cur, nil
+}
+
+// Params describes which page of a (created_at, id) ordered listing to return.
+type Params struct {
+	Limit  int
+	Cursor *Cursor
+	Desc   bool
+
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+}
+
+// Parse reads limit, cursor, sort, created_after and created_before from the
+// query string. Results are newest first unless sort=created_at is given.
+func Parse(c *gin.Context) (Params, error) {
+	p := Params{Limit: DefaultLimit, Desc: true}
+
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > MaxLimit {
+			return p, ErrInvalidLimit
+		}
+		p.Limit = n
+	}
+
+	switch c.Query("sort") {
+	case "", "-created_at":
+		p.Desc = true
+	case "created_at":
+		p.Desc = false
+	default:
+		return p, ErrInvalidSort
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		cur, err := DecodeCursor(raw)
+		if err != nil {
+			return p, err
+		}
+		p.Cursor = &cur
+	}
+
+	var err error
+	if p.CreatedAfter, err = parseTime(c.Query("created_after")); err != nil {
+		return p, err
+	}
+	if p.CreatedBefore, err = parseTime(c.Query("created_before")); err != nil {
+		return p, err
+	}
+
+	return p, nil
+}
+
+func parseTime(raw string) (*time.Time, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, ErrInvalidTime
+	}
+	return &t, nil
+}
+
+// Apply adds the created range, keyset condition, ordering and limit to q.
+// One extra row is fetched so NewPage can tell whether another page exists.
+func (p Params) Apply(q *gorm.DB) *gorm.DB {
+	return p.Scope(q).Limit(p.Limit + 1)
+}
+
+// Scope is Apply without the limit, for exports that walk every row from
+// the cursor onwards.
+func (p Params) Scope(q *gorm.DB) *gorm.DB {
+	if p.CreatedAfter != nil {
+		q = q.Where("created_at >= ?", *p.CreatedAfter)
+	}
+	if p.CreatedBefore != nil {
+		q = q.Where("created_at < ?", *p.CreatedBefore)
+	}
+
+	dir, op := "ASC", ">"
+	if p.Desc {
+		dir, op = "DESC", "<"
+	}
+
+	if p.Cursor != nil {
+		q = q.Where("(created_at, id) "+op+" (?, ?)", p.Cursor.CreatedAt, p.Cursor.ID)
+	}
+
+	return q.Order("created_at " + dir).Order("id " + dir)
+}
+
+// Page is the envelope returned by paginated list endpoints.
+type Page[T any] struct {
+	Data       []T    `json:"data"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// NewPage trims the extra row fetched by Apply and derives the next cursor
+// from the last item that is returned.
+func NewPage[T any](items []T, p Params, key func(T) Cursor) Page[T] {
+	page := Page[T]{Data: items}
+	if len(items) > p.Limit {
+		page.Data = items[:p.Limit]
+		page.NextCursor = EncodeCursor(key(page.Data[p.Limit-1]))
+	}
+	if page.Data == nil {
+		page.Data = []T{}
+	}
+	return page
+}
+
+// MapPage converts the items of a page, keeping its cursor. Handlers use it
+// to turn a page of models into a page of response DTOs.
+func MapPage[T, U any](page Page[T], convert func(T) U) Page[U] {
+	out := Page[U]{Data: make([]U, 0, len(page.Data)), NextCursor: page.NextCursor}
+	for _, item := range page.Data {
+		out.Data = append(out.Data, convert(item))
+	}
+	return out
+}
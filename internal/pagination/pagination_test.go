@@ -0,0 +1,100 @@
+package pagination_test
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/pagination"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func contextWithQuery(query string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/items?"+query, nil)
+	return c
+}
+
+func TestCursor_RoundTrip(t *testing.T) {
+	cur := pagination.Cursor{
+		CreatedAt: time.Date(2025, 1, 2, 3, 4, 5, 123456000, time.UTC),
+		ID:        uuid.New(),
+	}
+
+	decoded, err := pagination.DecodeCursor(pagination.EncodeCursor(cur))
+	require.NoError(t, err)
+	require.True(t, cur.CreatedAt.Equal(decoded.CreatedAt))
+	require.Equal(t, cur.ID, decoded.ID)
+}
+
+func TestCursor_Invalid(t *testing.T) {
+	_, err := pagination.DecodeCursor("not-a-cursor")
+	require.ErrorIs(t, err, pagination.ErrInvalidCursor)
+}
+
+func TestParse_Defaults(t *testing.T) {
+	p, err := pagination.Parse(contextWithQuery(""))
+	require.NoError(t, err)
+	require.Equal(t, pagination.DefaultLimit, p.Limit)
+	require.True(t, p.Desc)
+	require.Nil(t, p.Cursor)
+}
+
+func TestParse_Values(t *testing.T) {
+	p, err := pagination.Parse(contextWithQuery("limit=5&sort=created_at&created_after=2025-01-01T00:00:00Z"))
+	require.NoError(t, err)
+	require.Equal(t, 5, p.Limit)
+	require.False(t, p.Desc)
+	require.NotNil(t, p.CreatedAfter)
+	require.Nil(t, p.CreatedBefore)
+}
+
+func TestParse_Errors(t *testing.T) {
+	cases := map[string]error{
+		"limit=0":              pagination.ErrInvalidLimit,
+		"limit=1000":           pagination.ErrInvalidLimit,
+		"sort=title":           pagination.ErrInvalidSort,
+		"cursor=abc":           pagination.ErrInvalidCursor,
+		"created_before=today": pagination.ErrInvalidTime,
+	}
+
+	for query, want := range cases {
+		_, err := pagination.Parse(contextWithQuery(query))
+		require.ErrorIs(t, err, want, query)
+	}
+}
+
+func TestNewPage(t *testing.T) {
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	key := func(id uuid.UUID) pagination.Cursor { return pagination.Cursor{ID: id} }
+
+	page := pagination.NewPage(ids, pagination.Params{Limit: 2}, key)
+	require.Len(t, page.Data, 2)
+
+	cur, err := pagination.DecodeCursor(page.NextCursor)
+	require.NoError(t, err)
+	require.Equal(t, ids[1], cur.ID)
+
+	last := pagination.NewPage(ids[:1], pagination.Params{Limit: 2}, key)
+	require.Len(t, last.Data, 1)
+	require.Empty(t, last.NextCursor)
+
+	empty := pagination.NewPage[uuid.UUID](nil, pagination.Params{Limit: 2}, key)
+	require.NotNil(t, empty.Data)
+}
+
+func TestMapPage(t *testing.T) {
+	page := pagination.Page[int]{Data: []int{1, 2}, NextCursor: "next"}
+
+	mapped := pagination.MapPage(page, func(n int) string { return strconv.Itoa(n * 10) })
+	require.Equal(t, []string{"10", "20"}, mapped.Data)
+	require.Equal(t, "next", mapped.NextCursor)
+
+	empty := pagination.MapPage(pagination.Page[int]{}, strconv.Itoa)
+	require.NotNil(t, empty.Data)
+}
@@ -0,0 +1,92 @@
+package rbac
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"gorm.io/gorm"
+)
+
+type Permission string
+
+// Permissions known to the code. auth.permissions may hold more, but only
+// these are checked anywhere.
+const (
+	PermBooksModerate Permission = "books:moderate"
+	PermUsersManage   Permission = "users:manage"
+	PermAuditRead     Permission = "audit:read"
+	PermRolesManage   Permission = "roles:manage"
+	PermMetricsRead   Permission = "metrics:read"
+	PermRateLimits    Permission = "ratelimits:manage"
+)
+
+// DefaultCacheTTL bounds how long a permission change made by another
+// process goes unnoticed.
+const DefaultCacheTTL = 30 * time.Second
+
+// Store answers which permissions a role has. Assignments are read on every
+// admin request and change rarely, so the whole role_permissions table is
+// cached and reloaded once it is older than the TTL or invalidated.
+type Store struct {
+	db  *gorm.DB
+	ttl time.Duration
+
+	mu       sync.RWMutex
+	roles    map[string]map[Permission]bool
+	loadedAt time.Time
+}
+
+func NewStore(gdb *gorm.DB, ttl time.Duration) *Store {
+	return &Store{db: gdb, ttl: ttl}
+}
+
+// Has reports whether role grants perm. Unknown roles grant nothing.
+func (s *Store) Has(ctx context.Context, role string, perm Permission) (bool, error) {
+	roles, err := s.snapshot(ctx)
+	if err != nil {
+		return false, err
+	}
+	return roles[role][perm], nil
+}
+
+// Invalidate makes the next check reload assignments. Handlers that change
+// them call it so the change applies in this process straight away.
+func (s *Store) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.roles = nil
+}
+
+func (s *Store) snapshot(ctx context.Context) (map[string]map[Permission]bool, error) {
+	s.mu.RLock()
+	roles, fresh := s.roles, s.roles != nil && time.Since(s.loadedAt) < s.ttl
+	s.mu.RUnlock()
+	if fresh {
+		return roles, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Another request may have reloaded while we waited for the lock
+	if s.roles != nil && time.Since(s.loadedAt) < s.ttl {
+		return s.roles, nil
+	}
+
+	var rows []models.RolePermission
+	if err := s.db.WithContext(ctx).Table("auth.role_permissions").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	roles = make(map[string]map[Permission]bool)
+	for _, row := range rows {
+		if roles[row.Role] == nil {
+			roles[row.Role] = make(map[Permission]bool)
+		}
+		roles[row.Role][Permission(row.Permission)] = true
+	}
+
+	s.roles, s.loadedAt = roles, time.Now()
+	return roles, nil
+}
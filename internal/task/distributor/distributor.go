@@ -19,18 +19,38 @@ func NewTaskDistributor(redisAddr string) *TaskDistributor {
 }
 
 func (d *TaskDistributor) DistributeVerificationEmail(ctx context.Context, payload task.PayloadSendVerificationEmail) error {
+	return d.enqueue(ctx, task.TaskSendVerificationEmail, payload)
+}
+
+func (d *TaskDistributor) DistributePasswordResetEmail(ctx context.Context, payload task.PayloadSendPasswordResetEmail) error {
+	return d.enqueue(ctx, task.TaskSendPasswordResetEmail, payload)
+}
+
+func (d *TaskDistributor) DistributeBookMetadataEnrichment(ctx context.Context, payload task.PayloadEnrichBookMetadata) error {
+	return d.enqueue(ctx, task.TaskEnrichBookMetadata, payload, asynq.MaxRetry(5))
+}
+
+func (d *TaskDistributor) DistributeAccountLockedEmail(ctx context.Context, payload task.PayloadSendAccountLockedEmail) error {
+	return d.enqueue(ctx, task.TaskSendAccountLockedEmail, payload)
+}
+
+func (d *TaskDistributor) DistributeAuditLogs(ctx context.Context, payload task.PayloadWriteAuditLogs) error {
+	return d.enqueue(ctx, task.TaskWriteAuditLogs, payload, asynq.MaxRetry(25))
+}
+
+func (d *TaskDistributor) enqueue(ctx context.Context, taskType string, payload any, opts ...asynq.Option) error {
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return err
 	}
 
-	task := asynq.NewTask(task.TaskSendVerificationEmail, data)
+	task := asynq.NewTask(taskType, data, opts...)
 	info, err := d.Client.EnqueueContext(ctx, task)
 	if err != nil {
 		return err
 	}
 
-	log.Println("Task was enqueued:")
-	log.Printf("UUID: %+v\nType: %+v\nPayload: %+v\n", info.ID, info.Type, string(info.Payload))
+	// The payload is not logged: it carries emails and audit metadata
+	log.Printf("Enqueued task %s (%s)", info.Type, info.ID)
 	return nil
 }
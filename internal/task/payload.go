@@ -1,8 +1,42 @@
 package task
 
-const TaskSendVerificationEmail = "send_verification_email"
+import (
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+)
+
+const (
+	TaskSendVerificationEmail  = "send_verification_email"
+	TaskSendPasswordResetEmail = "send_password_reset_email"
+	TaskEnrichBookMetadata     = "enrich_book_metadata"
+	TaskSendAccountLockedEmail = "send_account_locked_email"
+	TaskWriteAuditLogs         = "write_audit_logs"
+)
 
 type PayloadSendVerificationEmail struct {
 	UserId string `json:"user_id"`
 	Email  string `json:"email"`
 }
+
+type PayloadSendPasswordResetEmail struct {
+	UserId string `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+type PayloadEnrichBookMetadata struct {
+	BookId string `json:"book_id"`
+	ISBN   string `json:"isbn"`
+}
+
+type PayloadSendAccountLockedEmail struct {
+	UserId      string    `json:"user_id"`
+	Email       string    `json:"email"`
+	IP          string    `json:"ip"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+// PayloadWriteAuditLogs carries audit entries the API could not insert.
+type PayloadWriteAuditLogs struct {
+	Entries []models.AuditLog `json:"entries"`
+}
@@ -3,26 +3,52 @@ package processor
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"os"
 	"time"
 
-	"github.com/DMaryanskiy/bookshare-api/internal/db"
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
+	"github.com/DMaryanskiy/bookshare-api/internal/config"
 	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
 	"github.com/DMaryanskiy/bookshare-api/internal/email"
+	"github.com/DMaryanskiy/bookshare-api/internal/metadata"
 	"github.com/DMaryanskiy/bookshare-api/internal/task"
 	"github.com/DMaryanskiy/bookshare-api/pkg/utils"
 	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
+	"gorm.io/gorm"
 )
 
 type TaskProcessor struct {
+	DB          *gorm.DB
 	EmailSender *email.EmailSender
+	Metadata    metadata.MetadataProvider
+	// HTTP locates the API for links in emails.
+	HTTP config.HTTPConfig
 }
 
-func NewTaskProcessor(sender *email.EmailSender) *TaskProcessor {
-	return &TaskProcessor{EmailSender: sender}
+func NewTaskProcessor(gdb *gorm.DB, sender *email.EmailSender, provider metadata.MetadataProvider, http config.HTTPConfig) *TaskProcessor {
+	return &TaskProcessor{DB: gdb, EmailSender: sender, Metadata: provider, HTTP: http}
+}
+
+// link returns an absolute URL on the API for path.
+func (p *TaskProcessor) link(path string) string {
+	return fmt.Sprintf("http://%s:%d%s", p.HTTP.Host, p.HTTP.Port, path)
+}
+
+func (p *TaskProcessor) verificationLink(token, userID string) string {
+	return p.link(fmt.Sprintf("/api/v1/verify?token=%s&uid=%s", token, userID))
+}
+
+// resetPasswordLink opens the reset form served by GET /api/v1/reset-password.
+func (p *TaskProcessor) resetPasswordLink(token, userID string) string {
+	return p.link(fmt.Sprintf("/api/v1/reset-password?token=%s&uid=%s", token, userID))
+}
+
+// forgotPasswordLink opens the form served by GET /api/v1/forgot-password.
+func (p *TaskProcessor) forgotPasswordLink() string {
+	return p.link("/api/v1/forgot-password")
 }
 
 func (p *TaskProcessor) Start(redisAddr string) error {
@@ -33,6 +59,10 @@ func (p *TaskProcessor) Start(redisAddr string) error {
 
 	mux := asynq.NewServeMux()
 	mux.HandleFunc(task.TaskSendVerificationEmail, p.handleSendVerificationEmail)
+	mux.HandleFunc(task.TaskSendPasswordResetEmail, p.handleSendPasswordResetEmail)
+	mux.HandleFunc(task.TaskEnrichBookMetadata, p.handleEnrichBookMetadata)
+	mux.HandleFunc(task.TaskSendAccountLockedEmail, p.handleSendAccountLockedEmail)
+	mux.HandleFunc(task.TaskWriteAuditLogs, p.handleWriteAuditLogs)
 
 	log.Println("Email worker is running...")
 	return srv.Run(mux)
@@ -49,17 +79,14 @@ func (p *TaskProcessor) handleSendVerificationEmail(ctx context.Context, t *asyn
 		return fmt.Errorf("failed to generate token: %v", err)
 	}
 	expires := time.Now().Add(30 * time.Minute)
-	db.DB.Table("auth.verification_tokens").Create(&models.VerificationToken{
+	p.DB.Table("auth.verification_tokens").Create(&models.VerificationToken{
 		UserID: uuid.MustParse(payload.UserId),
 		Token: token,
+		Purpose: models.TokenPurposeEmailVerification,
 		ExpiresAt: expires,
 	})
 
-	host := os.Getenv("HOST")
-	if host == "" {
-		host = "localhost"
-	}
-	verificationLink := fmt.Sprintf("http://%s:8080/api/v1/verify?token=%s&uid=%s", host, token, payload.UserId)
+	verificationLink := p.verificationLink(token, payload.UserId)
 
 	emailBody := fmt.Sprintf(`
         <h1>Verify your email</h1>
@@ -75,3 +102,116 @@ func (p *TaskProcessor) handleSendVerificationEmail(ctx context.Context, t *asyn
 	log.Printf("Sent verification email to %s", payload.Email)
 	return nil
 }
+
+func (p *TaskProcessor) handleSendPasswordResetEmail(ctx context.Context, t *asynq.Task) error {
+	var payload task.PayloadSendPasswordResetEmail
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("invalid payload: %v", err)
+	}
+
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return fmt.Errorf("failed to generate token: %v", err)
+	}
+
+	// Only the most recently requested reset link stays valid
+	userID := uuid.MustParse(payload.UserId)
+	if err := p.DB.WithContext(ctx).Table("auth.verification_tokens").
+		Where("user_id = ? AND purpose = ?", userID, models.TokenPurposePasswordReset).
+		Delete(&models.VerificationToken{}).Error; err != nil {
+		return fmt.Errorf("failed to delete old reset tokens: %w", err)
+	}
+
+	if err := p.DB.WithContext(ctx).Table("auth.verification_tokens").Create(&models.VerificationToken{
+		UserID:    userID,
+		Token:     token,
+		Purpose:   models.TokenPurposePasswordReset,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}).Error; err != nil {
+		return fmt.Errorf("failed to store reset token: %w", err)
+	}
+
+	resetLink := p.resetPasswordLink(token, payload.UserId)
+
+	emailBody := fmt.Sprintf(`
+        <h1>Reset your password</h1>
+        <p>Click <a href="%s">here</a> to choose a new password. The link expires in one hour.</p>
+        <p>If you did not request this, please ignore.</p>
+    `, resetLink)
+
+	if err := p.EmailSender.Send(payload.Email, "Reset your BookShare password", emailBody); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	log.Printf("Sent password reset email to %s", payload.Email)
+	return nil
+}
+
+func (p *TaskProcessor) handleSendAccountLockedEmail(ctx context.Context, t *asynq.Task) error {
+	var payload task.PayloadSendAccountLockedEmail
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("invalid payload: %v", err)
+	}
+
+	forgotLink := p.forgotPasswordLink()
+
+	emailBody := fmt.Sprintf(`
+        <h1>Your account was temporarily locked</h1>
+        <p>We locked sign-in to your account after too many failed login attempts, the last one from %s.</p>
+        <p>You can try again after %s.</p>
+        <p>If this wasn't you, <a href="%s">reset your password</a>.</p>
+    `, payload.IP, payload.LockedUntil.UTC().Format("2006-01-02 15:04 MST"), forgotLink)
+
+	if err := p.EmailSender.Send(payload.Email, "Your BookShare account was locked", emailBody); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	log.Printf("Sent account locked email to %s", payload.Email)
+	return nil
+}
+
+func (p *TaskProcessor) handleWriteAuditLogs(ctx context.Context, t *asynq.Task) error {
+	var payload task.PayloadWriteAuditLogs
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("invalid payload: %v", err)
+	}
+
+	if err := audit.InsertAuditLogs(ctx, p.DB, payload.Entries); err != nil {
+		return fmt.Errorf("failed to write audit logs: %w", err)
+	}
+
+	log.Printf("Wrote %d spilled audit log entries", len(payload.Entries))
+	return nil
+}
+
+func (p *TaskProcessor) handleEnrichBookMetadata(ctx context.Context, t *asynq.Task) error {
+	var payload task.PayloadEnrichBookMetadata
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("invalid payload: %v: %w", err, asynq.SkipRetry)
+	}
+
+	meta, err := p.Metadata.Lookup(ctx, payload.ISBN)
+	if errors.Is(err, metadata.ErrNotFound) {
+		log.Printf("No metadata for ISBN %s (book %s)", payload.ISBN, payload.BookId)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up isbn %s: %w", payload.ISBN, err)
+	}
+
+	// The ISBN may have been edited since the task was queued
+	res := p.DB.WithContext(ctx).Table("books.books").
+		Where("id = ? AND isbn = ?", payload.BookId, payload.ISBN).
+		Updates(map[string]any{
+			"cover_url":        meta.CoverURL,
+			"publisher":        meta.Publisher,
+			"page_count":       meta.PageCount,
+			"publication_year": meta.PublicationYear,
+		})
+	if res.Error != nil {
+		return fmt.Errorf("failed to update book metadata: %w", res.Error)
+	}
+
+	log.Printf("Enriched book %s from ISBN %s (%d row(s) updated)", payload.BookId, payload.ISBN, res.RowsAffected)
+	return nil
+}
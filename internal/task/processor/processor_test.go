@@ -0,0 +1,60 @@
+package processor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/config"
+	"github.com/DMaryanskiy/bookshare-api/internal/user"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+// pageRouter registers the pages emails link to at the paths cmd/api uses.
+func pageRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	h := &user.Handler{}
+	router := gin.New()
+	public := router.Group("/api/v1")
+	public.GET("/reset-password", h.ResetPasswordPage)
+	public.GET("/forgot-password", h.ForgotPasswordPage)
+	return router
+}
+
+func TestEmailLinks_ResolveToPages(t *testing.T) {
+	p := NewTaskProcessor(nil, nil, nil, config.HTTPConfig{Host: "books.example.com", Port: 8443})
+	router := pageRouter()
+
+	links := map[string]string{
+		"password reset":  p.resetPasswordLink("abc123", "11111111-1111-1111-1111-111111111111"),
+		"forgot password": p.forgotPasswordLink(),
+	}
+
+	for name, link := range links {
+		u, err := url.Parse(link)
+		require.NoError(t, err, name)
+		require.Equal(t, "books.example.com:8443", u.Host, name)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, u.RequestURI(), nil)
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code, "%s link %s", name, link)
+		require.Contains(t, w.Header().Get("Content-Type"), "text/html", name)
+	}
+}
+
+func TestResetPasswordPage_EmbedsTokenSafely(t *testing.T) {
+	router := pageRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/reset-password?token="+url.QueryEscape(`"</script><script>alert(1)`)+"&uid=u", nil)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotContains(t, w.Body.String(), "<script>alert(1)")
+	require.Equal(t, "no-referrer", w.Header().Get("Referrer-Policy"))
+}
@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// sensitiveKeys are field names no response may carry, compared after
+// lower-casing and dropping underscores so that both PasswordHash and
+// password_hash are caught.
+var sensitiveKeys = map[string]bool{
+	"password":     true,
+	"passwordhash": true,
+	"totpsecret":   true,
+	"codehash":     true,
+	"tokenhash":    true,
+}
+
+var bcryptHash = regexp.MustCompile(`^\$2[abxy]?\$\d{2}\$`)
+
+// RequireNoSensitiveFields fails the test if the JSON body contains a
+// credential field at any depth, or any value that looks like a bcrypt hash.
+func RequireNoSensitiveFields(t *testing.T, body []byte) {
+	t.Helper()
+
+	var doc any
+	require.NoError(t, json.Unmarshal(body, &doc), "response is not JSON: %s", body)
+
+	for _, problem := range findSensitive(doc, "$") {
+		t.Errorf("response leaks %s: %s", problem, body)
+	}
+}
+
+func findSensitive(v any, path string) []string {
+	var problems []string
+	switch v := v.(type) {
+	case map[string]any:
+		for key, child := range v {
+			childPath := path + "." + key
+			if sensitiveKeys[strings.ReplaceAll(strings.ToLower(key), "_", "")] {
+				problems = append(problems, childPath)
+			}
+			problems = append(problems, findSensitive(child, childPath)...)
+		}
+	case []any:
+		for i, child := range v {
+			problems = append(problems, findSensitive(child, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case string:
+		if bcryptHash.MatchString(v) {
+			problems = append(problems, path+" (bcrypt hash)")
+		}
+	}
+	return problems
+}
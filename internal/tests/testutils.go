@@ -7,6 +7,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
+	"github.com/DMaryanskiy/bookshare-api/internal/config"
 	"github.com/DMaryanskiy/bookshare-api/internal/db"
 	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
 	"github.com/DMaryanskiy/bookshare-api/pkg/utils"
@@ -15,7 +17,6 @@ import (
 	"github.com/joho/godotenv"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/require"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 
 	_ "github.com/golang-migrate/migrate/v4/source/file"
@@ -27,21 +28,38 @@ func init() {
 	if err != nil {
 		log.Fatalf("Failed to load env: %v", err)
 	}
-}
 
-var TestDB *gorm.DB
+	cfg, err := config.Load(config.SectionJWT)
+	if err != nil {
+		log.Fatal(err)
+	}
+	keySet, err := utils.NewKeySet([]byte(cfg.JWT.Secret), cfg.JWT.KeysDir, cfg.JWT.ActiveKID)
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+	utils.SetDefaultKeySet(keySet)
+}
 
-func SetupTestDB(t *testing.T) {
-	dsn := os.Getenv("TEST_DATABASE_URL")
-	dbConn, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+// SetupTestDB connects to the test database and migrates it from scratch.
+func SetupTestDB(t *testing.T) *gorm.DB {
+	dbConn, err := db.Open(os.Getenv("TEST_DATABASE_URL"))
 	if err != nil {
 		t.Fatalf("failed to connect to test database: %v", err)
 	}
 
-	db.DB = dbConn
-	TestDB = dbConn
-
 	RunMigrations(t, dbConn)
+	return dbConn
+}
+
+// Conns uses gdb as both the primary and the read replica.
+func Conns(gdb *gorm.DB) db.Conns {
+	return db.Conns{Primary: gdb, Replica: gdb}
+}
+
+// AuditLogger writes audit entries to gdb before Log returns, so tests can
+// check them straight away.
+func AuditLogger(gdb *gorm.DB) *audit.Logger {
+	return audit.NewSyncLogger(audit.DBStore{DB: gdb})
 }
 
 func RunMigrations(t *testing.T, db *gorm.DB) {
@@ -94,18 +112,25 @@ func GetAuthHeader(token string) string {
 	return "Bearer " + token
 }
 
-func CreateTestUser(t *testing.T, email, password string) (models.User, string) {
+func CreateTestUser(t *testing.T, gdb *gorm.DB, email, password string) (models.User, string) {
+	return CreateTestUserWithRole(t, gdb, email, password, models.RoleUser)
+}
+
+// CreateTestUserWithRole creates a verified user with the given role and an
+// access token carrying that role.
+func CreateTestUserWithRole(t *testing.T, gdb *gorm.DB, email, password, role string) (models.User, string) {
 	hashedPassword, _ := utils.HashPassword(password)
 	user := models.User{
 		Email:        email,
 		PasswordHash: hashedPassword,
 		IsVerified:   true,
+		Role:         role,
 	}
-	require.NotNil(t, db.DB, "db.DB is nil — make sure SetupTestDB was called before this")
-	err := db.DB.Table("auth.users").Create(&user).Error
+	require.NotNil(t, gdb, "gdb is nil — make sure SetupTestDB was called before this")
+	err := gdb.Table("auth.users").Create(&user).Error
 	require.NoError(t, err)
 
-	token, err := utils.GenerateAccessToken(user.ID.String(), 24*time.Hour)
+	token, err := utils.GenerateAccessToken(user.ID.String(), user.Role, 24*time.Hour)
 	require.NoError(t, err)
 
 	return user, token
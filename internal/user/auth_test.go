@@ -9,7 +9,8 @@ import (
 	"testing"
 	"time"
 
-	"github.com/DMaryanskiy/bookshare-api/internal/db"
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
+	"github.com/DMaryanskiy/bookshare-api/internal/auth"
 	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
 	"github.com/DMaryanskiy/bookshare-api/internal/middleware"
 	"github.com/DMaryanskiy/bookshare-api/internal/task/distributor"
@@ -18,33 +19,36 @@ import (
 	"github.com/DMaryanskiy/bookshare-api/pkg/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 )
 
-func setupAuthRouter(t *testing.T, tokenStore *FakeTokenStore) *gin.Engine {
+func setupAuthRouter(t *testing.T, tokenStore *FakeTokenStore) (*gin.Engine, *gorm.DB) {
 	gin.SetMode(gin.TestMode)
 
-	tests.SetupTestDB(t)
+	gdb := tests.SetupTestDB(t)
 	tests.SetupTestRedis()
 
 	router := gin.Default()
 	dist := distributor.NewTaskDistributor(tests.TestRedis.Options().Addr)
 
-	h := user.NewHandler(dist, tokenStore)
+	denylist := auth.NewDenylist(tests.TestRedis)
+	h := user.NewHandler(gdb, tests.AuditLogger(gdb), dist, tokenStore, denylist, tests.TestRedis)
 	router.POST("/login", h.LoginUser)
 	router.POST("/refresh", h.RefreshToken)
 
 	protected := router.Group("/protected")
-	protected.Use(middleware.JWTAuthMiddleware())
+	protected.Use(middleware.JWTAuthMiddleware(denylist))
 	protected.GET("", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "ok"})
 	})
+	protected.POST("/logout", h.Logout)
 
-	return router
+	return router, gdb
 }
 
 func TestLogin_Success(t *testing.T) {
 	tokenStore := NewFakeTokenStore()
-	r := setupAuthRouter(t, tokenStore)
+	r, gdb := setupAuthRouter(t, tokenStore)
 
 	// Create a verified user first
 	hashedPassword, _ := utils.HashPassword("password123")
@@ -53,7 +57,7 @@ func TestLogin_Success(t *testing.T) {
 		PasswordHash: hashedPassword,
 		IsVerified:   true,
 	}
-	require.NoError(t, db.DB.Table("auth.users").Create(&user).Error)
+	require.NoError(t, gdb.Table("auth.users").Create(&user).Error)
 
 	body := map[string]string{
 		"email":    "login@example.com",
@@ -79,7 +83,7 @@ func TestLogin_Success(t *testing.T) {
 
 func TestLogin_Failure(t *testing.T) {
 	tokenStore := NewFakeTokenStore()
-	r := setupAuthRouter(t, tokenStore)
+	r, _ := setupAuthRouter(t, tokenStore)
 
 	body := map[string]string{
 		"email":    "nonexistent@example.com",
@@ -98,7 +102,7 @@ func TestLogin_Failure(t *testing.T) {
 
 func TestRefreshToken_Success(t *testing.T) {
 	tokenStore := NewFakeTokenStore() // shared instance
-	r := setupAuthRouter(t, tokenStore)
+	r, gdb := setupAuthRouter(t, tokenStore)
 
 	// Use FakeTokenStore to create a token
 
@@ -108,8 +112,8 @@ func TestRefreshToken_Success(t *testing.T) {
 		PasswordHash: hashedPassword,
 		IsVerified:   true,
 	}
-	require.NoError(t, db.DB.Table("auth.users").Create(&user).Error)
-	refreshToken, err := tokenStore.CreateRefreshToken(context.TODO(), user.ID.String(), time.Hour*24)
+	require.NoError(t, gdb.Table("auth.users").Create(&user).Error)
+	refreshToken, err := tokenStore.CreateRefreshToken(context.TODO(), user.ID.String(), auth.SessionMeta{}, time.Hour*24)
 	require.NoError(t, err)
 
 	body := map[string]string{
@@ -135,7 +139,7 @@ func TestRefreshToken_Success(t *testing.T) {
 
 func TestProtectedRoute_Access(t *testing.T) {
 	tokenStore := NewFakeTokenStore()
-	r := setupAuthRouter(t, tokenStore)
+	r, gdb := setupAuthRouter(t, tokenStore)
 
 	// Create a verified user and login to get token
 	hashedPassword, _ := utils.HashPassword("password123")
@@ -144,10 +148,10 @@ func TestProtectedRoute_Access(t *testing.T) {
 		PasswordHash: hashedPassword,
 		IsVerified:   true,
 	}
-	require.NoError(t, db.DB.Table("auth.users").Create(&user).Error)
+	require.NoError(t, gdb.Table("auth.users").Create(&user).Error)
 
 	// Manually generate JWT token (or login and get it)
-	token, err := utils.GenerateAccessToken(user.ID.String(), time.Hour)
+	token, err := utils.GenerateAccessToken(user.ID.String(), user.Role, time.Hour)
 	require.NoError(t, err)
 
 	w := httptest.NewRecorder()
@@ -161,7 +165,7 @@ func TestProtectedRoute_Access(t *testing.T) {
 
 func TestProtectedRoute_AccessDenied(t *testing.T) {
 	tokenStore := NewFakeTokenStore()
-	r := setupAuthRouter(t, tokenStore)
+	r, _ := setupAuthRouter(t, tokenStore)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/protected", nil)
@@ -171,3 +175,213 @@ func TestProtectedRoute_AccessDenied(t *testing.T) {
 
 	require.Equal(t, http.StatusUnauthorized, w.Code)
 }
+
+func TestRefreshToken_ReuseRevokesSession(t *testing.T) {
+	tokenStore := NewFakeTokenStore()
+	r, gdb := setupAuthRouter(t, tokenStore)
+
+	hashedPassword, _ := utils.HashPassword("password123")
+	user := models.User{
+		Email:        "reuse@example.com",
+		PasswordHash: hashedPassword,
+		IsVerified:   true,
+	}
+	require.NoError(t, gdb.Table("auth.users").Create(&user).Error)
+
+	original, err := tokenStore.CreateRefreshToken(context.TODO(), user.ID.String(), auth.SessionMeta{}, time.Hour*24)
+	require.NoError(t, err)
+
+	refresh := func(token string) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(map[string]string{"refresh_token": token})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/refresh", bytes.NewBuffer(data))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := refresh(original)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	rotated := resp["refresh_token"]
+
+	// Replaying the original token revokes the whole family
+	w = refresh(original)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = refresh(rotated)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	var count int64
+	gdb.Table("logs.audit_logs").
+		Where("user_id = ? AND action = ?", user.ID, "refresh_token_reuse_detected").
+		Count(&count)
+	require.Equal(t, int64(1), count)
+}
+
+func TestLogout_RevokesAccessToken(t *testing.T) {
+	tokenStore := NewFakeTokenStore()
+	r, gdb := setupAuthRouter(t, tokenStore)
+
+	user, token := tests.CreateTestUser(t, gdb, "logout@example.com", "password123")
+	refreshToken, err := tokenStore.CreateRefreshToken(context.TODO(), user.ID.String(), auth.SessionMeta{}, time.Hour)
+	require.NoError(t, err)
+
+	data, _ := json.Marshal(map[string]string{"refresh_token": refreshToken})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/protected/logout", bytes.NewBuffer(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", tests.GetAuthHeader(token))
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// The access token is rejected even though it has not expired
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/protected", nil)
+	req.Header.Set("Authorization", tests.GetAuthHeader(token))
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestLogin_BackoffAndLockout(t *testing.T) {
+	tokenStore := NewFakeTokenStore()
+	r, gdb := setupAuthRouter(t, tokenStore)
+
+	u, _ := tests.CreateTestUser(t, gdb, "locked@example.com", "password123")
+
+	login := func(password string) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(map[string]string{"email": "locked@example.com", "password": password})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/login", bytes.NewBuffer(data))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < auth.BackoffThreshold; i++ {
+		require.Equal(t, http.StatusUnauthorized, login("wrong").Code)
+	}
+
+	// Further attempts have to wait, even with the right password
+	w := login("password123")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	// Skip ahead to the attempt that locks the account
+	throttle := auth.NewLoginThrottle(tests.TestRedis)
+	for i := auth.BackoffThreshold; i < auth.LockThreshold-1; i++ {
+		_, err := throttle.RecordFailure(context.TODO(), "locked@example.com")
+		require.NoError(t, err)
+	}
+	tests.TestRedis.Del(context.TODO(), "login_blocked:locked@example.com")
+
+	w = login("wrong")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "901", w.Header().Get("Retry-After"))
+
+	require.Equal(t, http.StatusTooManyRequests, login("password123").Code)
+
+	var failed, locked int64
+	gdb.Table("logs.audit_logs").Where("user_id = ? AND action = ?", u.ID, "login_failed").Count(&failed)
+	gdb.Table("logs.audit_logs").Where("user_id = ? AND action = ?", u.ID, "account_locked").Count(&locked)
+	require.Equal(t, int64(auth.BackoffThreshold+1), failed)
+	require.Equal(t, int64(1), locked)
+}
+
+func TestLogin_SuccessResetsFailures(t *testing.T) {
+	tokenStore := NewFakeTokenStore()
+	r, gdb := setupAuthRouter(t, tokenStore)
+
+	_, _ = tests.CreateTestUser(t, gdb, "flaky@example.com", "password123")
+
+	login := func(password string) int {
+		data, _ := json.Marshal(map[string]string{"email": "flaky@example.com", "password": password})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/login", bytes.NewBuffer(data))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for i := 0; i < auth.BackoffThreshold-1; i++ {
+		require.Equal(t, http.StatusUnauthorized, login("wrong"))
+	}
+	require.Equal(t, http.StatusOK, login("password123"))
+
+	// The streak starts over, so this failure doesn't trigger a delay
+	require.Equal(t, http.StatusUnauthorized, login("wrong"))
+	require.Equal(t, http.StatusOK, login("password123"))
+}
+
+func TestLogin_AuditEvents(t *testing.T) {
+	tokenStore := NewFakeTokenStore()
+	r, gdb := setupAuthRouter(t, tokenStore)
+
+	u, _ := tests.CreateTestUser(t, gdb, "audited@example.com", "password123")
+
+	login := func(email, password string) int {
+		data, _ := json.Marshal(map[string]string{"email": email, "password": password})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/login", bytes.NewBuffer(data))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "audit-test")
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusOK, login("audited@example.com", "password123"))
+	require.Equal(t, http.StatusUnauthorized, login("audited@example.com", "wrong"))
+	require.Equal(t, http.StatusUnauthorized, login("ghost@example.com", "wrong"))
+
+	var success models.AuditLog
+	require.NoError(t, gdb.Table("logs.audit_logs").
+		Where("user_id = ? AND action = ?", u.ID, audit.ActionLoginSuccess).
+		First(&success).Error)
+
+	var meta audit.Meta
+	require.NoError(t, json.Unmarshal([]byte(*success.Metadata), &meta))
+	require.Equal(t, audit.OutcomeSuccess, meta.Outcome)
+	require.Equal(t, "audit-test", meta.UserAgent)
+	require.Equal(t, "password", meta.Details["method"])
+
+	var failed models.AuditLog
+	require.NoError(t, gdb.Table("logs.audit_logs").
+		Where("user_id = ? AND action = ?", u.ID, audit.ActionLoginFailed).
+		First(&failed).Error)
+	require.NoError(t, json.Unmarshal([]byte(*failed.Metadata), &meta))
+	require.Equal(t, audit.OutcomeFailure, meta.Outcome)
+	require.Equal(t, "invalid_password", meta.Reason)
+
+	// Attempts on unknown accounts are kept without a user reference
+	var unknown models.AuditLog
+	require.NoError(t, gdb.Table("logs.audit_logs").
+		Where("user_id IS NULL AND action = ?", audit.ActionLoginFailed).
+		First(&unknown).Error)
+	require.Nil(t, unknown.UserID)
+	require.Contains(t, *unknown.Metadata, "ghost@example.com")
+}
+
+func TestLoginAndRefresh_RejectSuspendedUser(t *testing.T) {
+	tokenStore := NewFakeTokenStore()
+	r, gdb := setupAuthRouter(t, tokenStore)
+
+	u, _ := tests.CreateTestUser(t, gdb, "banned@example.com", "password123")
+	refreshToken, err := tokenStore.CreateRefreshToken(context.TODO(), u.ID.String(), auth.SessionMeta{}, time.Hour)
+	require.NoError(t, err)
+
+	require.NoError(t, gdb.Table("auth.users").Where("id = ?", u.ID).Update("suspended_at", time.Now()).Error)
+
+	w := postJSON(r, "/login", map[string]string{"email": "banned@example.com", "password": "password123"})
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.NotContains(t, w.Body.String(), "access_token")
+
+	// A wrong password still reads as invalid credentials
+	w = postJSON(r, "/login", map[string]string{"email": "banned@example.com", "password": "wrong"})
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = postJSON(r, "/refresh", map[string]string{"refresh_token": refreshToken})
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.Empty(t, tokenStore.Tokens)
+}
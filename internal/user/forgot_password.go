@@ -0,0 +1,76 @@
+package user
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
+	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"github.com/DMaryanskiy/bookshare-api/internal/task"
+	"github.com/gin-gonic/gin"
+)
+
+const forgotPasswordCooldown = 2 * time.Minute
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ForgotPassword godoc
+// @Summary      Request a password reset
+// @Description  Sends a single-use password reset link if the account exists. The response is the same either way.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        email  body  ForgotPasswordRequest  true  "Account email"
+// @Success      200  {object}  map[string]string  "Reset email sent if the account exists"
+// @Failure      400  {object}  map[string]string  "Invalid input"
+// @Failure      500  {object}  map[string]string  "Server error"
+// @Router       /auth/forgot-password [post]
+func (h *Handler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "valid email required"})
+		return
+	}
+
+	// Never reveal whether the email is registered
+	resp := gin.H{"message": "if the account exists, a password reset email has been sent"}
+
+	// One email per address per cooldown. Requests inside it get the same
+	// answer, so they cannot be told apart from sent ones
+	key := "password_reset:" + strings.ToLower(req.Email)
+	ok, err := h.Redis.SetNX(c, key, 1, forgotPasswordCooldown).Result()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not process request"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	var user models.User
+	if err := h.DB.Table("auth.users").
+		Where("email = ?", req.Email).
+		First(&user).Error; err != nil {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	payload := task.PayloadSendPasswordResetEmail{
+		UserId: user.ID.String(),
+		Email:  user.Email,
+	}
+	if err := h.TaskDistributor.DistributePasswordResetEmail(c, payload); err != nil {
+		log.Printf("Failed to enqueue password reset email for %s: %v", user.ID, err)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	h.Audit.Log(user.ID, audit.ActionPasswordResetRequested, audit.Request(c))
+
+	c.JSON(http.StatusOK, resp)
+}
@@ -1,15 +1,57 @@
 package user
 
 import (
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
 	"github.com/DMaryanskiy/bookshare-api/internal/auth"
 	"github.com/DMaryanskiy/bookshare-api/internal/task/distributor"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
 )
 
 type Handler struct {
+	DB              *gorm.DB
+	Audit           *audit.Logger
 	TaskDistributor *distributor.TaskDistributor
-	TokenStore auth.RefreshTokenStore
+	TokenStore      auth.RefreshTokenStore
+	Denylist        *auth.Denylist
+	LoginThrottle   *auth.LoginThrottle
+	Redis           *redis.Client
+}
+
+func NewHandler(gdb *gorm.DB, auditLog *audit.Logger, dist *distributor.TaskDistributor, ts auth.RefreshTokenStore, denylist *auth.Denylist, rdb *redis.Client) *Handler {
+	return &Handler{
+		DB:              gdb,
+		Audit:           auditLog,
+		TaskDistributor: dist,
+		TokenStore:      ts,
+		Denylist:        denylist,
+		LoginThrottle:   auth.NewLoginThrottle(rdb),
+		Redis:           rdb,
+	}
+}
+
+// auditUserID returns id as a UUID, or uuid.Nil if it is not one. Ids
+// taken from a request may name no user; the audit log keeps them anyway,
+// since it does not reference users by foreign key.
+func auditUserID(id string) uuid.UUID {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil
+	}
+	return uid
 }
 
-func NewHandler(dist *distributor.TaskDistributor, ts auth.RefreshTokenStore) *Handler {
-	return &Handler{TaskDistributor: dist, TokenStore: ts}
+// revokeCurrentAccessToken denies the access token that authenticated the
+// request, using the claims JWTAuthMiddleware put in the context.
+func (h *Handler) revokeCurrentAccessToken(c *gin.Context) error {
+	expiresAt, _ := c.Get("token_expires_at")
+	exp, ok := expiresAt.(time.Time)
+	if !ok {
+		return nil
+	}
+	return h.Denylist.RevokeToken(c, c.GetString("token_id"), exp)
 }
@@ -0,0 +1,27 @@
+package user
+
+import (
+	"net/http"
+
+	"github.com/DMaryanskiy/bookshare-api/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// JWKS godoc
+// @Summary      JSON Web Key Set
+// @Description  Returns the public keys used to verify access tokens, including retired keys whose tokens have not yet expired
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  utils.JWKS  "Public signing keys"
+// @Failure      500  {object}  map[string]string  "Keyset not available"
+// @Router       /.well-known/jwks.json [get]
+func (h *Handler) JWKS(c *gin.Context) {
+	ks, err := utils.DefaultKeySet()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "keyset not available"})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, ks.JWKS())
+}
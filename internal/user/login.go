@@ -1,30 +1,38 @@
 package user
 
 import (
+	"fmt"
+	"log"
 	"net/http"
 	"time"
 
-	"github.com/DMaryanskiy/bookshare-api/internal/db"
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
+	"github.com/DMaryanskiy/bookshare-api/internal/auth"
 	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"github.com/DMaryanskiy/bookshare-api/internal/task"
 	"github.com/DMaryanskiy/bookshare-api/pkg/utils"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
+	Device   string `json:"device" binding:"max=100"`
 }
 
 // LoginUser godoc
 // @Summary      Login user
-// @Description  Authenticates a user and returns access and refresh tokens
+// @Description  Authenticates a user and returns access and refresh tokens. Users with TOTP enabled get an MFA challenge token instead, to be exchanged at /login/mfa.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        credentials  body      LoginRequest  true  "Email and Password"
-// @Success      200  {object}  map[string]string  "Returns access and refresh tokens"
+// @Success      200  {object}  map[string]interface{}  "Returns access and refresh tokens, or mfa_required and mfa_token"
 // @Failure      400  {object}  map[string]string  "Invalid input"
 // @Failure      401  {object}  map[string]string  "Unauthorized - invalid credentials or not verified"
+// @Failure      403  {object}  map[string]string  "Account suspended"
+// @Failure      429  {object}  map[string]string  "Too many failed attempts - backoff or temporary lockout, see Retry-After"
 // @Failure      500  {object}  map[string]string  "Internal error"
 func (h *Handler) LoginUser(c *gin.Context) {
 	var req LoginRequest
@@ -33,38 +41,150 @@ func (h *Handler) LoginUser(c *gin.Context) {
 		return
 	}
 
+	throttle, err := h.LoginThrottle.Check(c, req.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not process login"})
+		return
+	}
+	if throttle.RetryAfter > 0 {
+		reason := "throttled"
+		if throttle.Locked {
+			reason = "account_locked"
+		}
+		h.Audit.Log(uuid.Nil, audit.ActionLoginFailed, audit.Request(c).
+			Failure(reason).
+			With("email", req.Email))
+		rejectThrottled(c, throttle)
+		return
+	}
+
 	var user models.User
-	if err := db.DB.Table("auth.users").
+	if err := h.DB.Table("auth.users").
 		Where("email = ?", req.Email).
 		First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid email or password"})
+		h.recordLoginFailure(c, req.Email, nil, "unknown_email", "invalid email or password")
 		return
 	}
 
 	ok, err := utils.CheckPasswordHash(user.PasswordHash, req.Password)
 	if !ok || err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid email or password"})
-        return
+		h.recordLoginFailure(c, req.Email, &user, "invalid_password", "invalid email or password")
+		return
+	}
+
+	if user.SuspendedAt != nil {
+		h.Audit.Log(user.ID, audit.ActionLoginFailed, audit.Request(c).Failure("suspended"))
+		c.JSON(http.StatusForbidden, gin.H{"error": "account suspended"})
+		return
 	}
 
 	if !user.IsVerified {
+		h.Audit.Log(user.ID, audit.ActionLoginFailed, audit.Request(c).Failure("not_verified"))
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "user is not verified"})
+		return
+	}
+
+	// With TOTP enabled the password alone is not enough: hand out a
+	// challenge token to be exchanged at /login/mfa with a code. The failure
+	// streak is kept until the code is right, so wrong codes add up across
+	// challenges.
+	if user.TOTPEnabled {
+		mfaToken, err := utils.GenerateMFAToken(user.ID.String(), mfaTokenTTL)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create mfa token"})
+			return
+		}
+
+		h.Audit.Log(user.ID, audit.ActionLoginMFARequired, audit.Request(c))
+
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+		})
+		return
+	}
+
+	if err := h.LoginThrottle.Reset(c, req.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not process login"})
+		return
+	}
+
+	h.issueTokens(c, user, req.Device, "password")
+}
+
+// recordLoginFailure counts a failed attempt against the account and
+// responds 401 with message, or 429 once the attempt triggers a lockout.
+// user is nil when the email is not registered.
+func (h *Handler) recordLoginFailure(c *gin.Context, email string, user *models.User, reason, message string) {
+	throttle, err := h.LoginThrottle.RecordFailure(c, email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not process login"})
+		return
+	}
+
+	userID := uuid.Nil
+	if user != nil {
+		userID = user.ID
+	}
+	h.Audit.Log(userID, audit.ActionLoginFailed, audit.Request(c).
+		Failure(reason).
+		With("email", email).
+		With("failures", throttle.Failures))
+
+	if !throttle.Locked {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": message})
+		return
+	}
+
+	if user != nil {
+		lockedUntil := time.Now().Add(throttle.RetryAfter)
+		h.Audit.Log(user.ID, audit.ActionAccountLocked, audit.Request(c).
+			With("failures", throttle.Failures).
+			With("locked_until", lockedUntil))
+
+		payload := task.PayloadSendAccountLockedEmail{
+			UserId:      user.ID.String(),
+			Email:       user.Email,
+			IP:          c.ClientIP(),
+			LockedUntil: lockedUntil,
+		}
+		if err := h.TaskDistributor.DistributeAccountLockedEmail(c, payload); err != nil {
+			log.Printf("Failed to enqueue account locked email for %s: %v", user.ID, err)
+		}
 	}
 
-	accessToken, err := utils.GenerateAccessToken(user.ID.String(), 15 * time.Minute)
+	rejectThrottled(c, throttle)
+}
+
+func rejectThrottled(c *gin.Context, throttle auth.Throttle) {
+	c.Header("Retry-After", fmt.Sprintf("%d", int(throttle.RetryAfter.Seconds())+1))
+	if throttle.Locked {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "account temporarily locked after too many failed login attempts"})
+		return
+	}
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed login attempts, please wait before trying again"})
+}
+
+// issueTokens starts a new session for the user and responds with its
+// access and refresh tokens. method records how the user authenticated.
+func (h *Handler) issueTokens(c *gin.Context, user models.User, device, method string) {
+	userID := user.ID.String()
+	accessToken, err := utils.GenerateAccessToken(userID, user.Role, 15*time.Minute)
 	if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create access token"})
-        return
-    }
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create access token"})
+		return
+	}
 
-	refreshToken, err := h.TokenStore.CreateRefreshToken(c, user.ID.String(), 24 * time.Hour)
+	refreshToken, err := h.TokenStore.CreateRefreshToken(c, userID, sessionMeta(c, device), 24*time.Hour)
 	if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create refresh token"})
-        return
-    }
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create refresh token"})
+		return
+	}
+
+	h.Audit.Log(user.ID, audit.ActionLoginSuccess, audit.Request(c).With("method", method))
 
 	c.JSON(http.StatusOK, gin.H{
-        "access_token":  accessToken,
-        "refresh_token": refreshToken,
-    })
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+	})
 }
@@ -3,6 +3,7 @@ package user
 import (
 	"net/http"
 
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
 	"github.com/gin-gonic/gin"
 )
 
@@ -12,7 +13,7 @@ type LogoutRequest struct {
 
 // LogoutUser godoc
 // @Summary      Logout user
-// @Description  Revokes the refresh token and logs the user out
+// @Description  Revokes the refresh token and the access token used for the request, logging the user out
 // @Tags         auth
 // @Accept       json
 // @Produce      json
@@ -34,5 +35,12 @@ func (h *Handler) Logout(c *gin.Context) {
         return
     }
 
+	if err := h.revokeCurrentAccessToken(c); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not revoke access token"})
+		return
+	}
+
+	h.Audit.Log(auditUserID(c.GetString("user_id")), audit.ActionLogout, audit.Request(c))
+
     c.JSON(http.StatusOK, gin.H{"message": "logged out successfully"})
 }
\ No newline at end of file
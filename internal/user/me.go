@@ -2,18 +2,29 @@ package user
 
 import (
 	"net/http"
+	"time"
 
-	"github.com/DMaryanskiy/bookshare-api/internal/db"
 	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
+// MeResponse is the authenticated user's view of their own account.
+type MeResponse struct {
+	ID          uuid.UUID `json:"id"`
+	Email       string    `json:"email"`
+	Verified    bool      `json:"verified"`
+	Role        string    `json:"role"`
+	TOTPEnabled bool      `json:"totp_enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
 // GetMe godoc
 // @Summary      Get current user
 // @Description  Returns the authenticated user's details
 // @Tags         user
 // @Produce      json
-// @Success      200  {object}  map[string]interface{}  "User info"
+// @Success      200  {object}  MeResponse  "User info"
 // @Failure      404  {object}  map[string]string  "User not found"
 // @Failure      500  {object}  map[string]string  "User ID missing from context"
 // @Router       /users/me [get]
@@ -25,15 +36,17 @@ func (h *Handler) GetMe(c *gin.Context) {
 	}
 
 	var user models.User
-	if err := db.DB.Table("auth.users").First(&user, "id = ?", userID).Error; err != nil {
+	if err := h.DB.Table("auth.users").First(&user, "id = ?", userID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"id":         user.ID,
-		"email":      user.Email,
-		"verified":   user.IsVerified,
-		"created_at": user.CreatedAt,
+	c.JSON(http.StatusOK, MeResponse{
+		ID:          user.ID,
+		Email:       user.Email,
+		Verified:    user.IsVerified,
+		Role:        user.Role,
+		TOTPEnabled: user.TOTPEnabled,
+		CreatedAt:   user.CreatedAt,
 	})
 }
@@ -0,0 +1,393 @@
+package user
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
+	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"github.com/DMaryanskiy/bookshare-api/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	totpIssuer        = "BookShare"
+	mfaTokenTTL       = 5 * time.Minute
+	mfaMaxAttempts    = 5
+	recoveryCodeCount = 10
+)
+
+var errInvalidCode = errors.New("invalid code")
+
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type DisableTOTPRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type LoginMFARequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+	Device   string `json:"device" binding:"max=100"`
+}
+
+// EnrollTOTP godoc
+// @Summary      Start TOTP enrollment
+// @Description  Generates a new TOTP secret for the authenticated user. Two-factor login is only enabled once a code is confirmed.
+// @Tags         mfa
+// @Produce      json
+// @Success      200  {object}  map[string]string  "Secret and otpauth URI"
+// @Failure      404  {object}  map[string]string  "User not found"
+// @Failure      409  {object}  map[string]string  "TOTP already enabled"
+// @Failure      500  {object}  map[string]string  "Server error"
+// @Router       /mfa/totp/enroll [post]
+func (h *Handler) EnrollTOTP(c *gin.Context) {
+	var user models.User
+	if err := h.DB.Table("auth.users").First(&user, "id = ?", c.GetString("user_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "totp is already enabled"})
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not generate secret"})
+		return
+	}
+
+	if err := h.DB.Table("auth.users").
+		Where("id = ?", user.ID).
+		Update("totp_secret", secret).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not save secret"})
+		return
+	}
+
+	h.Audit.Log(user.ID, audit.ActionTOTPEnrollmentStarted, audit.Request(c))
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_url": utils.TOTPURI(totpIssuer, user.Email, secret),
+	})
+}
+
+// ConfirmTOTP godoc
+// @Summary      Confirm TOTP enrollment
+// @Description  Enables two-factor login once the user proves their authenticator works, and returns single-use recovery codes. The codes are only shown once.
+// @Tags         mfa
+// @Accept       json
+// @Produce      json
+// @Param        code  body  MFACodeRequest  true  "Code from the authenticator app"
+// @Success      200  {object}  map[string][]string  "Recovery codes"
+// @Failure      400  {object}  map[string]string  "Invalid code or no enrollment in progress"
+// @Failure      409  {object}  map[string]string  "TOTP already enabled"
+// @Failure      500  {object}  map[string]string  "Server error"
+// @Router       /mfa/totp/confirm [post]
+func (h *Handler) ConfirmTOTP(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code required"})
+		return
+	}
+
+	var user models.User
+	if err := h.DB.Table("auth.users").First(&user, "id = ?", c.GetString("user_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "totp is already enabled"})
+		return
+	}
+	if user.TOTPSecret == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no totp enrollment in progress"})
+		return
+	}
+
+	if err := h.checkTOTP(c, user.ID, *user.TOTPSecret, req.Code); err != nil {
+		h.Audit.Log(user.ID, audit.ActionTOTPChangeFailed, audit.Request(c).
+			Failure("invalid_code").
+			With("change", "enable"))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid code"})
+		return
+	}
+
+	codes, rows, err := generateRecoveryCodes(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not generate recovery codes"})
+		return
+	}
+
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("auth.recovery_codes").
+			Where("user_id = ?", user.ID).
+			Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("auth.recovery_codes").Create(&rows).Error; err != nil {
+			return err
+		}
+		return tx.Table("auth.users").
+			Where("id = ?", user.ID).
+			Update("totp_enabled", true).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not enable totp"})
+		return
+	}
+
+	h.Audit.Log(user.ID, audit.ActionTOTPEnabled, audit.Request(c))
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// DisableTOTP godoc
+// @Summary      Disable TOTP
+// @Description  Turns off two-factor login. Requires the password and a TOTP or recovery code.
+// @Tags         mfa
+// @Accept       json
+// @Produce      json
+// @Param        body  body  DisableTOTPRequest  true  "Password and code"
+// @Success      200  {object}  map[string]string  "TOTP disabled"
+// @Failure      400  {object}  map[string]string  "Invalid input or TOTP not enabled"
+// @Failure      401  {object}  map[string]string  "Invalid password or code"
+// @Failure      500  {object}  map[string]string  "Server error"
+// @Router       /mfa/totp/disable [post]
+func (h *Handler) DisableTOTP(c *gin.Context) {
+	var req DisableTOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "password and code required"})
+		return
+	}
+
+	var user models.User
+	if err := h.DB.Table("auth.users").First(&user, "id = ?", c.GetString("user_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if !user.TOTPEnabled || user.TOTPSecret == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "totp is not enabled"})
+		return
+	}
+
+	ok, err := utils.CheckPasswordHash(user.PasswordHash, req.Password)
+	if !ok || err != nil {
+		h.Audit.Log(user.ID, audit.ActionTOTPChangeFailed, audit.Request(c).
+			Failure("invalid_password").
+			With("change", "disable"))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid password or code"})
+		return
+	}
+	if _, err := h.verifySecondFactor(c, user, req.Code); err != nil {
+		h.Audit.Log(user.ID, audit.ActionTOTPChangeFailed, audit.Request(c).
+			Failure("invalid_code").
+			With("change", "disable"))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid password or code"})
+		return
+	}
+
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("auth.recovery_codes").
+			Where("user_id = ?", user.ID).
+			Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Table("auth.users").
+			Where("id = ?", user.ID).
+			Updates(map[string]any{"totp_enabled": false, "totp_secret": nil}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not disable totp"})
+		return
+	}
+
+	h.Audit.Log(user.ID, audit.ActionTOTPDisabled, audit.Request(c))
+
+	c.JSON(http.StatusOK, gin.H{"message": "totp disabled"})
+}
+
+// LoginMFA godoc
+// @Summary      Complete two-factor login
+// @Description  Exchanges the MFA challenge token from /login and a TOTP or recovery code for access and refresh tokens
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        body  body  LoginMFARequest  true  "MFA token and code"
+// @Success      200  {object}  map[string]string  "Access and refresh tokens"
+// @Failure      400  {object}  map[string]string  "Invalid input"
+// @Failure      401  {object}  map[string]string  "Invalid or expired MFA token, or invalid code"
+// @Failure      403  {object}  map[string]string  "Account suspended"
+// @Failure      429  {object}  map[string]string  "Too many failed attempts - backoff or temporary lockout, see Retry-After"
+// @Failure      500  {object}  map[string]string  "Server error"
+// @Router       /login/mfa [post]
+func (h *Handler) LoginMFA(c *gin.Context) {
+	var req LoginMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid mfa input"})
+		return
+	}
+
+	claims, err := utils.ParseMFAToken(req.MFAToken)
+	if err != nil {
+		h.Audit.Log(uuid.Nil, audit.ActionLoginFailed, audit.Request(c).Failure("invalid_mfa_token"))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired mfa token"})
+		return
+	}
+	revoked, err := h.Denylist.IsRevoked(c, claims)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not validate mfa token"})
+		return
+	}
+	if revoked {
+		h.Audit.Log(auditUserID(claims.UserID), audit.ActionLoginFailed, audit.Request(c).Failure("mfa_token_revoked"))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired mfa token"})
+		return
+	}
+
+	var user models.User
+	if err := h.DB.Table("auth.users").First(&user, "id = ?", claims.UserID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired mfa token"})
+		return
+	}
+	if !user.TOTPEnabled || user.TOTPSecret == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired mfa token"})
+		return
+	}
+	if user.SuspendedAt != nil {
+		h.Audit.Log(user.ID, audit.ActionLoginFailed, audit.Request(c).Failure("suspended"))
+		c.JSON(http.StatusForbidden, gin.H{"error": "account suspended"})
+		return
+	}
+
+	// Wrong codes count towards the same backoff and lockout as wrong
+	// passwords, so getting a fresh challenge does not buy more guesses
+	throttle, err := h.LoginThrottle.Check(c, user.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not verify code"})
+		return
+	}
+	if throttle.RetryAfter > 0 {
+		reason := "throttled"
+		if throttle.Locked {
+			reason = "account_locked"
+		}
+		h.Audit.Log(user.ID, audit.ActionLoginFailed, audit.Request(c).Failure(reason))
+		rejectThrottled(c, throttle)
+		return
+	}
+
+	method, err := h.verifySecondFactor(c, user, req.Code)
+	if errors.Is(err, errInvalidCode) {
+		// Bound the guesses per challenge; a new one needs the password again
+		key := "mfa_attempts:" + claims.ID
+		attempts, _ := h.Redis.Incr(c, key).Result()
+		h.Redis.Expire(c, key, mfaTokenTTL)
+		if attempts >= mfaMaxAttempts {
+			_ = h.Denylist.RevokeToken(c, claims.ID, claims.ExpiresAt.Time)
+		}
+		h.recordLoginFailure(c, user.Email, &user, "invalid_mfa_code", "invalid code")
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not verify code"})
+		return
+	}
+
+	// The challenge is single use
+	if err := h.Denylist.RevokeToken(c, claims.ID, claims.ExpiresAt.Time); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not consume mfa token"})
+		return
+	}
+
+	if err := h.LoginThrottle.Reset(c, user.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not process login"})
+		return
+	}
+
+	if method == "recovery_code" {
+		h.Audit.Log(user.ID, audit.ActionRecoveryCodeUsed, audit.Request(c))
+	}
+
+	h.issueTokens(c, user, req.Device, method)
+}
+
+// verifySecondFactor accepts either a current TOTP code or an unused
+// recovery code, and reports which one matched.
+func (h *Handler) verifySecondFactor(c *gin.Context, user models.User, code string) (string, error) {
+	code = strings.TrimSpace(code)
+	if len(code) == utils.TOTPDigits {
+		if err := h.checkTOTP(c, user.ID, *user.TOTPSecret, code); err != nil {
+			return "", err
+		}
+		return "totp", nil
+	}
+
+	// Recovery codes are consumed with a conditional update so that two
+	// concurrent logins can't both spend the same one
+	res := h.DB.Table("auth.recovery_codes").
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	if res.Error != nil {
+		return "", res.Error
+	}
+	if res.RowsAffected == 0 {
+		return "", errInvalidCode
+	}
+	return "recovery_code", nil
+}
+
+// checkTOTP validates a TOTP code and remembers the matched time step, so an
+// observed code can't be replayed while it is still within the window.
+func (h *Handler) checkTOTP(c *gin.Context, userID uuid.UUID, secret, code string) error {
+	step, ok := utils.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return errInvalidCode
+	}
+
+	key := fmt.Sprintf("totp_used:%s:%d", userID, step)
+	fresh, err := h.Redis.SetNX(c, key, 1, time.Duration(2*utils.TOTPSkew+1)*utils.TOTPPeriod).Result()
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return errInvalidCode
+	}
+	return nil
+}
+
+// generateRecoveryCodes returns the plaintext codes to show the user once
+// and the hashed rows to store.
+func generateRecoveryCodes(userID uuid.UUID) ([]string, []models.RecoveryCode, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	rows := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw, err := utils.GenerateRandomToken(8)
+		if err != nil {
+			return nil, nil, err
+		}
+		code := raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16]
+		codes = append(codes, code)
+		rows = append(rows, models.RecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(code)})
+	}
+	return codes, rows, nil
+}
+
+// hashRecoveryCode normalises a code (case, dashes, spaces) before hashing.
+// The codes carry 64 bits of randomness, so a fast hash is enough.
+func hashRecoveryCode(code string) string {
+	normalised := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalised))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,202 @@
+package user_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/auth"
+	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"github.com/DMaryanskiy/bookshare-api/internal/middleware"
+	"github.com/DMaryanskiy/bookshare-api/internal/task/distributor"
+	"github.com/DMaryanskiy/bookshare-api/internal/tests"
+	"github.com/DMaryanskiy/bookshare-api/internal/user"
+	"github.com/DMaryanskiy/bookshare-api/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupMFARouter(t *testing.T, tokenStore *FakeTokenStore) (*gin.Engine, *gorm.DB) {
+	gin.SetMode(gin.TestMode)
+
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	router := gin.Default()
+
+	dist := distributor.NewTaskDistributor(tests.TestRedis.Options().Addr)
+	denylist := auth.NewDenylist(tests.TestRedis)
+	h := user.NewHandler(gdb, tests.AuditLogger(gdb), dist, tokenStore, denylist, tests.TestRedis)
+
+	router.POST("/login", h.LoginUser)
+	router.POST("/login/mfa", h.LoginMFA)
+
+	mfa := router.Group("/mfa/totp")
+	mfa.Use(middleware.JWTAuthMiddleware(denylist))
+	mfa.POST("/enroll", h.EnrollTOTP)
+	mfa.POST("/confirm", h.ConfirmTOTP)
+	mfa.POST("/disable", h.DisableTOTP)
+	mfa.GET("/protected", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "ok"})
+	})
+
+	return router, gdb
+}
+
+func postAuthJSON(r *gin.Engine, path, token string, body any) *httptest.ResponseRecorder {
+	data, _ := json.Marshal(body)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, path, bytes.NewBuffer(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", tests.GetAuthHeader(token))
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// enrollTOTP enables TOTP for the user and returns the secret and recovery codes.
+func enrollTOTP(t *testing.T, r *gin.Engine, token string) (string, []string) {
+	w := postAuthJSON(r, "/mfa/totp/enroll", token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var enroll map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &enroll))
+	require.Contains(t, enroll["otpauth_url"], "otpauth://totp/")
+
+	code, err := utils.TOTPCode(enroll["secret"], time.Now())
+	require.NoError(t, err)
+
+	w = postAuthJSON(r, "/mfa/totp/confirm", token, map[string]string{"code": code})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var confirm map[string][]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &confirm))
+	require.Len(t, confirm["recovery_codes"], 10)
+
+	return enroll["secret"], confirm["recovery_codes"]
+}
+
+func loginForMFAToken(t *testing.T, r *gin.Engine, email string) string {
+	w := postJSON(r, "/login", map[string]string{"email": email, "password": "password123"})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, true, resp["mfa_required"])
+	require.NotContains(t, resp, "access_token")
+
+	return resp["mfa_token"].(string)
+}
+
+func TestTOTP_LoginFlow(t *testing.T) {
+	tokenStore := NewFakeTokenStore()
+	r, gdb := setupMFARouter(t, tokenStore)
+
+	u, token := tests.CreateTestUser(t, gdb, "totp@example.com", "password123")
+	secret, _ := enrollTOTP(t, r, token)
+
+	var stored models.User
+	require.NoError(t, gdb.Table("auth.users").First(&stored, "id = ?", u.ID).Error)
+	require.True(t, stored.TOTPEnabled)
+
+	mfaToken := loginForMFAToken(t, r, "totp@example.com")
+	require.Empty(t, tokenStore.Tokens, "no session before the second factor")
+
+	// The challenge token is not an access token
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/mfa/totp/protected", nil)
+	req.Header.Set("Authorization", tests.GetAuthHeader(mfaToken))
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = postJSON(r, "/login/mfa", map[string]string{"mfa_token": mfaToken, "code": "000000"})
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// The code used at confirmation is spent; the next period's code is accepted
+	code, err := utils.TOTPCode(secret, time.Now().Add(utils.TOTPPeriod))
+	require.NoError(t, err)
+
+	w = postJSON(r, "/login/mfa", map[string]string{"mfa_token": mfaToken, "code": code})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Contains(t, resp, "access_token")
+	require.Contains(t, resp, "refresh_token")
+
+	// Neither the code nor the challenge token can be replayed
+	second := loginForMFAToken(t, r, "totp@example.com")
+	w = postJSON(r, "/login/mfa", map[string]string{"mfa_token": second, "code": code})
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = postJSON(r, "/login/mfa", map[string]string{"mfa_token": mfaToken, "code": code})
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestTOTP_WrongCodesLockAcrossChallenges(t *testing.T) {
+	r, gdb := setupMFARouter(t, NewFakeTokenStore())
+
+	_, token := tests.CreateTestUser(t, gdb, "guesser@example.com", "password123")
+	enrollTOTP(t, r, token)
+
+	// A correct password followed by a wrong code, over and over. Each
+	// cycle gets a fresh challenge, but the failures keep adding up.
+	for i := int64(1); i < auth.LockThreshold; i++ {
+		mfaToken := loginForMFAToken(t, r, "guesser@example.com")
+		w := postJSON(r, "/login/mfa", map[string]string{"mfa_token": mfaToken, "code": "000000"})
+		require.Equal(t, http.StatusUnauthorized, w.Code, "attempt %d", i)
+
+		// Skip the backoff, as a patient attacker would
+		require.NoError(t, tests.TestRedis.Del(context.Background(), "login_blocked:guesser@example.com").Err())
+	}
+
+	mfaToken := loginForMFAToken(t, r, "guesser@example.com")
+	w := postJSON(r, "/login/mfa", map[string]string{"mfa_token": mfaToken, "code": "000000"})
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Contains(t, w.Body.String(), "locked")
+
+	// The password alone no longer gets a challenge
+	w = postJSON(r, "/login", map[string]string{"email": "guesser@example.com", "password": "password123"})
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+}
+
+func TestTOTP_RecoveryCodeIsSingleUse(t *testing.T) {
+	r, gdb := setupMFARouter(t, NewFakeTokenStore())
+
+	_, token := tests.CreateTestUser(t, gdb, "recovery@example.com", "password123")
+	_, codes := enrollTOTP(t, r, token)
+
+	mfaToken := loginForMFAToken(t, r, "recovery@example.com")
+	w := postJSON(r, "/login/mfa", map[string]string{"mfa_token": mfaToken, "code": codes[0]})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	mfaToken = loginForMFAToken(t, r, "recovery@example.com")
+	w = postJSON(r, "/login/mfa", map[string]string{"mfa_token": mfaToken, "code": codes[0]})
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestTOTP_Disable(t *testing.T) {
+	r, gdb := setupMFARouter(t, NewFakeTokenStore())
+
+	u, token := tests.CreateTestUser(t, gdb, "disable@example.com", "password123")
+	_, codes := enrollTOTP(t, r, token)
+
+	w := postAuthJSON(r, "/mfa/totp/disable", token, map[string]string{"password": "wrong", "code": codes[0]})
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = postAuthJSON(r, "/mfa/totp/disable", token, map[string]string{"password": "password123", "code": codes[0]})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var count int64
+	gdb.Table("auth.recovery_codes").Where("user_id = ?", u.ID).Count(&count)
+	require.Equal(t, int64(0), count)
+
+	// Back to single-step login
+	w = postJSON(r, "/login", map[string]string{"email": "disable@example.com", "password": "password123"})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "access_token")
+}
@@ -0,0 +1,101 @@
+package user
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Emails link to these pages. Each one is a small form that posts JSON to
+// the endpoint of the same path, so a link works without a separate
+// frontend.
+
+var resetPasswordPage = template.Must(template.New("reset").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Reset your BookShare password</title></head>
+<body>
+<h1>Reset your password</h1>
+<form id="form">
+  <label>New password <input type="password" name="password" minlength="8" required></label>
+  <button type="submit">Reset password</button>
+</form>
+<p id="result"></p>
+<script>
+document.getElementById("form").addEventListener("submit", async (e) => {
+  e.preventDefault();
+  const res = await fetch(location.pathname, {
+    method: "POST",
+    headers: {"Content-Type": "application/json"},
+    body: JSON.stringify({token: {{.Token}}, uid: {{.UID}}, password: e.target.password.value}),
+  });
+  const body = await res.json();
+  document.getElementById("result").textContent = body.message || body.error;
+});
+</script>
+</body>
+</html>
+`))
+
+var forgotPasswordPage = template.Must(template.New("forgot").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Reset your BookShare password</title></head>
+<body>
+<h1>Forgot your password?</h1>
+<form id="form">
+  <label>Email <input type="email" name="email" required></label>
+  <button type="submit">Send reset link</button>
+</form>
+<p id="result"></p>
+<script>
+document.getElementById("form").addEventListener("submit", async (e) => {
+  e.preventDefault();
+  const res = await fetch(location.pathname, {
+    method: "POST",
+    headers: {"Content-Type": "application/json"},
+    body: JSON.stringify({email: e.target.email.value}),
+  });
+  const body = await res.json();
+  document.getElementById("result").textContent = body.message || body.error;
+});
+</script>
+</body>
+</html>
+`))
+
+func renderPage(c *gin.Context, page *template.Template, data any) {
+	// Reset links carry a token in the query string; keep it out of caches
+	// and Referer headers
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := page.Execute(c.Writer, data); err != nil {
+		log.Printf("Failed to render %s page: %v", page.Name(), err)
+	}
+}
+
+// ResetPasswordPage godoc
+// @Summary      Password reset page
+// @Description  The page reset emails link to. Its form posts the new password to POST /auth/reset-password.
+// @Tags         auth
+// @Produce      html
+// @Param        token  query  string  true  "Reset token"
+// @Param        uid    query  string  true  "User ID"
+// @Success      200  {string}  string  "HTML form"
+// @Router       /auth/reset-password [get]
+func (h *Handler) ResetPasswordPage(c *gin.Context) {
+	renderPage(c, resetPasswordPage, struct{ Token, UID string }{c.Query("token"), c.Query("uid")})
+}
+
+// ForgotPasswordPage godoc
+// @Summary      Forgotten password page
+// @Description  The page account-locked emails link to. Its form posts the email to POST /auth/forgot-password.
+// @Tags         auth
+// @Produce      html
+// @Success      200  {string}  string  "HTML form"
+// @Router       /auth/forgot-password [get]
+func (h *Handler) ForgotPasswordPage(c *gin.Context) {
+	renderPage(c, forgotPasswordPage, nil)
+}
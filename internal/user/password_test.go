@@ -0,0 +1,160 @@
+package user_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
+	"github.com/DMaryanskiy/bookshare-api/internal/auth"
+	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"github.com/DMaryanskiy/bookshare-api/internal/task/distributor"
+	"github.com/DMaryanskiy/bookshare-api/internal/tests"
+	"github.com/DMaryanskiy/bookshare-api/internal/user"
+	"github.com/DMaryanskiy/bookshare-api/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupPasswordRouter(t *testing.T, tokenStore *FakeTokenStore) (*gin.Engine, *gorm.DB) {
+	gin.SetMode(gin.TestMode)
+
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	router := gin.Default()
+
+	dist := distributor.NewTaskDistributor(tests.TestRedis.Options().Addr)
+	h := user.NewHandler(gdb, tests.AuditLogger(gdb), dist, tokenStore, auth.NewDenylist(tests.TestRedis), tests.TestRedis)
+
+	router.POST("/forgot-password", h.ForgotPassword)
+	router.POST("/reset-password", h.ResetPassword)
+
+	return router, gdb
+}
+
+func postJSON(r *gin.Engine, path string, body any) *httptest.ResponseRecorder {
+	data, _ := json.Marshal(body)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, path, bytes.NewBuffer(data))
+	req.Header.Set("Content-Type", "application/json")
+
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestForgotPassword_SameResponseForUnknownEmail(t *testing.T) {
+	r, gdb := setupPasswordRouter(t, NewFakeTokenStore())
+
+	_, _ = tests.CreateTestUser(t, gdb, "known@example.com", "password123")
+
+	known := postJSON(r, "/forgot-password", map[string]string{"email": "known@example.com"})
+	unknown := postJSON(r, "/forgot-password", map[string]string{"email": "unknown@example.com"})
+
+	require.Equal(t, http.StatusOK, known.Code)
+	require.Equal(t, http.StatusOK, unknown.Code)
+	require.Equal(t, known.Body.String(), unknown.Body.String())
+}
+
+func TestForgotPassword_Cooldown(t *testing.T) {
+	r, gdb := setupPasswordRouter(t, NewFakeTokenStore())
+
+	u, _ := tests.CreateTestUser(t, gdb, "flooded@example.com", "password123")
+
+	first := postJSON(r, "/forgot-password", map[string]string{"email": "flooded@example.com"})
+	second := postJSON(r, "/forgot-password", map[string]string{"email": "Flooded@example.com"})
+
+	require.Equal(t, http.StatusOK, first.Code)
+	require.Equal(t, http.StatusOK, second.Code)
+	require.Equal(t, first.Body.String(), second.Body.String())
+
+	// Only the first request sent an email
+	var count int64
+	require.NoError(t, gdb.Table("logs.audit_logs").
+		Where("user_id = ? AND action = ?", u.ID, audit.ActionPasswordResetRequested).
+		Count(&count).Error)
+	require.Equal(t, int64(1), count)
+}
+
+func TestResetPassword_Success(t *testing.T) {
+	tokenStore := NewFakeTokenStore()
+	r, gdb := setupPasswordRouter(t, tokenStore)
+
+	u, _ := tests.CreateTestUser(t, gdb, "reset@example.com", "oldpassword")
+	require.NoError(t, gdb.Table("auth.verification_tokens").Create(&models.VerificationToken{
+		UserID:    u.ID,
+		Token:     "reset-token",
+		Purpose:   models.TokenPurposePasswordReset,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}).Error)
+
+	_, err := tokenStore.CreateRefreshToken(context.TODO(), u.ID.String(), auth.SessionMeta{}, time.Hour)
+	require.NoError(t, err)
+
+	w := postJSON(r, "/reset-password", map[string]string{
+		"token":    "reset-token",
+		"uid":      u.ID.String(),
+		"password": "newpassword",
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var updated models.User
+	require.NoError(t, gdb.Table("auth.users").First(&updated, "id = ?", u.ID).Error)
+	ok, _ := utils.CheckPasswordHash(updated.PasswordHash, "newpassword")
+	require.True(t, ok)
+
+	// All sessions are revoked
+	require.Empty(t, tokenStore.Tokens)
+
+	// The token is single-use
+	w = postJSON(r, "/reset-password", map[string]string{
+		"token":    "reset-token",
+		"uid":      u.ID.String(),
+		"password": "anotherpassword",
+	})
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestResetPassword_RejectsVerificationToken(t *testing.T) {
+	r, gdb := setupPasswordRouter(t, NewFakeTokenStore())
+
+	u, _ := tests.CreateTestUser(t, gdb, "wrongpurpose@example.com", "oldpassword")
+	require.NoError(t, gdb.Table("auth.verification_tokens").Create(&models.VerificationToken{
+		UserID:    u.ID,
+		Token:     "verify-token",
+		Purpose:   models.TokenPurposeEmailVerification,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}).Error)
+
+	w := postJSON(r, "/reset-password", map[string]string{
+		"token":    "verify-token",
+		"uid":      u.ID.String(),
+		"password": "newpassword",
+	})
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestResetPassword_ExpiredToken(t *testing.T) {
+	r, gdb := setupPasswordRouter(t, NewFakeTokenStore())
+
+	u, _ := tests.CreateTestUser(t, gdb, "expiredreset@example.com", "oldpassword")
+	require.NoError(t, gdb.Table("auth.verification_tokens").Create(&models.VerificationToken{
+		UserID:    u.ID,
+		Token:     "expired-reset-token",
+		Purpose:   models.TokenPurposePasswordReset,
+		ExpiresAt: time.Now().Add(-time.Minute),
+	}).Error)
+
+	w := postJSON(r, "/reset-password", map[string]string{
+		"token":    "expired-reset-token",
+		"uid":      u.ID.String(),
+		"password": "newpassword",
+	})
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -1,11 +1,16 @@
 package user
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
+	"github.com/DMaryanskiy/bookshare-api/internal/auth"
+	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
 	"github.com/DMaryanskiy/bookshare-api/pkg/utils"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type RefreshRequest struct {
@@ -14,15 +19,16 @@ type RefreshRequest struct {
 
 // RefreshToken godoc
 // @Summary      Refresh access token
-// @Description  Validates refresh token and returns a new access and refresh token
+// @Description  Validates refresh token and returns a new access and refresh token. Presenting an already rotated token revokes its session.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        refresh_token  body  RefreshRequest  true  "Refresh token to validate"
 // @Success      200  {object}  map[string]string  "New access and refresh tokens"
 // @Failure      400  {object}  map[string]string  "Missing refresh token"
-// @Failure      401  {object}  map[string]string  "Invalid refresh token"
-// @Failure      500  {object}  map[string]string  "Server error creating or deleting tokens"
+// @Failure      401  {object}  map[string]string  "Invalid or reused refresh token"
+// @Failure      403  {object}  map[string]string  "Account suspended"
+// @Failure      500  {object}  map[string]string  "Server error rotating tokens"
 // @Router       /auth/refresh [post]
 func (h *Handler) RefreshToken(c *gin.Context) {
 	var req RefreshRequest
@@ -31,29 +37,53 @@ func (h *Handler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	userID, err := h.TokenStore.VerifyRefreshToken(c, req.RefreshToken)
-	if err != nil {
+	newRefresh, userID, err := h.TokenStore.RotateRefreshToken(c, req.RefreshToken, sessionMeta(c, ""), 24*time.Hour)
+
+	var reuse *auth.ReuseError
+	if errors.As(err, &reuse) {
+		// The token was stolen or replayed; its session is already revoked
+		h.Audit.Log(auditUserID(reuse.UserID), audit.ActionRefreshTokenReuseDetected, audit.Request(c).
+			Failure("refresh_token_reused").
+			With("session_id", reuse.SessionID))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token reuse detected, please log in again"})
+		return
+	}
+	if errors.Is(err, auth.ErrInvalidRefreshToken) {
+		h.Audit.Log(uuid.Nil, audit.ActionTokenRefreshFailed, audit.Request(c).Failure("invalid_refresh_token"))
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
 		return
 	}
-
-	err = h.TokenStore.DeleteRefreshToken(c, req.RefreshToken)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete old refresh token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not rotate refresh token"})
 		return
 	}
-	newRefresh, err := h.TokenStore.CreateRefreshToken(c, userID, 24*time.Hour)
-	if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create refresh token"})
-        return
-    }
 
-	accessToken, err := utils.GenerateAccessToken(userID, 15*time.Minute)
+	// The role is read afresh so that a role change takes effect with the
+	// next access token. Suspension and deletion end every session, so the
+	// checks below only catch a refresh racing them.
+	var account models.User
+	if err := h.DB.Table("auth.users").Select("id", "role", "suspended_at").
+		First(&account, "id = ?", userID).Error; err != nil {
+		_ = h.TokenStore.DeleteUserRefreshTokens(c, userID)
+		h.Audit.Log(uuid.Nil, audit.ActionTokenRefreshFailed, audit.Request(c).Failure("unknown_user"))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+		return
+	}
+	if account.SuspendedAt != nil {
+		_ = h.TokenStore.DeleteUserRefreshTokens(c, userID)
+		h.Audit.Log(account.ID, audit.ActionTokenRefreshFailed, audit.Request(c).Failure("suspended"))
+		c.JSON(http.StatusForbidden, gin.H{"error": "account suspended"})
+		return
+	}
+
+	accessToken, err := utils.GenerateAccessToken(userID, account.Role, 15*time.Minute)
 	if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create access token"})
         return
     }
 
+	h.Audit.Log(account.ID, audit.ActionTokenRefreshed, audit.Request(c))
+
 	c.JSON(http.StatusOK, gin.H{
 		"access_token":  accessToken,
 		"refresh_token": newRefresh,
@@ -6,11 +6,11 @@ import (
 	"net/http"
 
 	"github.com/DMaryanskiy/bookshare-api/internal/audit"
-	"github.com/DMaryanskiy/bookshare-api/internal/db"
 	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
 	"github.com/DMaryanskiy/bookshare-api/internal/task"
 	"github.com/DMaryanskiy/bookshare-api/pkg/utils"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type RegisterUserRequest struct {
@@ -48,7 +48,10 @@ func (h *Handler) RegisterUser(c *gin.Context) {
 		Email:        req.Email,
 		PasswordHash: hashedPassword,
 	}
-	if err := db.DB.Table("auth.users").Create(&user).Error; err != nil {
+	if err := h.DB.Table("auth.users").Create(&user).Error; err != nil {
+		h.Audit.Log(uuid.Nil, audit.ActionRegistrationFailed, audit.Request(c).
+			Failure("email_taken").
+			With("email", req.Email))
 		c.JSON(http.StatusConflict, gin.H{"error": "email already exists"})
 		return
 	}
@@ -63,10 +66,7 @@ func (h *Handler) RegisterUser(c *gin.Context) {
 		return
 	}
 
-	audit.Log(user.ID, "registration_success", map[string]string{
-		"ip":         c.ClientIP(),
-		"user_agent": c.GetHeader("User-Agent"),
-	})
+	h.Audit.Log(user.ID, audit.ActionRegistrationSuccess, audit.Request(c))
 
 	c.JSON(http.StatusCreated, gin.H{"message": "registration successful, verification email sent"})
 }
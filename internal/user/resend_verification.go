@@ -0,0 +1,91 @@
+package user
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
+	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"github.com/DMaryanskiy/bookshare-api/internal/task"
+	"github.com/gin-gonic/gin"
+)
+
+const resendVerificationCooldown = 2 * time.Minute
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResendVerification godoc
+// @Summary      Resend verification email
+// @Description  Issues a new verification link for an unverified account and invalidates older ones. The response does not reveal whether the email is registered.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        email  body  ResendVerificationRequest  true  "Account email"
+// @Success      200  {object}  map[string]string  "Verification email sent if the account exists and is unverified"
+// @Failure      400  {object}  map[string]string  "Invalid input"
+// @Failure      429  {object}  map[string]string  "Cooldown active for this email"
+// @Failure      500  {object}  map[string]string  "Server error"
+// @Router       /auth/verify/resend [post]
+func (h *Handler) ResendVerification(c *gin.Context) {
+	var req ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "valid email required"})
+		return
+	}
+
+	// The cooldown applies to every address, registered or not, so hitting
+	// it says nothing about whether an account exists
+	key := "verify_resend:" + strings.ToLower(req.Email)
+	ok, err := h.Redis.SetNX(c, key, 1, resendVerificationCooldown).Result()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not process request"})
+		return
+	}
+	if !ok {
+		retryAfter := resendVerificationCooldown
+		if ttl, err := h.Redis.TTL(c, key).Result(); err == nil && ttl > 0 {
+			retryAfter = ttl
+		}
+		c.Header("Retry-After", fmt.Sprintf("%d", int(retryAfter.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "please wait before requesting another verification email"})
+		return
+	}
+
+	resp := gin.H{"message": "if the account exists and is unverified, a new verification email has been sent"}
+
+	var user models.User
+	if err := h.DB.Table("auth.users").
+		Where("email = ?", req.Email).
+		First(&user).Error; err != nil || user.IsVerified {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	if err := h.DB.Table("auth.verification_tokens").
+		Where("user_id = ? AND purpose = ?", user.ID, models.TokenPurposeEmailVerification).
+		Delete(&models.VerificationToken{}).Error; err != nil {
+		// A 500 here would only ever be seen for unverified accounts
+		log.Printf("Failed to delete old verification tokens for %s: %v", user.ID, err)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	payload := task.PayloadSendVerificationEmail{
+		UserId: user.ID.String(),
+		Email:  user.Email,
+	}
+	if err := h.TaskDistributor.DistributeVerificationEmail(c, payload); err != nil {
+		log.Printf("Failed to enqueue verification email for %s: %v", user.ID, err)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	h.Audit.Log(user.ID, audit.ActionVerificationResent, audit.Request(c))
+
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,100 @@
+package user
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
+	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
+	"github.com/DMaryanskiy/bookshare-api/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	UID      string `json:"uid" binding:"required,uuid"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+// ResetPassword godoc
+// @Summary      Reset password
+// @Description  Sets a new password using a reset token and signs the user out of every session
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        reset  body  ResetPasswordRequest  true  "Reset token, user ID and new password"
+// @Success      200  {object}  map[string]string  "Password reset"
+// @Failure      400  {object}  map[string]string  "Invalid input or invalid/expired token"
+// @Failure      500  {object}  map[string]string  "Server error"
+// @Router       /auth/reset-password [post]
+func (h *Handler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid reset input"})
+		return
+	}
+
+	var token models.VerificationToken
+	if err := h.DB.Table("auth.verification_tokens").
+		Where("token = ? AND user_id = ? AND purpose = ?", req.Token, req.UID, models.TokenPurposePasswordReset).
+		First(&token).Error; err != nil {
+		h.Audit.Log(auditUserID(req.UID), audit.ActionPasswordResetFailed, audit.Request(c).Failure("invalid_token"))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired reset link"})
+		return
+	}
+
+	if time.Now().After(token.ExpiresAt) {
+		h.DB.Table("auth.verification_tokens").Delete(&token)
+		h.Audit.Log(token.UserID, audit.ActionPasswordResetFailed, audit.Request(c).Failure("expired_token"))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reset link expired"})
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not hash password"})
+		return
+	}
+
+	// Consuming the token and changing the password must happen together,
+	// otherwise a failed update could leave a spent link or a reusable one
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Table("auth.verification_tokens").
+			Where("user_id = ? AND purpose = ?", token.UserID, models.TokenPurposePasswordReset).
+			Delete(&models.VerificationToken{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			// Another request consumed the token first
+			return gorm.ErrRecordNotFound
+		}
+
+		return tx.Table("auth.users").
+			Where("id = ?", token.UserID).
+			Update("password_hash", hashedPassword).Error
+	})
+	if err == gorm.ErrRecordNotFound {
+		h.Audit.Log(token.UserID, audit.ActionPasswordResetFailed, audit.Request(c).Failure("token_already_used"))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired reset link"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not reset password"})
+		return
+	}
+
+	if err := h.TokenStore.DeleteUserRefreshTokens(c, token.UserID.String()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "password reset but sessions could not be revoked"})
+		return
+	}
+	if err := h.Denylist.RevokeUserTokens(c, token.UserID.String()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "password reset but access tokens could not be revoked"})
+		return
+	}
+
+	h.Audit.Log(token.UserID, audit.ActionPasswordReset, audit.Request(c))
+
+	c.JSON(http.StatusOK, gin.H{"message": "password reset successfully"})
+}
@@ -0,0 +1,84 @@
+package user_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/auth"
+	"github.com/DMaryanskiy/bookshare-api/internal/middleware"
+	"github.com/DMaryanskiy/bookshare-api/internal/task/distributor"
+	"github.com/DMaryanskiy/bookshare-api/internal/tests"
+	"github.com/DMaryanskiy/bookshare-api/internal/user"
+	"github.com/DMaryanskiy/bookshare-api/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponses_NoSensitiveFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	router := gin.Default()
+
+	dist := distributor.NewTaskDistributor(tests.TestRedis.Options().Addr)
+	denylist := auth.NewDenylist(tests.TestRedis)
+	h := user.NewHandler(gdb, tests.AuditLogger(gdb), dist, NewFakeTokenStore(), denylist, tests.TestRedis)
+
+	router.POST("/register", h.RegisterUser)
+	router.POST("/login", h.LoginUser)
+	router.POST("/login/mfa", h.LoginMFA)
+	router.POST("/refresh", h.RefreshToken)
+	router.POST("/forgot-password", h.ForgotPassword)
+
+	authed := router.Group("")
+	authed.Use(middleware.JWTAuthMiddleware(denylist))
+	authed.GET("/me", h.GetMe)
+	authed.GET("/sessions", h.ListSessions)
+	authed.POST("/mfa/totp/enroll", h.EnrollTOTP)
+	authed.POST("/mfa/totp/confirm", h.ConfirmTOTP)
+
+	check := func(w *httptest.ResponseRecorder, status int) {
+		t.Helper()
+		require.Equal(t, status, w.Code, w.Body.String())
+		tests.RequireNoSensitiveFields(t, w.Body.Bytes())
+	}
+
+	check(postJSON(router, "/register", map[string]string{"email": "new@example.com", "password": "password123"}), http.StatusCreated)
+	check(postJSON(router, "/forgot-password", map[string]string{"email": "new@example.com"}), http.StatusOK)
+
+	_, token := tests.CreateTestUser(t, gdb, "private@example.com", "password123")
+
+	w := postJSON(router, "/login", map[string]string{"email": "private@example.com", "password": "password123"})
+	check(w, http.StatusOK)
+	var tokens map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+
+	check(postJSON(router, "/refresh", map[string]string{"refresh_token": tokens["refresh_token"]}), http.StatusOK)
+
+	for _, path := range []string{"/me", "/sessions"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", tests.GetAuthHeader(token))
+		router.ServeHTTP(w, req)
+		check(w, http.StatusOK)
+	}
+
+	// Enrollment hands out the new secret once by design; stored credentials
+	// are what must never come back
+	w = postAuthJSON(router, "/mfa/totp/enroll", token, nil)
+	check(w, http.StatusOK)
+	var enroll map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &enroll))
+
+	code, err := utils.TOTPCode(enroll["secret"], time.Now())
+	require.NoError(t, err)
+	check(postAuthJSON(router, "/mfa/totp/confirm", token, map[string]string{"code": code}), http.StatusOK)
+
+	w = postJSON(router, "/login", map[string]string{"email": "private@example.com", "password": "password123"})
+	check(w, http.StatusOK)
+}
@@ -0,0 +1,95 @@
+package user
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
+	"github.com/DMaryanskiy/bookshare-api/internal/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// sessionMeta captures the client details stored with a session. Clients may
+// name the device; otherwise the user agent is all there is to go on.
+func sessionMeta(c *gin.Context, device string) auth.SessionMeta {
+	return auth.SessionMeta{
+		Device:    device,
+		IP:        c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+	}
+}
+
+// ListSessions godoc
+// @Summary      List sessions
+// @Description  Returns the authenticated user's active sessions, most recently used first
+// @Tags         sessions
+// @Produce      json
+// @Success      200  {array}   auth.Session  "Active sessions"
+// @Failure      500  {object}  map[string]string  "Could not list sessions"
+// @Router       /sessions [get]
+func (h *Handler) ListSessions(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	sessions, err := h.TokenStore.ListSessions(c, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not list sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession godoc
+// @Summary      Revoke a session
+// @Description  Signs out one of the authenticated user's sessions
+// @Tags         sessions
+// @Produce      json
+// @Param        id   path      string  true  "Session ID"
+// @Success      200  {object}  map[string]string  "Session revoked"
+// @Failure      404  {object}  map[string]string  "Session not found"
+// @Failure      500  {object}  map[string]string  "Could not revoke session"
+// @Router       /sessions/{id} [delete]
+func (h *Handler) RevokeSession(c *gin.Context) {
+	userID := c.GetString("user_id")
+	sessionID := c.Param("id")
+
+	err := h.TokenStore.DeleteSession(c, userID, sessionID)
+	if errors.Is(err, auth.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not revoke session"})
+		return
+	}
+
+	h.Audit.Log(uuid.MustParse(userID), audit.ActionSessionRevoked, audit.Request(c).With("session_id", sessionID))
+
+	c.JSON(http.StatusOK, gin.H{"message": "session revoked"})
+}
+
+// RevokeAllSessions godoc
+// @Summary      Log out everywhere
+// @Description  Revokes every session and access token of the authenticated user, including the current ones
+// @Tags         sessions
+// @Produce      json
+// @Success      200  {object}  map[string]string  "All sessions revoked"
+// @Failure      500  {object}  map[string]string  "Could not revoke sessions"
+// @Router       /sessions [delete]
+func (h *Handler) RevokeAllSessions(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	if err := h.TokenStore.DeleteUserRefreshTokens(c, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not revoke sessions"})
+		return
+	}
+	if err := h.Denylist.RevokeUserTokens(c, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not revoke access tokens"})
+		return
+	}
+
+	h.Audit.Log(uuid.MustParse(userID), audit.ActionSessionsRevoked, audit.Request(c))
+
+	c.JSON(http.StatusOK, gin.H{"message": "logged out everywhere"})
+}
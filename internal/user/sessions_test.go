@@ -0,0 +1,99 @@
+package user_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/internal/auth"
+	"github.com/DMaryanskiy/bookshare-api/internal/middleware"
+	"github.com/DMaryanskiy/bookshare-api/internal/task/distributor"
+	"github.com/DMaryanskiy/bookshare-api/internal/tests"
+	"github.com/DMaryanskiy/bookshare-api/internal/user"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupSessionRouter(t *testing.T, tokenStore *FakeTokenStore) (*gin.Engine, *gorm.DB) {
+	gin.SetMode(gin.TestMode)
+
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	router := gin.Default()
+
+	dist := distributor.NewTaskDistributor(tests.TestRedis.Options().Addr)
+	denylist := auth.NewDenylist(tests.TestRedis)
+	h := user.NewHandler(gdb, tests.AuditLogger(gdb), dist, tokenStore, denylist, tests.TestRedis)
+
+	sessions := router.Group("/sessions")
+	sessions.Use(middleware.JWTAuthMiddleware(denylist))
+	sessions.GET("", h.ListSessions)
+	sessions.DELETE("", h.RevokeAllSessions)
+	sessions.DELETE("/:id", h.RevokeSession)
+
+	return router, gdb
+}
+
+func TestSessions_ListAndRevoke(t *testing.T) {
+	tokenStore := NewFakeTokenStore()
+	r, gdb := setupSessionRouter(t, tokenStore)
+
+	u, token := tests.CreateTestUser(t, gdb, "sessions@example.com", "password123")
+	other, _ := tests.CreateTestUser(t, gdb, "othersessions@example.com", "password123")
+
+	_, err := tokenStore.CreateRefreshToken(context.TODO(), u.ID.String(), auth.SessionMeta{Device: "phone"}, time.Hour)
+	require.NoError(t, err)
+	_, err = tokenStore.CreateRefreshToken(context.TODO(), u.ID.String(), auth.SessionMeta{Device: "laptop"}, time.Hour)
+	require.NoError(t, err)
+	_, err = tokenStore.CreateRefreshToken(context.TODO(), other.ID.String(), auth.SessionMeta{}, time.Hour)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/sessions", nil)
+	req.Header.Set("Authorization", tests.GetAuthHeader(token))
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var sessions []auth.Session
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sessions))
+	require.Len(t, sessions, 2)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/sessions/"+sessions[0].ID, nil)
+	req.Header.Set("Authorization", tests.GetAuthHeader(token))
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	remaining, _ := tokenStore.ListSessions(context.TODO(), u.ID.String())
+	require.Len(t, remaining, 1)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/sessions", nil)
+	req.Header.Set("Authorization", tests.GetAuthHeader(token))
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	remaining, _ = tokenStore.ListSessions(context.TODO(), u.ID.String())
+	require.Empty(t, remaining)
+
+	// Other users' sessions are untouched
+	others, _ := tokenStore.ListSessions(context.TODO(), other.ID.String())
+	require.Len(t, others, 1)
+}
+
+func TestSessions_RevokeUnknown(t *testing.T) {
+	r, gdb := setupSessionRouter(t, NewFakeTokenStore())
+
+	_, token := tests.CreateTestUser(t, gdb, "nosession@example.com", "password123")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/sessions/does-not-exist", nil)
+	req.Header.Set("Authorization", tests.GetAuthHeader(token))
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -9,47 +9,128 @@ import (
 	"testing"
 	"time"
 
-	"github.com/DMaryanskiy/bookshare-api/internal/db"
+	"github.com/DMaryanskiy/bookshare-api/internal/auth"
 	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
 	"github.com/DMaryanskiy/bookshare-api/internal/task/distributor"
 	"github.com/DMaryanskiy/bookshare-api/internal/tests"
 	"github.com/DMaryanskiy/bookshare-api/internal/user"
 	"github.com/gin-gonic/gin"
-	"github.com/redis/go-redis/v9"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 )
 
 type FakeTokenStore struct {
-	Tokens map[string]string
+	Tokens   map[string]string // token -> user ID
+	Sessions map[string]*auth.Session
+	sessions map[string]string // token -> session ID
+	rotated  map[string]string // rotated token -> session ID
 }
 
 func NewFakeTokenStore() *FakeTokenStore {
-	return &FakeTokenStore{Tokens: make(map[string]string)}
+	return &FakeTokenStore{
+		Tokens:   make(map[string]string),
+		Sessions: make(map[string]*auth.Session),
+		sessions: make(map[string]string),
+		rotated:  make(map[string]string),
+	}
 }
 
-func (f *FakeTokenStore) CreateRefreshToken(_ context.Context, userID string, ttl time.Duration) (string, error) {
-	token := "fake-" + userID
+func (f *FakeTokenStore) CreateRefreshToken(_ context.Context, userID string, meta auth.SessionMeta, ttl time.Duration) (string, error) {
+	token := "fake-" + uuid.NewString()
+	session := &auth.Session{
+		ID:         uuid.NewString(),
+		UserID:     userID,
+		Device:     meta.Device,
+		IP:         meta.IP,
+		UserAgent:  meta.UserAgent,
+		CreatedAt:  time.Now(),
+		LastUsedAt: time.Now(),
+	}
 	f.Tokens[token] = userID
+	f.Sessions[session.ID] = session
+	f.sessions[token] = session.ID
 	return token, nil
 }
 
 func (f *FakeTokenStore) VerifyRefreshToken(_ context.Context, token string) (string, error) {
 	userID, ok := f.Tokens[token]
 	if !ok {
-		return "", redis.Nil
+		return "", auth.ErrInvalidRefreshToken
 	}
 	return userID, nil
 }
 
+func (f *FakeTokenStore) RotateRefreshToken(_ context.Context, token string, meta auth.SessionMeta, ttl time.Duration) (string, string, error) {
+	userID, ok := f.Tokens[token]
+	if !ok {
+		if sessionID, reused := f.rotated[token]; reused {
+			reuse := &auth.ReuseError{SessionID: sessionID}
+			if session, ok := f.Sessions[sessionID]; ok {
+				reuse.UserID = session.UserID
+				_ = f.DeleteSession(context.TODO(), session.UserID, sessionID)
+			}
+			return "", "", reuse
+		}
+		return "", "", auth.ErrInvalidRefreshToken
+	}
+	sessionID := f.sessions[token]
+	delete(f.Tokens, token)
+	delete(f.sessions, token)
+	f.rotated[token] = sessionID
+
+	newToken := "fake-" + uuid.NewString()
+	f.Tokens[newToken] = userID
+	f.sessions[newToken] = sessionID
+	f.Sessions[sessionID].LastUsedAt = time.Now()
+	return newToken, userID, nil
+}
+
 func (f *FakeTokenStore) DeleteRefreshToken(_ context.Context, token string) error {
+	if sessionID, ok := f.sessions[token]; ok {
+		delete(f.Sessions, sessionID)
+	}
 	delete(f.Tokens, token)
+	delete(f.sessions, token)
+	return nil
+}
+
+func (f *FakeTokenStore) DeleteUserRefreshTokens(_ context.Context, userID string) error {
+	for token, owner := range f.Tokens {
+		if owner == userID {
+			_ = f.DeleteRefreshToken(context.TODO(), token)
+		}
+	}
 	return nil
 }
 
-func setupRouter(t *testing.T) *gin.Engine {
+func (f *FakeTokenStore) ListSessions(_ context.Context, userID string) ([]auth.Session, error) {
+	var sessions []auth.Session
+	for _, s := range f.Sessions {
+		if s.UserID == userID {
+			sessions = append(sessions, *s)
+		}
+	}
+	return sessions, nil
+}
+
+func (f *FakeTokenStore) DeleteSession(_ context.Context, userID, sessionID string) error {
+	s, ok := f.Sessions[sessionID]
+	if !ok || s.UserID != userID {
+		return auth.ErrSessionNotFound
+	}
+	for token, id := range f.sessions {
+		if id == sessionID {
+			return f.DeleteRefreshToken(context.TODO(), token)
+		}
+	}
+	return nil
+}
+
+func setupRouter(t *testing.T) (*gin.Engine, *gorm.DB) {
 	gin.SetMode(gin.TestMode)
 
-	tests.SetupTestDB(t)
+	gdb := tests.SetupTestDB(t)
 	tests.SetupTestRedis()
 
 	router := gin.Default()
@@ -57,15 +138,16 @@ func setupRouter(t *testing.T) *gin.Engine {
 	dist := distributor.NewTaskDistributor(tests.TestRedis.Options().Addr)
 	tokenStore := &FakeTokenStore{} // can use mock for now
 
-	h := user.NewHandler(dist, tokenStore)
+	h := user.NewHandler(gdb, tests.AuditLogger(gdb), dist, tokenStore, auth.NewDenylist(tests.TestRedis), tests.TestRedis)
 	router.POST("/register", h.RegisterUser)
 	router.GET("/verify", h.VerifyEmail)
+	router.POST("/verify/resend", h.ResendVerification)
 
-	return router
+	return router, gdb
 }
 
 func TestRegisterUser_Success(t *testing.T) {
-	r := setupRouter(t)
+	r, gdb := setupRouter(t)
 
 	body := map[string]string{
 		"email":    "test@example.com",
@@ -82,16 +164,16 @@ func TestRegisterUser_Success(t *testing.T) {
 	require.Equal(t, http.StatusCreated, w.Code)
 
 	var user models.User
-	err := db.DB.Table("auth.users").Where("email = ?", "test@example.com").First(&user).Error
+	err := gdb.Table("auth.users").Where("email = ?", "test@example.com").First(&user).Error
 	require.NoError(t, err)
 	require.False(t, user.IsVerified)
 }
 
 func TestRegisterUser_DuplicateEmail(t *testing.T) {
-	r := setupRouter(t)
+	r, gdb := setupRouter(t)
 
 	email := "dupe@example.com"
-	_ = db.DB.Table("auth.users").Create(&models.User{
+	_ = gdb.Table("auth.users").Create(&models.User{
 		Email:        email,
 		PasswordHash: "somehash",
 	}).Error
@@ -111,7 +193,7 @@ func TestRegisterUser_DuplicateEmail(t *testing.T) {
 }
 
 func TestRegisterUser_InvalidInput(t *testing.T) {
-	r := setupRouter(t)
+	r, _ := setupRouter(t)
 
 	body := map[string]string{
 		"password": "12345678",
@@ -127,18 +209,18 @@ func TestRegisterUser_InvalidInput(t *testing.T) {
 }
 
 func TestVerifyEmail_Success(t *testing.T) {
-	r := setupRouter(t)
+	r, gdb := setupRouter(t)
 
 	user := models.User{
 		Email:        "verify@example.com",
 		PasswordHash: "somehash",
 	}
-	require.NoError(t, db.DB.Table("auth.users").Create(&user).Error)
+	require.NoError(t, gdb.Table("auth.users").Create(&user).Error)
 
 	token := "valid-token"
 	exp := time.Now().Add(10 * time.Minute)
 
-	db.DB.Table("auth.verification_tokens").Create(&models.VerificationToken{
+	gdb.Table("auth.verification_tokens").Create(&models.VerificationToken{
 		UserID:    user.ID,
 		Token:     token,
 		ExpiresAt: exp,
@@ -151,18 +233,18 @@ func TestVerifyEmail_Success(t *testing.T) {
 	require.Equal(t, http.StatusOK, w.Code)
 
 	var updated models.User
-	_ = db.DB.Table("auth.users").First(&updated, "id = ?", user.ID)
+	_ = gdb.Table("auth.users").First(&updated, "id = ?", user.ID)
 	require.True(t, updated.IsVerified)
 }
 
 func TestVerifyEmail_InvalidToken(t *testing.T) {
-	r := setupRouter(t)
+	r, gdb := setupRouter(t)
 
 	user := models.User{
 		Email:        "badtoken@example.com",
 		PasswordHash: "somehash",
 	}
-	require.NoError(t, db.DB.Table("auth.users").Create(&user).Error)
+	require.NoError(t, gdb.Table("auth.users").Create(&user).Error)
 
 	req, _ := http.NewRequest("GET", "/verify?token=invalid-token&uid="+user.ID.String(), nil)
 	w := httptest.NewRecorder()
@@ -172,18 +254,18 @@ func TestVerifyEmail_InvalidToken(t *testing.T) {
 }
 
 func TestVerifyEmail_ExpiredToken(t *testing.T) {
-	r := setupRouter(t)
+	r, gdb := setupRouter(t)
 
 	user := models.User{
 		Email:        "expired@example.com",
 		PasswordHash: "somehash",
 	}
-	require.NoError(t, db.DB.Table("auth.users").Create(&user).Error)
+	require.NoError(t, gdb.Table("auth.users").Create(&user).Error)
 
 	token := "expired-token"
 	exp := time.Now().Add(-10 * time.Minute) // expired
 
-	db.DB.Table("auth.verification_tokens").Create(&models.VerificationToken{
+	gdb.Table("auth.verification_tokens").Create(&models.VerificationToken{
 		UserID:    user.ID,
 		Token:     token,
 		ExpiresAt: exp,
@@ -195,3 +277,76 @@ func TestVerifyEmail_ExpiredToken(t *testing.T) {
 
 	require.Equal(t, http.StatusBadRequest, w.Code)
 }
+
+func TestResendVerification_InvalidatesOldTokens(t *testing.T) {
+	r, gdb := setupRouter(t)
+
+	user := models.User{
+		Email:        "resend@example.com",
+		PasswordHash: "somehash",
+	}
+	require.NoError(t, gdb.Table("auth.users").Create(&user).Error)
+	require.NoError(t, gdb.Table("auth.verification_tokens").Create(&models.VerificationToken{
+		UserID:    user.ID,
+		Token:     "stale-token",
+		ExpiresAt: time.Now().Add(-time.Minute),
+	}).Error)
+
+	data, _ := json.Marshal(map[string]string{"email": "resend@example.com"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/verify/resend", bytes.NewBuffer(data))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var count int64
+	gdb.Table("auth.verification_tokens").Where("user_id = ?", user.ID).Count(&count)
+	require.Equal(t, int64(0), count)
+}
+
+func TestResendVerification_Cooldown(t *testing.T) {
+	r, _ := setupRouter(t)
+
+	// Unknown emails hit the same cooldown as registered ones
+	for _, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		data, _ := json.Marshal(map[string]string{"email": "nobody@example.com"})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/verify/resend", bytes.NewBuffer(data))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+
+		require.Equal(t, want, w.Code)
+	}
+}
+
+func TestResendVerification_QueueFailureLooksLikeSuccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	gdb := tests.SetupTestDB(t)
+	tests.SetupTestRedis()
+
+	// Nobody listens here, so enqueueing the email fails
+	dist := distributor.NewTaskDistributor("127.0.0.1:1")
+	h := user.NewHandler(gdb, tests.AuditLogger(gdb), dist, &FakeTokenStore{}, auth.NewDenylist(tests.TestRedis), tests.TestRedis)
+	r := gin.New()
+	r.POST("/verify/resend", h.ResendVerification)
+
+	require.NoError(t, gdb.Table("auth.users").Create(&models.User{
+		Email:        "unverified@example.com",
+		PasswordHash: "somehash",
+	}).Error)
+
+	var bodies []string
+	for _, email := range []string{"unverified@example.com", "unknown@example.com"} {
+		data, _ := json.Marshal(map[string]string{"email": email})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/verify/resend", bytes.NewBuffer(data))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code, email)
+		bodies = append(bodies, w.Body.String())
+	}
+	require.Equal(t, bodies[0], bodies[1])
+}
@@ -4,7 +4,7 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/DMaryanskiy/bookshare-api/internal/db"
+	"github.com/DMaryanskiy/bookshare-api/internal/audit"
 	"github.com/DMaryanskiy/bookshare-api/internal/db/models"
 	"github.com/gin-gonic/gin"
 )
@@ -34,21 +34,23 @@ func (h *Handler) VerifyEmail(c *gin.Context) {
 	}
 
 	var token models.VerificationToken
-	if err := db.DB.
+	if err := h.DB.
 		Table("auth.verification_tokens").
-		Where("token = ? AND user_id = ?", req.Token, req.UID).
+		Where("token = ? AND user_id = ? AND purpose = ?", req.Token, req.UID, models.TokenPurposeEmailVerification).
 		First(&token).Error; err != nil {
+		h.Audit.Log(auditUserID(req.UID), audit.ActionEmailVerificationFailed, audit.Request(c).Failure("invalid_token"))
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification link"})
         return
 	}
 
 	if time.Now().After(token.ExpiresAt) {
-		db.DB.Table("auth.verification_tokens").Delete(&token)
+		h.DB.Table("auth.verification_tokens").Delete(&token)
+		h.Audit.Log(token.UserID, audit.ActionEmailVerificationFailed, audit.Request(c).Failure("expired_token"))
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Verification link expired"})
         return
 	}
 
-	if err := db.DB.Table("auth.users").
+	if err := h.DB.Table("auth.users").
 		Model(&models.User{}).
 		Where("id = ?", req.UID).
 		Update("is_verified", true).Error; err != nil {
@@ -56,7 +58,9 @@ func (h *Handler) VerifyEmail(c *gin.Context) {
 		return
 	}
 
-	db.DB.Table("auth.verification_tokens").Delete(&token)
+	h.DB.Table("auth.verification_tokens").Delete(&token)
+
+	h.Audit.Log(token.UserID, audit.ActionEmailVerified, audit.Request(c))
 
     c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
 }
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS set_updated_at_loans_trigger ON books.loans;
+
+DROP TABLE IF EXISTS books.loans;
@@ -0,0 +1,27 @@
+CREATE TABLE books.loans (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  book_id UUID NOT NULL REFERENCES books.books(id) ON DELETE CASCADE,
+  owner_id UUID NOT NULL REFERENCES auth.users(id) ON DELETE CASCADE,
+  borrower_id UUID NOT NULL REFERENCES auth.users(id) ON DELETE CASCADE,
+  status TEXT NOT NULL DEFAULT 'requested'
+    CHECK (status IN ('requested', 'approved', 'rejected', 'returned')),
+  message TEXT,
+  decided_at TIMESTAMPTZ,
+  returned_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ DEFAULT now(),
+  updated_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX loans_owner_id_idx ON books.loans (owner_id);
+CREATE INDEX loans_borrower_id_idx ON books.loans (borrower_id);
+
+-- A book can only be lent to one borrower at a time
+CREATE UNIQUE INDEX loans_active_book_idx ON books.loans (book_id) WHERE status = 'approved';
+
+-- A borrower can only have one open request per book
+CREATE UNIQUE INDEX loans_pending_request_idx ON books.loans (book_id, borrower_id) WHERE status = 'requested';
+
+CREATE TRIGGER set_updated_at_loans_trigger
+BEFORE UPDATE ON books.loans
+FOR EACH ROW
+EXECUTE FUNCTION books.set_updated_at();
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS books.books_public_created_at_idx;
+
+ALTER TABLE books.books DROP COLUMN IF EXISTS visibility;
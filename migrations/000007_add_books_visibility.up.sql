@@ -0,0 +1,5 @@
+ALTER TABLE books.books
+  ADD COLUMN visibility TEXT NOT NULL DEFAULT 'private'
+    CHECK (visibility IN ('private', 'public'));
+
+CREATE INDEX books_public_created_at_idx ON books.books (created_at DESC) WHERE visibility = 'public';
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS auth.users_created_at_id_idx;
+
+DROP INDEX IF EXISTS books.books_user_id_created_at_id_idx;
@@ -0,0 +1,3 @@
+CREATE INDEX books_user_id_created_at_id_idx ON books.books (user_id, created_at, id);
+
+CREATE INDEX users_created_at_id_idx ON auth.users (created_at, id);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS books.books_isbn_idx;
+
+ALTER TABLE books.books
+  DROP COLUMN IF EXISTS publication_year,
+  DROP COLUMN IF EXISTS page_count,
+  DROP COLUMN IF EXISTS publisher,
+  DROP COLUMN IF EXISTS cover_url,
+  DROP COLUMN IF EXISTS isbn;
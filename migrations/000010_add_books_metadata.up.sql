@@ -0,0 +1,8 @@
+ALTER TABLE books.books
+  ADD COLUMN isbn TEXT,
+  ADD COLUMN cover_url TEXT,
+  ADD COLUMN publisher TEXT,
+  ADD COLUMN page_count INTEGER,
+  ADD COLUMN publication_year INTEGER;
+
+CREATE INDEX books_isbn_idx ON books.books (isbn);
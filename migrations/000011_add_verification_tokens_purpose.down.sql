@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS auth.verification_tokens_user_id_purpose_idx;
+
+DELETE FROM auth.verification_tokens WHERE purpose <> 'email_verification';
+
+ALTER TABLE auth.verification_tokens DROP COLUMN IF EXISTS purpose;
@@ -0,0 +1,5 @@
+ALTER TABLE auth.verification_tokens
+  ADD COLUMN purpose TEXT NOT NULL DEFAULT 'email_verification'
+    CHECK (purpose IN ('email_verification', 'password_reset'));
+
+CREATE INDEX verification_tokens_user_id_purpose_idx ON auth.verification_tokens (user_id, purpose);
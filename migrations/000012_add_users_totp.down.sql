@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS auth.recovery_codes;
+
+ALTER TABLE auth.users
+  DROP COLUMN IF EXISTS totp_enabled,
+  DROP COLUMN IF EXISTS totp_secret;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS logs.audit_logs_action_created_at_id_idx;
+DROP INDEX IF EXISTS logs.audit_logs_user_id_created_at_id_idx;
+DROP INDEX IF EXISTS logs.audit_logs_created_at_id_idx;
+DROP INDEX IF EXISTS logs.audit_logs_metadata_idx;
+
+ALTER TABLE logs.audit_logs
+  ALTER COLUMN metadata TYPE TEXT USING metadata::text;
@@ -0,0 +1,8 @@
+ALTER TABLE logs.audit_logs
+  ALTER COLUMN metadata TYPE JSONB USING NULLIF(metadata, '')::jsonb;
+
+CREATE INDEX audit_logs_metadata_idx ON logs.audit_logs USING GIN (metadata jsonb_path_ops);
+
+CREATE INDEX audit_logs_created_at_id_idx ON logs.audit_logs (created_at, id);
+CREATE INDEX audit_logs_user_id_created_at_id_idx ON logs.audit_logs (user_id, created_at, id);
+CREATE INDEX audit_logs_action_created_at_id_idx ON logs.audit_logs (action, created_at, id);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS logs.audit_logs_seq_idx;
+
+ALTER TABLE logs.audit_logs
+  DROP COLUMN IF EXISTS hash,
+  DROP COLUMN IF EXISTS prev_hash,
+  DROP COLUMN IF EXISTS seq;
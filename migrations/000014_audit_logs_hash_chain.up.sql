@@ -0,0 +1,15 @@
+ALTER TABLE logs.audit_logs
+  ADD COLUMN seq BIGINT,
+  ADD COLUMN prev_hash TEXT,
+  ADD COLUMN hash TEXT;
+
+-- Existing entries get a position but no hash: the chain starts with the
+-- first entry written after this migration
+UPDATE logs.audit_logs a
+SET seq = n.seq
+FROM (SELECT id, ROW_NUMBER() OVER (ORDER BY created_at, id) AS seq FROM logs.audit_logs) n
+WHERE a.id = n.id;
+
+ALTER TABLE logs.audit_logs ALTER COLUMN seq SET NOT NULL;
+
+CREATE UNIQUE INDEX audit_logs_seq_idx ON logs.audit_logs (seq);
@@ -0,0 +1 @@
+ALTER TABLE auth.users DROP COLUMN IF EXISTS suspended_at;
@@ -0,0 +1 @@
+ALTER TABLE auth.users ADD COLUMN suspended_at TIMESTAMPTZ;
@@ -0,0 +1,5 @@
+ALTER TABLE auth.users DROP CONSTRAINT IF EXISTS users_role_fkey;
+
+DROP TABLE IF EXISTS auth.role_permissions;
+DROP TABLE IF EXISTS auth.permissions;
+DROP TABLE IF EXISTS auth.roles;
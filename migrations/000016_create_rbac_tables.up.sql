@@ -0,0 +1,38 @@
+CREATE TABLE auth.roles (
+  name TEXT PRIMARY KEY,
+  description TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE auth.permissions (
+  name TEXT PRIMARY KEY,
+  description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE auth.role_permissions (
+  role TEXT NOT NULL REFERENCES auth.roles(name) ON DELETE CASCADE,
+  permission TEXT NOT NULL REFERENCES auth.permissions(name) ON DELETE CASCADE,
+  PRIMARY KEY (role, permission)
+);
+
+INSERT INTO auth.roles (name, description) VALUES
+  ('user', 'Regular account'),
+  ('admin', 'Full administrative access');
+
+-- Keep any role already assigned to a user valid
+INSERT INTO auth.roles (name)
+SELECT DISTINCT role FROM auth.users WHERE role IS NOT NULL
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO auth.permissions (name, description) VALUES
+  ('books:moderate', 'Remove any user''s books'),
+  ('users:manage', 'View, change roles of, suspend, verify and delete users'),
+  ('audit:read', 'Query, export and verify the audit log'),
+  ('roles:manage', 'Create and delete roles and assign their permissions'),
+  ('metrics:read', 'Read runtime counters');
+
+INSERT INTO auth.role_permissions (role, permission)
+SELECT 'admin', name FROM auth.permissions;
+
+ALTER TABLE auth.users
+  ADD CONSTRAINT users_role_fkey FOREIGN KEY (role) REFERENCES auth.roles(name);
@@ -0,0 +1 @@
+DELETE FROM auth.permissions WHERE name = 'ratelimits:manage';
@@ -0,0 +1,5 @@
+INSERT INTO auth.permissions (name, description) VALUES
+  ('ratelimits:manage', 'View rate limit rules, override client limits and reset counters');
+
+INSERT INTO auth.role_permissions (role, permission) VALUES
+  ('admin', 'ratelimits:manage');
@@ -0,0 +1,3 @@
+ALTER TABLE logs.audit_logs
+  ADD CONSTRAINT audit_logs_user_id_fkey FOREIGN KEY (user_id)
+  REFERENCES auth.users(id) ON DELETE SET NULL NOT VALID;
@@ -0,0 +1,3 @@
+-- Audit entries outlive the users they reference: ON DELETE SET NULL would
+-- rewrite chained rows when an admin deletes a user
+ALTER TABLE logs.audit_logs DROP CONSTRAINT IF EXISTS audit_logs_user_id_fkey;
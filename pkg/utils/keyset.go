@@ -0,0 +1,218 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrNoSigningKey   = errors.New("no signing key configured")
+	ErrUnknownKeyID   = errors.New("unknown key id")
+	ErrUnexpectedAlgo = errors.New("unexpected signing algorithm")
+)
+
+// SigningKey is a single entry of a KeySet. Keys loaded from a public key
+// file have no Private part and can only be used to verify tokens.
+type SigningKey struct {
+	KID     string
+	Method  jwt.SigningMethod
+	Private crypto.PrivateKey
+	Public  crypto.PublicKey
+}
+
+// KeySet holds every key ParseToken accepts and the one GenerateAccessToken
+// signs with. Keeping retired keys in the set lets tokens signed before a
+// rotation stay valid until they expire.
+type KeySet struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+// NewHMACKeySet returns a keyset that signs and verifies HS256 tokens with a
+// shared secret. Tokens carry no kid in this mode.
+func NewHMACKeySet(secret []byte) *KeySet {
+	key := &SigningKey{Method: jwt.SigningMethodHS256, Private: secret, Public: secret}
+	return &KeySet{active: key, keys: map[string]*SigningKey{"": key}}
+}
+
+// LoadKeySet reads every <kid>.pem file in dir. Private keys (PKCS#8 RSA or
+// Ed25519, or PKCS#1 RSA) can sign; public keys (PKIX) only verify. activeKID
+// selects the signing key and may be empty when the directory holds exactly
+// one private key.
+func LoadKeySet(dir, activeKID string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	ks := &KeySet{keys: make(map[string]*SigningKey)}
+	var signers []*SigningKey
+	for _, path := range paths {
+		kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := loadKeyFile(path, kid)
+		if err != nil {
+			return nil, fmt.Errorf("load key %q: %w", kid, err)
+		}
+		ks.keys[kid] = key
+		if key.Private != nil {
+			signers = append(signers, key)
+		}
+	}
+
+	switch {
+	case activeKID != "":
+		key, ok := ks.keys[activeKID]
+		if !ok || key.Private == nil {
+			return nil, fmt.Errorf("active key %q: %w", activeKID, ErrNoSigningKey)
+		}
+		ks.active = key
+	case len(signers) == 1:
+		ks.active = signers[0]
+	default:
+		return nil, fmt.Errorf("%d private keys in %s, set the active key id: %w", len(signers), dir, ErrNoSigningKey)
+	}
+
+	return ks, nil
+}
+
+// AddLegacyHMAC lets the keyset verify HS256 tokens without a kid, which is
+// what the API issued before moving to asymmetric keys.
+func (ks *KeySet) AddLegacyHMAC(secret []byte) {
+	ks.keys[""] = &SigningKey{Method: jwt.SigningMethodHS256, Public: secret}
+}
+
+func loadKeyFile(path, kid string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	key := &SigningKey{KID: kid}
+	switch block.Type {
+	case "PRIVATE KEY":
+		priv, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key.Private = priv
+	case "RSA PRIVATE KEY":
+		priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key.Private = priv
+	case "PUBLIC KEY":
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key.Public = pub
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+
+	switch priv := key.Private.(type) {
+	case *rsa.PrivateKey:
+		key.Public = &priv.PublicKey
+	case ed25519.PrivateKey:
+		key.Public = priv.Public()
+	case nil:
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", priv)
+	}
+
+	switch key.Public.(type) {
+	case *rsa.PublicKey:
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key.Public)
+	}
+
+	return key, nil
+}
+
+// Sign signs claims with the active key and sets the kid header.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	if ks.active == nil {
+		return "", ErrNoSigningKey
+	}
+	token := jwt.NewWithClaims(ks.active.Method, claims)
+	if ks.active.KID != "" {
+		token.Header["kid"] = ks.active.KID
+	}
+	return token.SignedString(ks.active.Private)
+}
+
+// Keyfunc resolves the verification key from the token's kid header and
+// rejects tokens whose alg does not match that key.
+func (ks *KeySet) Keyfunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKeyID
+	}
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, ErrUnexpectedAlgo
+	}
+	return key.Public, nil
+}
+
+// JWK is a public key in RFC 7517 format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public half of every asymmetric key in the set, sorted by
+// kid. HMAC secrets are never published.
+func (ks *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range ks.keys {
+		jwk := JWK{Kid: key.KID, Use: "sig", Alg: key.Method.Alg()}
+		switch pub := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	sort.Slice(jwks.Keys, func(i, j int) bool { return jwks.Keys[i].Kid < jwks.Keys[j].Kid })
+	return jwks
+}
@@ -0,0 +1,183 @@
+package utils_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/pkg/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func writePEM(t *testing.T, dir, kid, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0o600))
+}
+
+func writeRSAKey(t *testing.T, dir, kid string) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	writePEM(t, dir, kid, "PRIVATE KEY", der)
+	return key
+}
+
+func writeEd25519Key(t *testing.T, dir, kid string) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	writePEM(t, dir, kid, "PRIVATE KEY", der)
+	return key
+}
+
+func testClaims() utils.JWTClaims {
+	return utils.JWTClaims{
+		UserID: "user-1",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}
+}
+
+func parse(ks *utils.KeySet, token string) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(token, &utils.JWTClaims{}, ks.Keyfunc)
+}
+
+func TestLoadKeySet_SignsWithActiveKey(t *testing.T) {
+	dir := t.TempDir()
+	writeRSAKey(t, dir, "rsa-1")
+	writeEd25519Key(t, dir, "ed-1")
+
+	ks, err := utils.LoadKeySet(dir, "ed-1")
+	require.NoError(t, err)
+
+	token, err := ks.Sign(testClaims())
+	require.NoError(t, err)
+
+	parsed, err := parse(ks, token)
+	require.NoError(t, err)
+	require.Equal(t, "ed-1", parsed.Header["kid"])
+	require.Equal(t, "EdDSA", parsed.Method.Alg())
+}
+
+func TestLoadKeySet_RequiresActiveKID(t *testing.T) {
+	dir := t.TempDir()
+	writeRSAKey(t, dir, "a")
+	writeRSAKey(t, dir, "b")
+
+	_, err := utils.LoadKeySet(dir, "")
+	require.ErrorIs(t, err, utils.ErrNoSigningKey)
+
+	_, err = utils.LoadKeySet(dir, "missing")
+	require.ErrorIs(t, err, utils.ErrNoSigningKey)
+
+	// A single private key is picked automatically
+	single := t.TempDir()
+	writeRSAKey(t, single, "only")
+	_, err = utils.LoadKeySet(single, "")
+	require.NoError(t, err)
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	old := writeRSAKey(t, dir, "2026-01")
+
+	before, err := utils.LoadKeySet(dir, "2026-01")
+	require.NoError(t, err)
+	oldToken, err := before.Sign(testClaims())
+	require.NoError(t, err)
+
+	// Rotate: new active key, the old one is kept as public key only
+	writeEd25519Key(t, dir, "2026-02")
+	der, err := x509.MarshalPKIXPublicKey(&old.PublicKey)
+	require.NoError(t, err)
+	writePEM(t, dir, "2026-01", "PUBLIC KEY", der)
+
+	after, err := utils.LoadKeySet(dir, "2026-02")
+	require.NoError(t, err)
+
+	_, err = parse(after, oldToken)
+	require.NoError(t, err)
+
+	newToken, err := after.Sign(testClaims())
+	require.NoError(t, err)
+	_, err = parse(before, newToken)
+	require.ErrorIs(t, err, utils.ErrUnknownKeyID)
+
+	// A public-only key can't become the signing key
+	_, err = utils.LoadKeySet(dir, "2026-01")
+	require.ErrorIs(t, err, utils.ErrNoSigningKey)
+}
+
+func TestKeySet_RejectsAlgorithmMismatch(t *testing.T) {
+	dir := t.TempDir()
+	key := writeRSAKey(t, dir, "rsa")
+
+	ks, err := utils.LoadKeySet(dir, "")
+	require.NoError(t, err)
+
+	// HS256 token keyed with the public modulus must not verify
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+	forged.Header["kid"] = "rsa"
+	token, err := forged.SignedString(key.PublicKey.N.Bytes())
+	require.NoError(t, err)
+
+	_, err = parse(ks, token)
+	require.ErrorIs(t, err, utils.ErrUnexpectedAlgo)
+}
+
+func TestKeySet_LegacyHMAC(t *testing.T) {
+	secret := []byte("legacy-secret")
+	legacy := utils.NewHMACKeySet(secret)
+	legacyToken, err := legacy.Sign(testClaims())
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	writeEd25519Key(t, dir, "ed")
+	ks, err := utils.LoadKeySet(dir, "")
+	require.NoError(t, err)
+
+	_, err = parse(ks, legacyToken)
+	require.ErrorIs(t, err, utils.ErrUnknownKeyID)
+
+	ks.AddLegacyHMAC(secret)
+	_, err = parse(ks, legacyToken)
+	require.NoError(t, err)
+}
+
+func TestKeySet_JWKS(t *testing.T) {
+	dir := t.TempDir()
+	writeRSAKey(t, dir, "a-rsa")
+	writeEd25519Key(t, dir, "b-ed")
+
+	ks, err := utils.LoadKeySet(dir, "a-rsa")
+	require.NoError(t, err)
+	ks.AddLegacyHMAC([]byte("secret"))
+
+	jwks := ks.JWKS()
+	require.Len(t, jwks.Keys, 2)
+
+	require.Equal(t, "a-rsa", jwks.Keys[0].Kid)
+	require.Equal(t, "RSA", jwks.Keys[0].Kty)
+	require.Equal(t, "RS256", jwks.Keys[0].Alg)
+	require.Equal(t, "AQAB", jwks.Keys[0].E)
+	require.NotEmpty(t, jwks.Keys[0].N)
+
+	require.Equal(t, "b-ed", jwks.Keys[1].Kid)
+	require.Equal(t, "OKP", jwks.Keys[1].Kty)
+	require.Equal(t, "Ed25519", jwks.Keys[1].Crv)
+	require.Equal(t, "EdDSA", jwks.Keys[1].Alg)
+	require.NotEmpty(t, jwks.Keys[1].X)
+}
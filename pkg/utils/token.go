@@ -3,10 +3,12 @@ package utils
 import (
 	"crypto/rand"
 	"encoding/hex"
-	"os"
+	"errors"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 func GenerateRandomToken(n int) (string, error) {
@@ -18,29 +20,104 @@ func GenerateRandomToken(n int) (string, error) {
 	return hex.EncodeToString(b), nil
 }
 
-var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
+var defaultKeySet atomic.Pointer[KeySet]
+
+var ErrNoKeySet = errors.New("no JWT keyset configured")
+
+// NewKeySet builds the keyset for the JWT settings. With dir set, tokens are
+// signed with the key named by activeKID; otherwise secret is used for
+// HS256. When both are set, secret still verifies tokens issued before the
+// switch.
+func NewKeySet(secret []byte, dir, activeKID string) (*KeySet, error) {
+	if dir == "" {
+		return NewHMACKeySet(secret), nil
+	}
+
+	ks, err := LoadKeySet(dir, activeKID)
+	if err != nil {
+		return nil, err
+	}
+	if len(secret) > 0 {
+		ks.AddLegacyHMAC(secret)
+	}
+	return ks, nil
+}
+
+// DefaultKeySet returns the keyset used by GenerateAccessToken and
+// ParseToken, as installed by SetDefaultKeySet at startup.
+func DefaultKeySet() (*KeySet, error) {
+	ks := defaultKeySet.Load()
+	if ks == nil {
+		return nil, ErrNoKeySet
+	}
+	return ks, nil
+}
+
+// SetDefaultKeySet replaces the keyset returned by DefaultKeySet.
+func SetDefaultKeySet(ks *KeySet) {
+	defaultKeySet.Store(ks)
+}
+
+// TokenPurposeMFA marks the short-lived token LoginUser hands out when a
+// second factor is still required. It can't be used as an access token.
+const TokenPurposeMFA = "mfa"
+
+var ErrTokenPurpose = errors.New("token issued for a different purpose")
 
 type JWTClaims struct {
 	UserID string `json:"user_id"`
+	// Role is the user's role when the token was issued, so permission
+	// checks need no database lookup. Role changes revoke the user's tokens.
+	Role    string `json:"role,omitempty"`
+	Purpose string `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateAccessToken(userID string, ttl time.Duration) (string, error) {
+func GenerateAccessToken(userID, role string, ttl time.Duration) (string, error) {
+	return generateToken(userID, role, "", ttl)
+}
+
+// GenerateMFAToken issues the challenge token exchanged at /login/mfa.
+func GenerateMFAToken(userID string, ttl time.Duration) (string, error) {
+	return generateToken(userID, "", TokenPurposeMFA, ttl)
+}
+
+func generateToken(userID, role, purpose string, ttl time.Duration) (string, error) {
 	claims := JWTClaims{
-		UserID: userID,
+		UserID:  userID,
+		Role:    role,
+		Purpose: purpose,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	ks, err := DefaultKeySet()
+	if err != nil {
+		return "", err
+	}
+	return ks.Sign(claims)
 }
 
+// ParseToken parses an access token. Tokens issued for another purpose are
+// rejected with ErrTokenPurpose.
 func ParseToken(tokenStr string) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenStr, &JWTClaims{}, func(t *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
+	return parseToken(tokenStr, "")
+}
+
+// ParseMFAToken parses a token issued by GenerateMFAToken.
+func ParseMFAToken(tokenStr string) (*JWTClaims, error) {
+	return parseToken(tokenStr, TokenPurposeMFA)
+}
+
+func parseToken(tokenStr, purpose string) (*JWTClaims, error) {
+	ks, err := DefaultKeySet()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.ParseWithClaims(tokenStr, &JWTClaims{}, ks.Keyfunc)
 	if err != nil {
 		return nil, err
 	}
@@ -49,6 +126,9 @@ func ParseToken(tokenStr string) (*JWTClaims, error) {
 	if !ok || !token.Valid {
 		return nil, err
 	}
+	if claims.Purpose != purpose {
+		return nil, ErrTokenPurpose
+	}
 
 	return claims, nil
 }
@@ -0,0 +1,33 @@
+package utils_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/pkg/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseToken_RejectsOtherPurposes(t *testing.T) {
+	utils.SetDefaultKeySet(utils.NewHMACKeySet([]byte("test-secret")))
+
+	mfaToken, err := utils.GenerateMFAToken("user-1", time.Minute)
+	require.NoError(t, err)
+
+	_, err = utils.ParseToken(mfaToken)
+	require.ErrorIs(t, err, utils.ErrTokenPurpose)
+
+	claims, err := utils.ParseMFAToken(mfaToken)
+	require.NoError(t, err)
+	require.Equal(t, "user-1", claims.UserID)
+
+	accessToken, err := utils.GenerateAccessToken("user-1", "admin", time.Minute)
+	require.NoError(t, err)
+
+	_, err = utils.ParseMFAToken(accessToken)
+	require.ErrorIs(t, err, utils.ErrTokenPurpose)
+
+	claims, err = utils.ParseToken(accessToken)
+	require.NoError(t, err)
+	require.Equal(t, "admin", claims.Role)
+}
@@ -0,0 +1,70 @@
+package utils_test
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/DMaryanskiy/bookshare-api/pkg/utils"
+	"github.com/stretchr/testify/require"
+)
+
+// RFC 6238 appendix B test secret for SHA-1
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCode_RFCVectors(t *testing.T) {
+	// The RFC lists 8-digit codes; these are their last 6 digits
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+
+	for ts, want := range vectors {
+		code, err := utils.TOTPCode(rfcSecret, time.Unix(ts, 0))
+		require.NoError(t, err)
+		require.Equal(t, want, code, "time %d", ts)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := utils.GenerateTOTPSecret()
+	require.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+	code, err := utils.TOTPCode(secret, now)
+	require.NoError(t, err)
+
+	step, ok := utils.ValidateTOTP(secret, code, now)
+	require.True(t, ok)
+	require.Equal(t, now.Unix()/30, step)
+
+	// Accepted one period either side for clock drift
+	_, ok = utils.ValidateTOTP(secret, code, now.Add(utils.TOTPPeriod))
+	require.True(t, ok)
+	_, ok = utils.ValidateTOTP(secret, code, now.Add(-utils.TOTPPeriod))
+	require.True(t, ok)
+
+	_, ok = utils.ValidateTOTP(secret, code, now.Add(3*utils.TOTPPeriod))
+	require.False(t, ok)
+
+	_, ok = utils.ValidateTOTP(secret, "12345", now)
+	require.False(t, ok)
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := utils.TOTPURI("BookShare", "reader@example.com", "ABCDEF")
+
+	u, err := url.Parse(uri)
+	require.NoError(t, err)
+	require.Equal(t, "otpauth", u.Scheme)
+	require.Equal(t, "totp", u.Host)
+	require.Equal(t, "/BookShare:reader@example.com", u.Path)
+	require.Equal(t, "ABCDEF", u.Query().Get("secret"))
+	require.Equal(t, "BookShare", u.Query().Get("issuer"))
+	require.Equal(t, "6", u.Query().Get("digits"))
+}